@@ -506,14 +506,21 @@ func newNewCmd() *cobra.Command {
 
 // errorIfNotEmptyDirectory returns an error if path is not empty.
 func errorIfNotEmptyDirectory(path string) error {
+	return errorIfNotEmptyDirectoryExcept(path, nil)
+}
+
+// errorIfNotEmptyDirectoryExcept returns an error if path contains any entry whose name is not in ignore.
+func errorIfNotEmptyDirectoryExcept(path string, ignore map[string]bool) error {
 	infos, err := ioutil.ReadDir(path)
 	if err != nil {
 		return err
 	}
 
-	if len(infos) > 0 {
-		return fmt.Errorf("%s is not empty; "+
-			"rerun in an empty directory, pass the path to an empty directory to --dir, or use --force", path)
+	for _, info := range infos {
+		if !ignore[info.Name()] {
+			return fmt.Errorf("%s is not empty; "+
+				"rerun in an empty directory, pass the path to an empty directory to --dir, or use --force", path)
+		}
 	}
 
 	return nil
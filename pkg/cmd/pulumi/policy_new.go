@@ -15,33 +15,154 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/google/shlex"
 	"github.com/pulumi/pulumi/pkg/v3/backend/display"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/diag/colors"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/encoding"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/util/cmdutil"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/util/contract"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/executable"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/gitutil"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
 	"github.com/pulumi/pulumi/sdk/v3/nodejs/npm"
 	"github.com/pulumi/pulumi/sdk/v3/python"
+	"github.com/sergi/go-diff/diffmatchpatch"
 	"github.com/spf13/cobra"
 	survey "gopkg.in/AlecAivazis/survey.v1"
 	surveycore "gopkg.in/AlecAivazis/survey.v1/core"
+	"gopkg.in/yaml.v3"
 )
 
 type newPolicyArgs struct {
-	dir               string
-	force             bool
-	generateOnly      bool
-	interactive       bool
-	offline           bool
-	templateNameOrURL string
-	yes               bool
+	all                 bool
+	allowHooks          bool
+	archive             string
+	description         string
+	dir                 string
+	excludes            []string
+	force               bool
+	fromExisting        bool
+	generateOnly        bool
+	interactive         bool
+	jsonOut             bool
+	keepTemp            bool
+	language            string
+	listTemplates       bool
+	noGitignore         bool
+	noVerify            bool
+	offline             bool
+	preview             bool
+	proxy               string
+	readme              bool
+	templateNameOrURL   string
+	templateSearchPaths []string
+	upgrade             bool
+	variables           []string
+	yes                 bool
+}
+
+var (
+	// ErrDirectoryNotEmpty is matched by the error that `pulumi policy new` returns when the directory that a Policy
+	// Pack would be created in is not empty.
+	ErrDirectoryNotEmpty = errors.New("directory not empty")
+	// ErrTemplateNotFound is matched by the error that `pulumi policy new` returns when there is no template with the
+	// requested name, or none for the requested language.
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrDependencyInstall is matched by the error that `pulumi policy new` returns when the dependencies of a Policy
+	// Pack could not be installed.
+	ErrDependencyInstall = errors.New("installing dependencies failed")
+)
+
+// policyPackError is an error from `pulumi policy new` that errors.Is matches to one of the sentinel errors above. Its
+// message and cause are those of the error that it wraps.
+type policyPackError struct {
+	kind error
+	err  error
+}
+
+func (e *policyPackError) Error() string {
+	return e.err.Error()
+}
+
+func (e *policyPackError) Unwrap() error {
+	return e.err
+}
+
+func (e *policyPackError) Is(target error) bool {
+	return target == e.kind
+}
+
+// newPolicyPackError returns err marked as an error of the given kind, which is one of the sentinel errors above.
+func newPolicyPackError(kind, err error) error {
+	return &policyPackError{kind: kind, err: err}
+}
+
+// policyPackDirectoryMetadata holds the names of the version control and editor metadata entries that
+// errorIfPolicyPackDirectoryNotEmpty ignores, so that a Policy Pack can be created in a freshly initialized repository.
+var policyPackDirectoryMetadata = map[string]bool{
+	".git":       true,
+	".gitignore": true,
+	".vscode":    true,
+	".idea":      true,
+	".DS_Store":  true,
+}
+
+// errorIfPolicyPackDirectoryNotEmpty returns an error that matches ErrDirectoryNotEmpty if the directory contains
+// anything other than the metadata entries in policyPackDirectoryMetadata. An error reading the directory is returned
+// as it is.
+func errorIfPolicyPackDirectoryNotEmpty(dir string) error {
+	err := errorIfNotEmptyDirectoryExcept(dir, policyPackDirectoryMetadata)
+	var pathErr *os.PathError
+	if err == nil || errors.As(err, &pathErr) {
+		return err
+	}
+	return newPolicyPackError(ErrDirectoryNotEmpty, err)
+}
+
+// pulumiPolicyTemplateURLEnvVar names the template that `pulumi policy new` uses when no template is given, e.g. the
+// URL of an internal template repository.
+const pulumiPolicyTemplateURLEnvVar = "PULUMI_POLICY_TEMPLATE_URL"
+
+// pulumiCABundleEnvVar names a PEM file of certificate authorities that template downloads trust in addition to the
+// system's, e.g. those of a proxy that inspects TLS traffic.
+const pulumiCABundleEnvVar = "PULUMI_CA_BUNDLE"
+
+// pulumiTemplateTokenEnvVar and githubTokenEnvVar name the tokens that template downloads authenticate with, so that
+// templates can be retrieved from private repositories. The tokens are only sent to the host of the template URL, and
+// the GitHub token only if that host is github.com.
+const (
+	pulumiTemplateTokenEnvVar = "PULUMI_TEMPLATE_TOKEN"
+	githubTokenEnvVar         = "GITHUB_TOKEN"
+)
+
+// policyPackTemplateNameOrURL returns the template to create a Policy Pack from: the template given on the command
+// line, if any, or else the value of PULUMI_POLICY_TEMPLATE_URL. If neither is set, it returns "", which selects
+// from the built-in templates.
+func policyPackTemplateNameOrURL(cliArgs []string) string {
+	if len(cliArgs) > 0 {
+		return cliArgs[0]
+	}
+	return os.Getenv(pulumiPolicyTemplateURLEnvVar)
 }
 
 func newPolicyNewCmd() *cobra.Command {
@@ -59,84 +180,289 @@ func newPolicyNewCmd() *cobra.Command {
 			"or `azure-python`).  If no template name is provided, a list of suggested templates will be presented\n" +
 			"which can be selected interactively.\n" +
 			"\n" +
+			"A template may also be given as the path to a local `.tar.gz` or `.tgz` archive of templates.\n" +
+			"\n" +
+			"Some templates bundle several related Policy Packs. Pass --all to create each of them in its own\n" +
+			"subdirectory of the target directory.\n" +
+			"\n" +
+			"If no template is given and the PULUMI_POLICY_TEMPLATE_URL environment variable is set, its value is\n" +
+			"used as if it had been passed as the template. A template passed on the command line takes precedence\n" +
+			"over PULUMI_POLICY_TEMPLATE_URL, which takes precedence over the built-in list of templates.\n" +
+			"\n" +
+			"If the PULUMI_CA_BUNDLE environment variable is set to the path of a PEM file, the certificate\n" +
+			"authorities in it are trusted when downloading templates, as well as the system's.\n" +
+			"\n" +
+			"To download templates from a private repository, set the PULUMI_TEMPLATE_TOKEN environment variable to\n" +
+			"a token that it accepts. The token is sent over HTTPS to the host of the template URL only, as a bearer\n" +
+			"token. For repositories on github.com, a token in GITHUB_TOKEN is used if PULUMI_TEMPLATE_TOKEN is not\n" +
+			"set.\n" +
+			"\n" +
+			"Pass --upgrade in the directory of an existing Policy Pack to update its framework files, such as its\n" +
+			"package.json, from a newer version of the template it was created from. The Policy Pack's policies are\n" +
+			"left alone, as are framework files that were changed since they were generated, unless --force is given.\n" +
+			"Policy Packs created from templates with framework files record them in a .pulumi-policy-template.json\n" +
+			"file for --upgrade; commit it along with the Policy Pack.\n" +
+			"\n" +
 			"Once you're done authoring the Policy Pack, you will need to publish the pack to your organization.\n" +
 			"Only organization administrators can publish a Policy Pack.",
 		Args: cmdutil.MaximumNArgs(1),
 		Run: cmdutil.RunFunc(func(cmd *cobra.Command, cliArgs []string) error {
-			if len(cliArgs) > 0 {
-				args.templateNameOrURL = cliArgs[0]
+			args.templateNameOrURL = policyPackTemplateNameOrURL(cliArgs)
+			if cmd.Flags().Changed("description") && strings.TrimSpace(args.description) == "" {
+				return errors.New("--description must not be empty")
+			}
+			if args.jsonOut && (args.listTemplates || args.preview) {
+				return errors.New("--json cannot be used with --list-templates or --preview")
+			}
+			if err := validatePolicyPackExcludes(args.excludes); err != nil {
+				return err
+			}
+			if args.fromExisting && (args.force || args.preview) {
+				return errors.New("--from-existing cannot be used with --force or --preview")
+			}
+			if args.all && (args.fromExisting || args.jsonOut || args.preview) {
+				return errors.New("--all cannot be used with --from-existing, --json, or --preview")
+			}
+			if args.archive != "" && (args.all || args.dir != "" || args.fromExisting || args.jsonOut || args.preview) {
+				return errors.New("--archive cannot be used with --all, --dir, --from-existing, --json, or --preview")
+			}
+			if args.upgrade && (args.all || args.archive != "" || args.fromExisting || args.jsonOut || args.preview) {
+				return errors.New("--upgrade cannot be used with --all, --archive, --from-existing, --json, or --preview")
+			}
+			if len(parsePolicyPackLanguages(args.language)) > 1 &&
+				(args.all || args.archive != "" || args.fromExisting || args.jsonOut || args.preview || args.upgrade) {
+				return errors.New("several languages cannot be given with --all, --archive, --from-existing, --json, " +
+					"--preview, or --upgrade")
+			}
+			if _, err := parsePolicyTemplateProxy(args.proxy); err != nil {
+				return err
 			}
 			return runNewPolicyPack(context.Background(), args)
 		}),
 	}
 
+	cmd.PersistentFlags().BoolVar(
+		&args.all, "all", false,
+		"Create each of the Policy Packs bundled by the template in its own subdirectory")
+	cmd.PersistentFlags().BoolVar(
+		&args.allowHooks, "allow-hooks", false,
+		"Run the template's post-generation hooks without confirmation, even if the template was given by URL")
+	cmd.PersistentFlags().StringVar(
+		&args.archive, "archive", "",
+		"Write the Policy Pack's files to the given zip archive instead of a directory, without installing "+
+			"dependencies; an existing archive is only replaced with --force")
+	cmd.PersistentFlags().StringVarP(
+		&args.description, "description", "d", "",
+		"The Policy Pack description; if not specified, the template's description is used")
 	cmd.PersistentFlags().StringVar(
 		&args.dir, "dir", "",
 		"The location to place the generated Policy Pack; if not specified, the current directory is used")
+	cmd.PersistentFlags().StringArrayVar(
+		&args.excludes, "exclude", nil,
+		"Skip the template's files whose paths or names match the glob pattern, such as `.github` or `examples/*`; "+
+			"may be repeated")
 	cmd.PersistentFlags().BoolVarP(
 		&args.force, "force", "f", false,
 		"Forces content to be generated even if it would change existing files")
+	cmd.PersistentFlags().BoolVar(
+		&args.fromExisting, "from-existing", false,
+		"Add the template's files to the Policy Pack in the directory, skipping files that already exist")
 	cmd.PersistentFlags().BoolVarP(
 		&args.generateOnly, "generate-only", "g", false,
 		"Generate the Policy Pack only; do not install dependencies")
+	cmd.PersistentFlags().BoolVarP(
+		&args.jsonOut, "json", "j", false,
+		"Emit a summary of the created Policy Pack as JSON; other output is written to stderr")
+	cmd.PersistentFlags().BoolVar(
+		&args.keepTemp, "keep-temp", false,
+		"Keep the retrieved templates in their temporary directory and print its path, for debugging templates")
+	_ = cmd.PersistentFlags().MarkHidden("keep-temp")
+	cmd.PersistentFlags().StringVarP(
+		&args.language, "language", "l", "",
+		"Only consider templates for the given language (such as `typescript`, `python`, `go`, or `dotnet`); "+
+			"defaults to the language of a Pulumi project in the target or current directory, if there is one. "+
+			"Given a comma-separated list of languages, a Policy Pack is created for each in a subdirectory named "+
+			"after the language")
+	cmd.PersistentFlags().BoolVar(
+		&args.listTemplates, "list-templates", false,
+		"List the available templates and exit; with --offline, only locally cached templates are listed")
+	cmd.PersistentFlags().BoolVar(
+		&args.noGitignore, "no-gitignore", false,
+		"Do not write a .gitignore for the Policy Pack's runtime when the template does not include one")
+	cmd.PersistentFlags().BoolVar(
+		&args.noVerify, "no-verify", false,
+		"With --offline, use cached templates even if they have changed since they were downloaded")
 	cmd.PersistentFlags().BoolVarP(
 		&args.offline, "offline", "o", false,
 		"Use locally cached templates without making any network requests")
+	cmd.PersistentFlags().StringArrayVar(
+		&args.variables, "set", nil,
+		"Replace ${key} in the template's files with value, given as key=value; may be repeated")
+	cmd.PersistentFlags().StringArrayVar(
+		&args.templateSearchPaths, "template-search-path", nil,
+		"A directory to search for templates before the template cache and network; may be repeated")
+	cmd.PersistentFlags().BoolVar(
+		&args.upgrade, "upgrade", false,
+		"Update the framework files of the existing Policy Pack in the directory, such as its package.json, from "+
+			"the template, leaving its policies alone; files changed since they were generated are only replaced "+
+			"with --force")
+	cmd.PersistentFlags().BoolVarP(
+		&args.yes, "yes", "y", false,
+		"Skip confirmation prompts and use the default values of template parameters; "+
+			"when several templates match, one is still chosen at a prompt")
+	cmd.PersistentFlags().BoolVar(
+		&args.preview, "preview", false,
+		"Show the files the Policy Pack would create and how they differ from existing files, without writing anything")
+	cmd.PersistentFlags().StringVar(
+		&args.proxy, "proxy", "",
+		"Download templates through the given proxy, such as `http://proxy.example.com:8080`; "+
+			"if not specified, the HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables are honored")
+	cmd.PersistentFlags().BoolVar(
+		&args.readme, "readme", true,
+		"Write a README.md that lists the Policy Pack's policies when the template does not include one")
 
 	return cmd
 }
 
-func runNewPolicyPack(ctx context.Context, args newPolicyArgs) error {
-	if !args.interactive && !args.yes {
-		return errors.New("--yes must be passed in to proceed when running in non-interactive mode")
-	}
-
+func runNewPolicyPack(ctx context.Context, args newPolicyArgs) (err error) {
 	// Prepare options.
 	opts := display.Options{
 		Color:         cmdutil.GetGlobalColorization(),
 		IsInteractive: args.interactive,
 	}
 
+	// If we're only listing templates, print them and stop.
+	if args.listTemplates {
+		return listPolicyPackTemplates(args, opts)
+	}
+
+	if !args.interactive && !args.yes {
+		return errors.New("--yes must be passed in to proceed when running in non-interactive mode")
+	}
+
+	variables, err := parsePolicyPackTemplateVariables(args.variables)
+	if err != nil {
+		return err
+	}
+
 	// Get the current working directory.
 	cwd, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("getting the working directory: %w", err)
 	}
 
-	// If dir was specified, ensure it exists and use it as the
-	// current working directory.
+	// If dir was specified, ensure it exists and create the Policy Pack there instead of in the current working
+	// directory, which is left unchanged. When previewing, nothing is written, so just resolve the directory instead.
+	workingDir := cwd
 	if args.dir != "" {
-		cwd, err = useSpecifiedDir(args.dir)
+		created := false
+		if args.preview {
+			cwd, err = filepath.Abs(args.dir)
+		} else {
+			cwd, created, err = createPolicyPackDir(args.dir)
+		}
 		if err != nil {
 			return err
 		}
+
+		// If a later step fails, don't leave behind an empty directory that we created, and say where any partial
+		// output was left.
+		if created {
+			dir := cwd
+			defer func() {
+				if err != nil {
+					err = cleanUpPolicyPackDir(dir, err)
+				}
+			}()
+		}
 	}
 
-	// Return an error if the directory isn't empty.
-	if !args.force {
-		if err = errorIfNotEmptyDirectory(cwd); err != nil {
+	// --language may list several languages, in which case a Policy Pack is created for each of them.
+	languages := parsePolicyPackLanguages(args.language)
+
+	// When adding to an existing Policy Pack, the directory must contain one. Otherwise, return an error if the
+	// directory isn't empty. With --all or several languages, only the subdirectories that the Policy Packs are
+	// created in are checked. With --archive, nothing is written to the directory, so it isn't checked at all.
+	if args.fromExisting {
+		if _, err = existingPolicyPackPath(cwd, "--from-existing"); err != nil {
+			return err
+		}
+	} else if args.upgrade {
+		if _, err = existingPolicyPackPath(cwd, "--upgrade"); err != nil {
+			return err
+		}
+	} else if !args.force && !args.preview && !args.all && args.archive == "" && len(languages) <= 1 {
+		if err = errorIfPolicyPackDirectoryNotEmpty(cwd); err != nil {
 			return err
 		}
 	}
 
-	// Retrieve the templates-policy repo.
-	repo, err := workspace.RetrieveTemplates(args.templateNameOrURL, args.offline, workspace.TemplateKindPolicyPack)
-	if err != nil {
-		return err
+	// When emitting JSON, stdout is reserved for the summary, so everything else goes to stderr.
+	var stdout io.Writer = os.Stdout
+	if args.jsonOut {
+		stdout = os.Stderr
 	}
-	defer func() {
-		contract.IgnoreError(repo.Delete())
-	}()
 
-	// List the templates from the repo.
-	templates, err := repo.PolicyTemplates()
-	if err != nil {
+	// Report progress with a spinner only when a user is watching.
+	progress := policyPackProgress{
+		out:   stdout,
+		spin:  args.interactive && !args.yes,
+		quiet: args.jsonOut,
+		color: opts.Color,
+	}
+
+	// With --upgrade, update the framework files of the existing Policy Pack instead of creating one.
+	if args.upgrade {
+		return upgradePolicyPack(args, cwd, variables, progress, stdout, opts)
+	}
+
+	// Retrieve the templates.
+	retrieveMessage := "Downloading template..."
+	if args.offline {
+		retrieveMessage = "Loading template..."
+	}
+	var templates []workspace.PolicyPackTemplate
+	var cleanup func()
+	if err := progress.run(retrieveMessage, false, func() error {
+		var err error
+		templates, cleanup, err = retrievePolicyPackTemplates(args, os.Stderr, opts.Color)
 		return err
+	}); err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Given several languages, create a Policy Pack from the matching variant of the template for each of them.
+	if len(languages) > 1 {
+		variants, err := choosePolicyPackLanguageTemplates(templates, languages, opts)
+		if err != nil {
+			return err
+		}
+		return createPolicyPacks(ctx, args, variants, languages, cwd, variables, progress, stdout, opts)
+	}
+
+	// Filter the templates down to the requested language, if any. Otherwise, prefer the templates in the language of
+	// the Pulumi project that the Policy Pack is created in or next to, if there is one.
+	if len(languages) == 1 {
+		templates = filterPolicyPackTemplatesByLanguage(templates, languages[0])
+		if len(templates) == 0 {
+			return newPolicyPackError(ErrTemplateNotFound,
+				fmt.Errorf("no templates found for language '%s'", languages[0]))
+		}
+	} else if language, projectDir := projectPolicyPackLanguage(cwd, workingDir); language != "" {
+		if matching := filterPolicyPackTemplatesByLanguage(templates, language); len(matching) > 0 {
+			if len(matching) < len(templates) {
+				message := fmt.Sprintf("Using %s templates to match the Pulumi project in %s; "+
+					"pass --language to choose another language", language, projectDir)
+				fmt.Fprintln(stdout, opts.Color.Colorize(colors.SpecInfo+message+colors.Reset))
+			}
+			templates = matching
+		}
 	}
 
 	var template workspace.PolicyPackTemplate
 	if len(templates) == 0 {
-		return errors.New("no templates")
+		return newPolicyPackError(ErrTemplateNotFound, errors.New("no templates"))
 	} else if len(templates) == 1 {
 		template = templates[0]
 	} else {
@@ -145,189 +471,2296 @@ func runNewPolicyPack(ctx context.Context, args newPolicyArgs) error {
 		}
 	}
 
-	// Do a dry run, if we're not forcing files to be overwritten.
-	if !args.force {
+	// A template that bundles several Policy Packs is only used with --all, which creates all of them.
+	if args.all {
+		if len(template.Packs) == 0 {
+			return fmt.Errorf("template '%s' contains a single Policy Pack; --all requires a template that bundles "+
+				"several", template.Name)
+		}
+		return runNewPolicyPacks(ctx, args, template, cwd, variables, progress, stdout, opts)
+	} else if len(template.Packs) > 0 {
+		return fmt.Errorf("template '%s' bundles several Policy Packs (%s); rerun with --all to create them",
+			template.Name, strings.Join(template.Packs, ", "))
+	}
+
+	// Leave out the files that --exclude names, so that they are neither checked for nor written.
+	template, cleanupExcluded, err := excludePolicyPackTemplateFiles(template, args.excludes)
+	if err != nil {
+		return err
+	}
+	defer cleanupExcluded()
+
+	// Resolve the template's parameters before anything is written, so that a missing value stops us early.
+	variables, err = resolvePolicyPackTemplateParameters(template.Parameters, variables,
+		policyPackParameterPrompt(args.yes, opts))
+	if err != nil {
+		return err
+	}
+
+	// With --archive, write the Policy Pack's files to a zip archive and stop. Archives are for transport, so
+	// dependencies aren't installed and the template's hooks aren't run.
+	if args.archive != "" {
+		var unresolved []string
+		if err := progress.run("Writing archive...", false, func() error {
+			var err error
+			unresolved, err = writePolicyPackArchive(template, args.archive, args.force, args.description, variables)
+			return err
+		}); err != nil {
+			if os.IsNotExist(err) {
+				return newPolicyPackError(ErrTemplateNotFound,
+					fmt.Errorf("template '%s' not found: %w", args.templateNameOrURL, err))
+			}
+			return err
+		}
+		if warning := renderUnresolvedPlaceholdersWarning(unresolved); warning != "" {
+			fmt.Fprintln(stdout, opts.Color.Colorize(warning))
+		}
+		fmt.Fprintln(stdout, opts.Color.Colorize(
+			colors.BrightGreen+fmt.Sprintf("Created Policy Pack archive %s!", args.archive)+colors.Reset))
+		return nil
+	}
+
+	// If we're only previewing, show what would be written and stop.
+	if args.preview {
+		files, err := workspace.PreviewTemplateFiles(template.Dir, cwd, "", args.description, variables)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return newPolicyPackError(ErrTemplateNotFound,
+					fmt.Errorf("template '%s' not found: %w", args.templateNameOrURL, err))
+			}
+			return err
+		}
+		fmt.Fprint(stdout, opts.Color.Colorize(renderPolicyPackPreview(template, files, cwd)))
+		return nil
+	}
+
+	// Without prompts, nothing else confirms what is about to be created, so record it.
+	if args.yes {
+		fmt.Fprintln(stdout, opts.Color.Colorize(colors.SpecInfo+describePolicyPackScaffold(template, cwd)+colors.Reset))
+	}
+
+	// Do a dry run, if we're not forcing files to be overwritten or skipping existing files.
+	if !args.force && !args.fromExisting {
 		if err = workspace.CopyTemplateFilesDryRun(template.Dir, cwd, ""); err != nil {
 			if os.IsNotExist(err) {
-				return fmt.Errorf("template '%s' not found: %w", args.templateNameOrURL, err)
+				return newPolicyPackError(ErrTemplateNotFound,
+					fmt.Errorf("template '%s' not found: %w", args.templateNameOrURL, err))
 			}
 			return err
 		}
 	}
 
-	// Actually copy the files.
-	if err = workspace.CopyTemplateFiles(template.Dir, cwd, args.force, "", ""); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("template '%s' not found: %w", args.templateNameOrURL, err)
+	// Record the files the template will write, for the JSON summary.
+	var files []string
+	if args.jsonOut && !args.fromExisting {
+		previews, err := workspace.PreviewTemplateFiles(template.Dir, cwd, "", args.description, variables)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, file := range previews {
+			files = append(files, file.Path)
 		}
-		return err
 	}
 
-	fmt.Println("Created Policy Pack!")
+	// An existing Policy Pack may already have a .gitignore or README.md, which is not one of the files added.
+	gitignore := filepath.Join(cwd, ".gitignore")
+	_, statErr := os.Stat(gitignore)
+	hadGitignore := args.fromExisting && statErr == nil
+	readme := filepath.Join(cwd, "README.md")
+	_, statErr = os.Stat(readme)
+	hadReadme := args.fromExisting && statErr == nil
 
-	proj, projPath, root, err := readPolicyProject()
+	// Record the framework files as they are generated, so that --upgrade can tell whether they were changed since.
+	var source string
+	if len(template.Framework) > 0 && !args.fromExisting {
+		source = template.Name
+		if workspace.IsTemplateURL(args.templateNameOrURL) {
+			source = args.templateNameOrURL
+		}
+	}
+
+	pack, err := createPolicyPack(ctx, args, template, cwd, "", source, variables, progress, stdout, opts)
 	if err != nil {
 		return err
 	}
+	if args.fromExisting {
+		files = pack.added
+	}
 
-	// Install dependencies.
-	if !args.generateOnly {
-		if err := installPolicyPackDependencies(ctx, proj, projPath, root); err != nil {
-			return err
-		}
+	if !args.jsonOut {
+		ready := colors.BrightGreen + colors.Bold + "Your new Policy Pack is ready to go!" + colors.Reset
+		fmt.Fprintln(stdout, opts.Color.Colorize(ready)+" "+cmdutil.EmojiOr("✨", ""))
+		fmt.Fprintln(stdout)
 	}
 
-	fmt.Println(
-		opts.Color.Colorize(
-			colors.BrightGreen+colors.Bold+"Your new Policy Pack is ready to go!"+colors.Reset) +
-			" " + cmdutil.EmojiOr("✨", ""))
-	fmt.Println()
+	if err := workspace.ValidatePolicyPackName(pack.name); err != nil {
+		warning := fmt.Sprintf("warning: the Policy Pack name '%s' will not publish cleanly: %v", pack.name, err)
+		fmt.Fprintln(stdout, opts.Color.Colorize(colors.SpecWarning+warning+colors.Reset))
+		fmt.Fprintln(stdout)
+	}
+
+	if args.jsonOut {
+		// The .gitignore and README.md are written after the template's files, so add them if they were.
+		if _, err := os.Stat(gitignore); err == nil && !hadGitignore {
+			files = appendPolicyPackFile(files, gitignore)
+		}
+		if _, err := os.Stat(readme); err == nil && !hadReadme {
+			files = appendPolicyPackFile(files, readme)
+		}
+		return printJSON(newPolicyPackSummary(template.Name, cwd, pack.proj.Runtime.Name(), files))
+	}
 
-	printPolicyPackNextSteps(proj, root, args.generateOnly, opts)
+	printPolicyPackNextSteps(stdout, pack.proj, pack.root, args.generateOnly, opts)
 
 	return nil
 }
 
-func installPolicyPackDependencies(ctx context.Context,
-	proj *workspace.PolicyPackProject, projPath, root string) error {
-	// TODO[pulumi/pulumi#1334]: move to the language plugins so we don't have to hard code here.
-	if strings.EqualFold(proj.Runtime.Name(), "nodejs") {
-		fmt.Println("Installing dependencies...")
-		fmt.Println()
+// createPolicyPackDir resolves dir to an absolute path and creates it if it doesn't exist, reporting whether it did.
+func createPolicyPackDir(dir string) (string, bool, error) {
+	path, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false, fmt.Errorf("resolving the directory: %w", err)
+	}
 
-		bin, err := npm.Install(ctx, "", false /*production*/, os.Stdout, os.Stderr)
-		if err != nil {
-			return fmt.Errorf("`%s install` failed; rerun manually to try again.: %w", bin, err)
-		}
+	_, err = os.Stat(path)
+	created := os.IsNotExist(err)
+	if err = os.MkdirAll(path, os.ModePerm); err != nil {
+		return "", false, fmt.Errorf("creating the directory: %w", err)
+	}
+	return path, created, nil
+}
 
-		fmt.Println("Finished installing dependencies")
-		fmt.Println()
-	} else if strings.EqualFold(proj.Runtime.Name(), "python") {
-		const venvDir = "venv"
-		if err := python.InstallDependencies(ctx, root, venvDir, true /*showOutput*/); err != nil {
+// cleanUpPolicyPackDir handles err, which stopped a Policy Pack from being created in dir after dir was created for
+// it. If nothing was written to dir, it is removed. Otherwise, err is extended to say where the partial output is.
+func cleanUpPolicyPackDir(dir string, err error) error {
+	if entries, readErr := os.ReadDir(dir); readErr == nil && len(entries) == 0 {
+		if os.Remove(dir) == nil {
 			return err
 		}
+	}
+	return fmt.Errorf("%w\nThe partially created Policy Pack was left in %s", err, dir)
+}
+
+// runNewPolicyPacks creates a Policy Pack from each of the Policy Packs bundled by template, in the subdirectory of
+// dir with the same name.
+func runNewPolicyPacks(ctx context.Context, args newPolicyArgs, template workspace.PolicyPackTemplate, dir string,
+	variables map[string]string, progress policyPackProgress, stdout io.Writer, opts display.Options) error {
 
-		// Save project with venv info.
-		proj.Runtime.SetOption("virtualenv", venvDir)
-		if err := proj.Save(projPath); err != nil {
-			return fmt.Errorf("saving project at %s: %w", projPath, err)
+	packs := make([]workspace.PolicyPackTemplate, len(template.Packs))
+	for i, name := range template.Packs {
+		pack, err := workspace.LoadPolicyPackTemplate(filepath.Join(template.Dir, name))
+		if err != nil {
+			return fmt.Errorf("loading Policy Pack '%s' of template '%s': %w", name, template.Name, err)
 		}
+		packs[i] = pack
 	}
-	return nil
+	return createPolicyPacks(ctx, args, packs, template.Packs, dir, variables, progress, stdout, opts)
 }
 
-func printPolicyPackNextSteps(proj *workspace.PolicyPackProject, root string, generateOnly bool, opts display.Options) {
-	var commands []string
-	if generateOnly {
-		// We didn't install dependencies, so instruct the user to do so.
-		if strings.EqualFold(proj.Runtime.Name(), "nodejs") {
-			commands = append(commands, "npm install")
-		} else if strings.EqualFold(proj.Runtime.Name(), "python") {
-			commands = append(commands, pythonCommands()...)
+// createPolicyPacks creates a Policy Pack from each of packs in the subdirectory of dir with the corresponding name in
+// subdirs. The checks for existing files and --force apply to each subdirectory, and all of the subdirectories are
+// checked before anything is written.
+func createPolicyPacks(ctx context.Context, args newPolicyArgs, packs []workspace.PolicyPackTemplate,
+	subdirs []string, dir string, variables map[string]string, progress policyPackProgress, stdout io.Writer,
+	opts display.Options) error {
+
+	contract.Assert(len(packs) == len(subdirs))
+
+	// Leave out the files that --exclude names from each of the packs.
+	if len(args.excludes) > 0 {
+		excluded := make([]workspace.PolicyPackTemplate, len(packs))
+		for i, pack := range packs {
+			pack, cleanup, err := excludePolicyPackTemplateFiles(pack, args.excludes)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			excluded[i] = pack
 		}
+		packs = excluded
 	}
 
-	if len(commands) == 1 {
-		installMsg := fmt.Sprintf("To install dependencies for the Policy Pack, run `%s`", commands[0])
-		installMsg = colors.Highlight(installMsg, commands[0], colors.BrightBlue+colors.Bold)
-		fmt.Println(opts.Color.Colorize(installMsg))
-		fmt.Println()
+	// Resolve the parameters of each of the packs before anything is written. A value given for one pack is reused by
+	// the packs that follow it, so that a parameter that several packs share is only asked for once.
+	for _, pack := range packs {
+		var err error
+		variables, err = resolvePolicyPackTemplateParameters(pack.Parameters, variables,
+			policyPackParameterPrompt(args.yes, opts))
+		if err != nil {
+			return fmt.Errorf("Policy Pack '%s': %w", pack.Name, err)
+		}
 	}
 
-	if len(commands) > 1 {
-		fmt.Println("To install dependencies for the Policy Pack, run the following commands:")
-		fmt.Println()
-		for i, cmd := range commands {
-			cmdColors := colors.BrightBlue + colors.Bold + cmd + colors.Reset
-			fmt.Printf("   %d. %s\n", i+1, opts.Color.Colorize(cmdColors))
+	if !args.force {
+		for i, pack := range packs {
+			packDir := filepath.Join(dir, subdirs[i])
+			if err := errorIfPolicyPackDirectoryNotEmpty(packDir); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := workspace.CopyTemplateFilesDryRun(pack.Dir, packDir, ""); err != nil {
+				return err
+			}
 		}
-		fmt.Println()
 	}
 
-	usageCommandPreambles :=
-		[]string{"run the Policy Pack against a Pulumi program, in the directory of the Pulumi program run"}
-	usageCommands := []string{fmt.Sprintf("pulumi up --policy-pack %s", root)}
+	for i, pack := range packs {
+		packDir := filepath.Join(dir, subdirs[i])
+		if err := os.MkdirAll(packDir, 0700); err != nil {
+			return err
+		}
+		if _, err := createPolicyPack(ctx, args, pack, packDir, subdirs[i], "", variables, progress, stdout,
+			opts); err != nil {
+			return fmt.Errorf("Policy Pack '%s': %w", subdirs[i], err)
+		}
+	}
 
-	if strings.EqualFold(proj.Runtime.Name(), "nodejs") || strings.EqualFold(proj.Runtime.Name(), "python") {
-		usageCommandPreambles = append(usageCommandPreambles, "publish the Policy Pack, run")
-		usageCommands = append(usageCommands, "pulumi policy publish [org-name]")
+	fmt.Fprint(stdout, opts.Color.Colorize(renderCreatedPolicyPacks(subdirs, dir)))
+	return nil
+}
+
+// createdPolicyPack describes a Policy Pack that createPolicyPack created.
+type createdPolicyPack struct {
+	proj  *workspace.PolicyPackProject // The Policy Pack's project.
+	root  string                       // The Policy Pack's root directory.
+	name  string                       // The name of the Policy Pack.
+	added []string                     // With --from-existing, the full paths of the files that were added.
+}
+
+// createPolicyPack creates a Policy Pack from template in dir, or with --from-existing adds the template's missing
+// files to the Policy Pack in dir. It copies the template's files, updates the Policy Pack's metadata, writes its
+// .gitignore and README.md, installs its dependencies, and runs the template's hooks. label names the Policy Pack in
+// messages when it is one of several, and is empty otherwise. If source is not empty, the Policy Pack records that it
+// was created from the template given by source, for --upgrade. Any checks that the files can be written are left to
+// the caller.
+func createPolicyPack(ctx context.Context, args newPolicyArgs, template workspace.PolicyPackTemplate, dir, label,
+	source string, variables map[string]string, progress policyPackProgress, stdout io.Writer,
+	opts display.Options) (createdPolicyPack, error) {
+
+	var pack createdPolicyPack
+	templateNotFound := func(err error) error {
+		if os.IsNotExist(err) {
+			return newPolicyPackError(ErrTemplateNotFound,
+				fmt.Errorf("template '%s' not found: %w", args.templateNameOrURL, err))
+		}
+		return err
 	}
 
-	contract.Assert(len(usageCommandPreambles) == len(usageCommands))
+	// Record the existing files that --force is about to overwrite, so that they can be reported afterwards.
+	var overwrites []string
+	if args.force {
+		var err error
+		overwrites, err = policyPackOverwrites(template.Dir, dir, args.description, variables)
+		if err != nil && !os.IsNotExist(err) {
+			return pack, err
+		}
+	}
 
-	if len(usageCommands) == 1 {
-		usageMsg := fmt.Sprintf("Once you're done editing your Policy Pack, to %s `%s`", usageCommandPreambles[0],
-			usageCommands[0])
-		usageMsg = colors.Highlight(usageMsg, usageCommands[0], colors.BrightBlue+colors.Bold)
-		fmt.Println(opts.Color.Colorize(usageMsg))
-		fmt.Println()
-	} else {
-		fmt.Println("Once you're done editing your Policy Pack:")
-		fmt.Println()
-		for i, cmd := range usageCommands {
-			cmdColors := colors.BrightBlue + colors.Bold + cmd + colors.Reset
-			fmt.Printf("   * To %s `%s`\n", usageCommandPreambles[i], opts.Color.Colorize(cmdColors))
+	// Actually copy the files.
+	copyMessage := "Copying files..."
+	if label != "" {
+		copyMessage = fmt.Sprintf("Copying files for %s...", label)
+	}
+	var unresolved []string
+	if err := progress.run(copyMessage, false, func() error {
+		var err error
+		if args.fromExisting {
+			pack.added, unresolved, err = workspace.CopyNewTemplateFiles(
+				template.Dir, dir, "", args.description, variables)
+		} else {
+			unresolved, err = workspace.CopyTemplateFilesWithVariables(
+				template.Dir, dir, args.force, "", args.description, variables)
 		}
-		fmt.Println()
+		return err
+	}); err != nil {
+		return pack, templateNotFound(err)
 	}
-}
 
-// choosePolicyPackTemplate will prompt the user to choose amongst the available templates.
-func choosePolicyPackTemplate(templates []workspace.PolicyPackTemplate,
-	opts display.Options) (workspace.PolicyPackTemplate, error) {
+	// Several Policy Packs are reported together once they have all been created.
+	if label == "" {
+		if args.fromExisting {
+			if len(pack.added) == 0 {
+				warning := "warning: all of the template's files already exist; nothing was added to the Policy Pack"
+				fmt.Fprintln(stdout, opts.Color.Colorize(colors.SpecWarning+warning+colors.Reset))
+			} else if !args.jsonOut {
+				message := fmt.Sprintf("Added %d file(s) to the Policy Pack!", len(pack.added))
+				fmt.Fprintln(stdout, opts.Color.Colorize(colors.BrightGreen+message+colors.Reset))
+			}
+		} else if !args.jsonOut {
+			fmt.Fprintln(stdout, opts.Color.Colorize(colors.BrightGreen+"Created Policy Pack!"+colors.Reset))
+		}
+	}
 
-	const chooseTemplateErr = "no template selected; please use `pulumi policy new` to choose one"
-	if !opts.IsInteractive {
-		return workspace.PolicyPackTemplate{}, errors.New(chooseTemplateErr)
+	if source != "" {
+		if err := writePolicyPackTemplateRecord(dir, source, template, args.description, variables); err != nil {
+			return pack, err
+		}
 	}
 
-	// Customize the prompt a little bit (and disable color since it doesn't match our scheme).
-	surveycore.DisableColor = true
-	surveycore.QuestionIcon = ""
-	surveycore.SelectFocusIcon = opts.Color.Colorize(colors.BrightGreen + ">" + colors.Reset)
-	message := "\rPlease choose a template:"
-	message = opts.Color.Colorize(colors.SpecPrompt + message + colors.Reset)
+	if warning := renderUnresolvedPlaceholdersWarning(unresolved); warning != "" {
+		fmt.Fprintln(stdout, opts.Color.Colorize(warning))
+	}
 
-	options, optionToTemplateMap := policyTemplatesToOptionArrayAndMap(templates)
+	proj, projPath, root, err := readPolicyProjectFrom(dir)
+	if err != nil {
+		return pack, err
+	}
+	pack.proj, pack.root = proj, root
 
-	cmdutil.EndKeypadTransmitMode()
+	// Record the description, if one was given, and drop the template's manifest. An existing Policy Pack's metadata
+	// is left alone.
+	if args.description != "" && !args.fromExisting {
+		if err := setPolicyPackDescription(projPath, args.description); err != nil {
+			return pack, err
+		}
+	}
+	if proj.Template != nil && !args.fromExisting {
+		if err := removePolicyPackTemplateManifest(projPath); err != nil {
+			return pack, err
+		}
+	}
 
-	var option string
-	if err := survey.AskOne(&survey.Select{
-		Message:  message,
-		Options:  options,
-		PageSize: len(options),
-	}, &option, nil); err != nil {
-		return workspace.PolicyPackTemplate{}, errors.New(chooseTemplateErr)
+	// Templates name their Policy Packs after themselves unless PulumiPolicy.yaml says otherwise.
+	pack.name = proj.Name
+	if pack.name == "" {
+		pack.name = template.Name
 	}
-	return optionToTemplateMap[option], nil
-}
 
-// policyTemplatesToOptionArrayAndMap returns an array of option strings and a map of option strings to policy
-// templates. Each option string is made up of the template name and description with some padding in between.
-func policyTemplatesToOptionArrayAndMap(
-	templates []workspace.PolicyPackTemplate) ([]string, map[string]workspace.PolicyPackTemplate) {
+	// Write a .gitignore suitable for the runtime, unless the template ships its own, and likewise a README.md that
+	// lists the Policy Pack's policies.
+	if !args.noGitignore {
+		overwrote, err := writePolicyPackGitignore(template.Dir, root, proj.Runtime.Name(), args.force)
+		if err != nil {
+			return pack, err
+		}
+		if overwrote {
+			overwrites = append(overwrites, ".gitignore")
+		}
+	}
+	if args.readme {
+		overwrote, err := writePolicyPackReadme(template.Dir, root, pack.name, proj.Description, args.force)
+		if err != nil {
+			return pack, err
+		}
+		if overwrote {
+			overwrites = append(overwrites, "README.md")
+		}
+	}
 
-	// Find the longest name length. Used to add padding between the name and description.
-	maxNameLength := 0
-	for _, template := range templates {
-		if len(template.Name) > maxNameLength {
-			maxNameLength = len(template.Name)
+	// Overwriting files isn't an error, but make sure that it doesn't go unnoticed.
+	if label != "" {
+		for i, path := range overwrites {
+			overwrites[i] = filepath.Join(label, path)
 		}
 	}
+	if warning := renderPolicyPackOverwriteWarning(overwrites); warning != "" {
+		fmt.Fprint(stdout, opts.Color.Colorize(warning))
+	}
 
-	// Build the array and map.
-	var options []string
-	nameToTemplateMap := make(map[string]workspace.PolicyPackTemplate)
-	for _, template := range templates {
-		// Create the option string that combines the name, padding, and description.
-		option := fmt.Sprintf(fmt.Sprintf("%%%ds    %%s", -maxNameLength), template.Name, template.Description)
+	// Install dependencies.
+	if !args.generateOnly {
+		installMessage := "Installing dependencies..."
+		if label != "" {
+			installMessage = fmt.Sprintf("Installing dependencies for %s...", label)
+		}
+		if err := progress.run(installMessage, true, func() error {
+			return installPolicyPackDependencies(ctx, proj, projPath, root, stdout, opts.Color)
+		}); err != nil {
+			return pack, err
+		}
+	}
 
-		// Add it to the array and map.
-		options = append(options, option)
+	// Run the template's hooks, if they have been consented to.
+	remote := workspace.IsTemplateURL(args.templateNameOrURL)
+	runHooks, skipped := shouldRunPolicyPackHooks(template.Hooks, remote, args, opts, func(hooks []string) bool {
+		return confirmPolicyPackHooks(hooks, opts)
+	})
+	if skipped != "" {
+		fmt.Fprintln(stdout, opts.Color.Colorize(colors.SpecWarning+"warning: "+skipped+colors.Reset))
+	}
+	if runHooks {
+		if err := runPolicyPackHooks(ctx, root, stdout, opts.Color, template.Hooks); err != nil {
+			return pack, err
+		}
+	}
+
+	return pack, nil
+}
+
+// resolvePolicyPackTemplateParameters returns variables with a value for each of the template's parameters that it
+// doesn't already set: the value that prompt returns, if there is a prompt, or else the parameter's default. A
+// required parameter that is left without a value is an error.
+func resolvePolicyPackTemplateParameters(params []workspace.PolicyPackTemplateParameter,
+	variables map[string]string,
+	prompt func(param workspace.PolicyPackTemplateParameter) (string, error)) (map[string]string, error) {
+
+	resolved := make(map[string]string, len(variables)+len(params))
+	for key, value := range variables {
+		resolved[key] = value
+	}
+	for _, param := range params {
+		value, ok := resolved[param.Name]
+		if !ok {
+			value = param.Default
+			if prompt != nil {
+				var err error
+				if value, err = prompt(param); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if param.Required && value == "" {
+			return nil, fmt.Errorf("template parameter '%s' is required; pass --set %s=<value>", param.Name, param.Name)
+		}
+		resolved[param.Name] = value
+	}
+	return resolved, nil
+}
+
+// policyPackParameterPrompt returns a function that prompts for the value of a template parameter, or nil if prompts
+// are disabled or, with --yes, the parameters' defaults are used instead.
+func policyPackParameterPrompt(yes bool,
+	opts display.Options) func(param workspace.PolicyPackTemplateParameter) (string, error) {
+
+	if yes || !opts.IsInteractive {
+		return nil
+	}
+	return func(param workspace.PolicyPackTemplateParameter) (string, error) {
+		message := param.Prompt
+		if message == "" {
+			message = param.Name
+		}
+		var isValidFn func(value string) error
+		if param.Required {
+			isValidFn = func(value string) error {
+				if value == "" {
+					return errors.New("A value is required")
+				}
+				return nil
+			}
+		}
+		return promptForValue(false, message, param.Default, false, isValidFn, opts)
+	}
+}
+
+// validatePolicyPackExcludes returns an error if any of the given --exclude patterns is malformed.
+func validatePolicyPackExcludes(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --exclude pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// policyPackPathMatches returns true if the slash-separated path of a file relative to its template, or the file's
+// name, matches any of the given patterns.
+func policyPackPathMatches(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, rel); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, path.Base(rel)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// excludePolicyPackTemplateFiles returns a copy of template whose directory is a temporary copy of the template's
+// files without those excluded by patterns, along with a function that deletes the temporary copy. A directory that
+// is excluded is excluded with everything in it. The template's PulumiPolicy.yaml is always kept. Given no patterns,
+// the template is returned as it is.
+func excludePolicyPackTemplateFiles(template workspace.PolicyPackTemplate,
+	patterns []string) (workspace.PolicyPackTemplate, func(), error) {
+
+	if len(patterns) == 0 {
+		return template, func() {}, nil
+	}
+
+	dir, err := ioutil.TempDir("", "pulumi-policy-template-")
+	if err != nil {
+		return workspace.PolicyPackTemplate{}, nil, err
+	}
+	cleanup := func() {
+		contract.IgnoreError(os.RemoveAll(dir))
+	}
+
+	err = filepath.Walk(template.Dir, func(source string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(template.Dir, source)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if rel != "PulumiPolicy.yaml" && policyPackPathMatches(patterns, filepath.ToSlash(rel)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		dest := filepath.Join(dir, rel)
+		if info.IsDir() {
+			return os.Mkdir(dest, 0700)
+		}
+		b, err := ioutil.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dest, b, info.Mode().Perm()|0600)
+	})
+	if err != nil {
+		cleanup()
+		return workspace.PolicyPackTemplate{}, nil, fmt.Errorf("excluding files from template '%s': %w",
+			template.Name, err)
+	}
+
+	template.Dir = dir
+	return template, cleanup, nil
+}
+
+// writePolicyPackArchive writes the files that template would create in a new Policy Pack to a zip archive at path,
+// and returns the placeholders in them that were left unresolved. The files are written as they would be to a
+// directory, with the description, if any, recorded and the template's manifest dropped. An existing archive is
+// replaced only if force is true.
+func writePolicyPackArchive(template workspace.PolicyPackTemplate, path string, force bool, description string,
+	variables map[string]string) ([]string, error) {
+
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("archive '%s' already exists; rerun with --force to replace it", path)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "pulumi-policy-archive-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		contract.IgnoreError(os.RemoveAll(dir))
+	}()
+
+	unresolved, err := workspace.CopyTemplateFilesWithVariables(template.Dir, dir, true, "", description, variables)
+	if err != nil {
+		return nil, err
+	}
+	projPath := filepath.Join(dir, "PulumiPolicy.yaml")
+	proj, err := workspace.LoadPolicyPack(projPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Pulumi policy project located at %q: %w", projPath, err)
+	}
+	if description != "" {
+		if err := setPolicyPackDescription(projPath, description); err != nil {
+			return nil, err
+		}
+	}
+	if proj.Template != nil {
+		if err := removePolicyPackTemplateManifest(projPath); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("writing archive '%s': %w", path, err)
+	}
+	w := zip.NewWriter(f)
+	err = filepath.Walk(dir, func(source string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, source)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name, header.Method = filepath.ToSlash(rel), zip.Deflate
+		entry, err := w.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		_, err = entry.Write(b)
+		return err
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		contract.IgnoreError(os.Remove(path))
+		return nil, fmt.Errorf("writing archive '%s': %w", path, err)
+	}
+	return unresolved, nil
+}
+
+// renderCreatedPolicyPacks renders the message that lists the Policy Packs that were created in the subdirectories of
+// dir with the given names. The result contains colorization directives.
+func renderCreatedPolicyPacks(names []string, dir string) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%sCreated %d Policy Packs:%s\n", colors.BrightGreen, len(names), colors.Reset)
+	for _, name := range names {
+		fmt.Fprintf(b, "    %s\n", filepath.Join(dir, name))
+	}
+	b.WriteString("\nTo run a Policy Pack against a Pulumi program, in the directory of the Pulumi program run " +
+		"`pulumi up --policy-pack <directory>`\n")
+	return b.String()
+}
+
+// renderUnresolvedPlaceholdersWarning renders a warning that lists the placeholders that were left in a template's
+// files because no values were given for them, or returns "" if there are none. The result contains colorization
+// directives.
+func renderUnresolvedPlaceholdersWarning(unresolved []string) string {
+	if len(unresolved) == 0 {
+		return ""
+	}
+
+	placeholders := make([]string, len(unresolved))
+	for i, name := range unresolved {
+		placeholders[i] = "${" + name + "}"
+	}
+	return colors.SpecWarning + fmt.Sprintf("warning: the template contains placeholders that were not set: %s; "+
+		"rerun with --set name=value or edit the files by hand", strings.Join(placeholders, ", ")) + colors.Reset
+}
+
+// existingPolicyPackPath returns the path of the PulumiPolicy.yaml file of the Policy Pack in dir, or an error if dir
+// does not contain a Policy Pack. flag names the option that requires one, for the error.
+func existingPolicyPackPath(dir, flag string) (string, error) {
+	path, err := workspace.DetectPolicyPackPathFrom(dir)
+	if err != nil {
+		return "", fmt.Errorf("searching for an existing Policy Pack in %s: %w", dir, err)
+	}
+	if path == "" || filepath.Dir(path) != dir {
+		return "", fmt.Errorf("no existing Policy Pack found in %s: %s requires a PulumiPolicy.yaml file", dir, flag)
+	}
+	return path, nil
+}
+
+// policyPackTemplateRecordFile is the file in which a Policy Pack records the template it was created from and the
+// checksums of the template's framework files as they were generated.
+const policyPackTemplateRecordFile = ".pulumi-policy-template.json"
+
+// policyPackTemplateRecord is the contents of policyPackTemplateRecordFile.
+type policyPackTemplateRecord struct {
+	// Template is the name or URL of the template the Policy Pack was created from.
+	Template string `json:"template"`
+	// Files maps the slash-separated path of each framework file, relative to the Policy Pack, to the SHA-256
+	// checksum of its contents as they were generated.
+	Files map[string]string `json:"files"`
+}
+
+// policyPackChecksum returns the hex-encoded SHA-256 checksum of content.
+func policyPackChecksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// readPolicyPackTemplateRecord reads the template record of the Policy Pack in dir. It returns nil if the Policy Pack
+// has none, e.g. because its template has no framework files.
+func readPolicyPackTemplateRecord(dir string) (*policyPackTemplateRecord, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, policyPackTemplateRecordFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var record policyPackTemplateRecord
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", policyPackTemplateRecordFile, err)
+	}
+	return &record, nil
+}
+
+// savePolicyPackTemplateRecord writes the template record of the Policy Pack in dir.
+func savePolicyPackTemplateRecord(dir string, record policyPackTemplateRecord) error {
+	b, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, policyPackTemplateRecordFile), append(b, '\n'), 0600)
+}
+
+// writePolicyPackTemplateRecord records that the Policy Pack in dir was created from the template given by source,
+// along with the checksums of the template's framework files as they were generated.
+func writePolicyPackTemplateRecord(dir, source string, template workspace.PolicyPackTemplate, description string,
+	variables map[string]string) error {
+
+	files, err := workspace.PreviewTemplateFiles(template.Dir, dir, "", description, variables)
+	if err != nil {
+		return err
+	}
+	record := policyPackTemplateRecord{Template: source, Files: map[string]string{}}
+	for _, file := range files {
+		rel, err := filepath.Rel(dir, file.Path)
+		if err != nil {
+			return err
+		}
+		if rel := filepath.ToSlash(rel); isPolicyPackFrameworkFile(template.Framework, rel) {
+			record.Files[rel] = policyPackChecksum(file.Content)
+		}
+	}
+	return savePolicyPackTemplateRecord(dir, record)
+}
+
+// isPolicyPackFrameworkFile returns true if the file at the slash-separated path rel, relative to a Policy Pack, is
+// one of the framework files matched by patterns. PulumiPolicy.yaml, which describes the Policy Pack itself, never is.
+func isPolicyPackFrameworkFile(patterns []string, rel string) bool {
+	return rel != "PulumiPolicy.yaml" && policyPackPathMatches(patterns, rel)
+}
+
+// policyPackUpgradeAction is what upgrading a Policy Pack does with one of its template's framework files.
+type policyPackUpgradeAction string
+
+const (
+	// policyPackUpgradeCreate adds a framework file that the Policy Pack doesn't have.
+	policyPackUpgradeCreate policyPackUpgradeAction = "create"
+	// policyPackUpgradeUpdate replaces a framework file that wasn't changed since it was generated.
+	policyPackUpgradeUpdate policyPackUpgradeAction = "update"
+	// policyPackUpgradeUnchanged leaves a framework file that already matches the template alone.
+	policyPackUpgradeUnchanged policyPackUpgradeAction = "unchanged"
+	// policyPackUpgradeModified marks a framework file that was changed since it was generated, which is only
+	// replaced with --force.
+	policyPackUpgradeModified policyPackUpgradeAction = "modified"
+)
+
+// policyPackUpgradeFile describes what upgrading a Policy Pack does with one of its template's framework files.
+type policyPackUpgradeFile struct {
+	Path     string                  // The slash-separated path of the file, relative to the Policy Pack.
+	Action   policyPackUpgradeAction // What upgrading does with the file.
+	Existing []byte                  // The current content of the file, if it exists.
+	Content  []byte                  // The content of the file in the template.
+	Mode     os.FileMode             // The permissions the file is created with if it doesn't exist.
+}
+
+// planPolicyPackUpgrade returns what upgrading the Policy Pack in dir does with each of the template's framework
+// files, given the files the template would write to dir. A file that differs from the template counts as modified
+// unless it still has the checksum in the Policy Pack's template record, if any.
+func planPolicyPackUpgrade(files []workspace.TemplateFile, dir string, patterns []string,
+	record *policyPackTemplateRecord) ([]policyPackUpgradeFile, error) {
+
+	var plan []policyPackUpgradeFile
+	for _, file := range files {
+		rel, err := filepath.Rel(dir, file.Path)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		if !isPolicyPackFrameworkFile(patterns, rel) {
+			continue
+		}
+
+		upgrade := policyPackUpgradeFile{Path: rel, Existing: file.Existing, Content: file.Content, Mode: file.Mode}
+		switch {
+		case !file.Exists:
+			upgrade.Action = policyPackUpgradeCreate
+		case bytes.Equal(file.Existing, file.Content):
+			upgrade.Action = policyPackUpgradeUnchanged
+		case record != nil && record.Files[rel] == policyPackChecksum(file.Existing):
+			upgrade.Action = policyPackUpgradeUpdate
+		default:
+			upgrade.Action = policyPackUpgradeModified
+		}
+		plan = append(plan, upgrade)
+	}
+	return plan, nil
+}
+
+// upgradePolicyPack updates the framework files of the existing Policy Pack in dir from the template it was created
+// from, or the template given on the command line. Framework files that were changed since they were generated are
+// only replaced with --force; the Policy Pack's other files are never touched.
+func upgradePolicyPack(args newPolicyArgs, dir string, variables map[string]string, progress policyPackProgress,
+	stdout io.Writer, opts display.Options) error {
+
+	projPath, err := existingPolicyPackPath(dir, "--upgrade")
+	if err != nil {
+		return err
+	}
+	proj, err := workspace.LoadPolicyPack(projPath)
+	if err != nil {
+		return fmt.Errorf("failed to load Pulumi policy project located at %q: %w", projPath, err)
+	}
+	record, err := readPolicyPackTemplateRecord(dir)
+	if err != nil {
+		return err
+	}
+	if args.templateNameOrURL == "" {
+		if record == nil {
+			return fmt.Errorf("the Policy Pack in %s does not record the template it was created from; "+
+				"pass the template to upgrade from", dir)
+		}
+		args.templateNameOrURL = record.Template
+	}
+
+	retrieveMessage := "Downloading template..."
+	if args.offline {
+		retrieveMessage = "Loading template..."
+	}
+	var templates []workspace.PolicyPackTemplate
+	var cleanup func()
+	if err := progress.run(retrieveMessage, false, func() error {
+		var err error
+		templates, cleanup, err = retrievePolicyPackTemplates(args, os.Stderr, opts.Color)
+		return err
+	}); err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Prefer the templates in the Policy Pack's language.
+	if matching := filterPolicyPackTemplatesByLanguage(templates, proj.Runtime.Name()); len(matching) > 0 {
+		templates = matching
+	}
+	var template workspace.PolicyPackTemplate
+	switch len(templates) {
+	case 0:
+		return newPolicyPackError(ErrTemplateNotFound, errors.New("no templates"))
+	case 1:
+		template = templates[0]
+	default:
+		if template, err = choosePolicyPackTemplate(templates, opts); err != nil {
+			return err
+		}
+	}
+	if len(template.Framework) == 0 {
+		return fmt.Errorf("template '%s' does not declare any framework files to upgrade", template.Name)
+	}
+
+	variables, err = resolvePolicyPackTemplateParameters(template.Parameters, variables,
+		policyPackParameterPrompt(args.yes, opts))
+	if err != nil {
+		return err
+	}
+	files, err := workspace.PreviewTemplateFiles(template.Dir, dir, "", args.description, variables)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return newPolicyPackError(ErrTemplateNotFound,
+				fmt.Errorf("template '%s' not found: %w", args.templateNameOrURL, err))
+		}
+		return err
+	}
+	plan, err := planPolicyPackUpgrade(files, dir, template.Framework, record)
+	if err != nil {
+		return err
+	}
+
+	// Write the files, and record the checksums of those that now match the template. A modified file that is left
+	// alone keeps its recorded checksum, if any, so that it still counts as modified.
+	updated := policyPackTemplateRecord{Template: args.templateNameOrURL, Files: map[string]string{}}
+	if !workspace.IsTemplateURL(updated.Template) {
+		updated.Template = template.Name
+	}
+	for _, file := range plan {
+		if file.Action == policyPackUpgradeModified && !args.force {
+			if record != nil && record.Files[file.Path] != "" {
+				updated.Files[file.Path] = record.Files[file.Path]
+			}
+			continue
+		}
+		if file.Action != policyPackUpgradeUnchanged {
+			path := filepath.Join(dir, filepath.FromSlash(file.Path))
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+			// A new file gets the template file's permissions; an existing file keeps its own.
+			if err := ioutil.WriteFile(path, file.Content, file.Mode); err != nil {
+				return err
+			}
+		}
+		updated.Files[file.Path] = policyPackChecksum(file.Content)
+	}
+	if err := savePolicyPackTemplateRecord(dir, updated); err != nil {
+		return err
+	}
+
+	fmt.Fprint(stdout, opts.Color.Colorize(renderPolicyPackUpgrade(template, plan, dir, args.force)))
+	return nil
+}
+
+// renderPolicyPackUpgrade renders a summary of upgrading the Policy Pack in dir from template, with a diff of each
+// framework file that was replaced or, without force, left alone because it was modified. The result contains
+// colorization directives.
+func renderPolicyPackUpgrade(template workspace.PolicyPackTemplate, plan []policyPackUpgradeFile, dir string,
+	force bool) string {
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Upgrading the Policy Pack in %s from template '%s':\n\n", dir, template.Name)
+
+	written, skipped := 0, 0
+	for _, file := range plan {
+		switch {
+		case file.Action == policyPackUpgradeCreate:
+			written++
+			fmt.Fprintf(&b, "%s  create     %s%s\n", colors.SpecCreate, file.Path, colors.Reset)
+		case file.Action == policyPackUpgradeUnchanged:
+			fmt.Fprintf(&b, "  unchanged  %s\n", file.Path)
+		case file.Action == policyPackUpgradeUpdate:
+			written++
+			fmt.Fprintf(&b, "%s  update     %s%s\n", colors.SpecUpdate, file.Path, colors.Reset)
+			renderPolicyPackFileDiff(&b, string(file.Existing), string(file.Content))
+		case force:
+			written++
+			fmt.Fprintf(&b, "%s  overwrite  %s (changed since it was generated)%s\n",
+				colors.SpecUpdate, file.Path, colors.Reset)
+			renderPolicyPackFileDiff(&b, string(file.Existing), string(file.Content))
+		default:
+			skipped++
+			fmt.Fprintf(&b, "%s  skip       %s (changed since it was generated)%s\n",
+				colors.SpecWarning, file.Path, colors.Reset)
+			renderPolicyPackFileDiff(&b, string(file.Existing), string(file.Content))
+		}
+	}
+
+	fmt.Fprintf(&b, "\nUpgraded %d file(s); the Policy Pack's other files were left alone\n", written)
+	if skipped > 0 {
+		fmt.Fprintf(&b, "%swarning: %d file(s) were changed since they were generated and were not upgraded; "+
+			"merge the changes above by hand, or rerun with --force to replace them%s\n",
+			colors.SpecWarning, skipped, colors.Reset)
+	}
+	return b.String()
+}
+
+// describePolicyPackScaffold returns a line describing the Policy Pack that will be created from template in dir.
+func describePolicyPackScaffold(template workspace.PolicyPackTemplate, dir string) string {
+	description := fmt.Sprintf("Creating Policy Pack from template '%s'", template.Name)
+	if template.Runtime != "" {
+		description += fmt.Sprintf(" (%s)", template.Runtime)
+	}
+	return description + " in " + dir
+}
+
+// policyPackSummary is the JSON summary of a new Policy Pack printed by `pulumi policy new --json`.
+type policyPackSummary struct {
+	Template string   `json:"template"`
+	Dir      string   `json:"dir"`
+	Runtime  string   `json:"runtime"`
+	Files    []string `json:"files"`
+}
+
+// newPolicyPackSummary returns the summary of a Policy Pack created in root. The paths of the files are made
+// relative to root and sorted.
+func newPolicyPackSummary(template, root, runtime string, files []string) policyPackSummary {
+	relative := make([]string, 0, len(files))
+	for _, file := range files {
+		if rel, err := filepath.Rel(root, file); err == nil {
+			file = rel
+		}
+		relative = append(relative, filepath.ToSlash(file))
+	}
+	sort.Strings(relative)
+
+	return policyPackSummary{
+		Template: template,
+		Dir:      root,
+		Runtime:  runtime,
+		Files:    relative,
+	}
+}
+
+// appendPolicyPackFile appends path to files unless it is already present.
+func appendPolicyPackFile(files []string, path string) []string {
+	for _, file := range files {
+		if file == path {
+			return files
+		}
+	}
+	return append(files, path)
+}
+
+// setPolicyPackDescription sets the description in the PulumiPolicy.yaml file at path. The file is edited in place
+// so that its comments and the order of its keys are preserved.
+func setPolicyPackDescription(path, description string) error {
+	return editPolicyPackProject(path, func(project *yaml.Node) {
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: description}
+		for i := 0; i+1 < len(project.Content); i += 2 {
+			if project.Content[i].Value == "description" {
+				value.HeadComment = project.Content[i+1].HeadComment
+				value.LineComment = project.Content[i+1].LineComment
+				value.FootComment = project.Content[i+1].FootComment
+				project.Content[i+1] = value
+				return
+			}
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "description"}
+		project.Content = append(project.Content, key, value)
+	})
+}
+
+// removePolicyPackTemplateManifest removes the template section from the PulumiPolicy.yaml file at path, since it
+// describes the template rather than the Policy Pack created from it.
+func removePolicyPackTemplateManifest(path string) error {
+	return editPolicyPackProject(path, func(project *yaml.Node) {
+		for i := 0; i+1 < len(project.Content); i += 2 {
+			if project.Content[i].Value == "template" {
+				project.Content = append(project.Content[:i], project.Content[i+2:]...)
+				return
+			}
+		}
+	})
+}
+
+// editPolicyPackProject applies edit to the mapping in the PulumiPolicy.yaml file at path and writes the result back.
+// The file is edited in place so that its comments and the order of its keys are preserved.
+func editPolicyPackProject(path string, edit func(project *yaml.Node)) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s must contain a mapping", filepath.Base(path))
+	}
+	edit(doc.Content[0])
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// parsePolicyPackTemplateVariables parses the key=value pairs passed to --set.
+func parsePolicyPackTemplateVariables(pairs []string) (map[string]string, error) {
+	variables := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid --set value '%s': expected key=value", pair)
+		}
+		if err := workspace.ValidateTemplateVariableName(kv[0]); err != nil {
+			return nil, fmt.Errorf("invalid --set value '%s': %w", pair, err)
+		}
+		variables[kv[0]] = kv[1]
+	}
+	return variables, nil
+}
+
+// policyPackGitignores maps each supported runtime to the contents of the .gitignore written for its Policy Packs.
+var policyPackGitignores = map[string]string{
+	"nodejs": "/bin/\n/node_modules/\n",
+	"python": "*.pyc\n__pycache__/\nvenv/\n",
+	"go":     "/bin/\n",
+	"dotnet": "bin/\nobj/\n",
+}
+
+// writePolicyPackGitignore writes a .gitignore for the given runtime to root. Nothing is written if the template in
+// templateDir includes its own .gitignore or if the runtime is unknown. An existing .gitignore is only overwritten
+// if force is set, in which case writePolicyPackGitignore returns true.
+func writePolicyPackGitignore(templateDir, root, runtime string, force bool) (bool, error) {
+	contents, ok := policyPackGitignores[strings.ToLower(runtime)]
+	if !ok {
+		return false, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(templateDir, ".gitignore")); err == nil {
+		return false, nil
+	}
+
+	path := filepath.Join(root, ".gitignore")
+	_, statErr := os.Stat(path)
+	exists := statErr == nil
+	if exists && !force {
+		return false, nil
+	}
+
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		return false, fmt.Errorf("writing .gitignore: %w", err)
+	}
+	return exists, nil
+}
+
+// policyPackPolicy describes a policy defined by a Policy Pack's source code.
+type policyPackPolicy struct {
+	Name             string
+	EnforcementLevel string
+}
+
+var (
+	// policyPackDefinitionRegexp matches, in order of appearance, the parts of a TypeScript, JavaScript, or Python
+	// Policy Pack's source that define its policies: the start of the PolicyPack itself, including its name if it is
+	// passed by keyword, the name of each policy, and each enforcement level.
+	policyPackDefinitionRegexp = regexp.MustCompile(`\bPolicyPack\(\s*(?:name\s*=\s*["'][^"']*["'])?|` +
+		`\bname\s*[:=]\s*["']([^"']+)["']|` +
+		`\benforcement(?:Level\s*:\s*["']|_level\s*=\s*EnforcementLevel\.)(\w+)`)
+
+	// policyPackSourceExtensions are the extensions of the source files searched for policies.
+	policyPackSourceExtensions = map[string]bool{".ts": true, ".js": true, ".py": true}
+
+	// policyPackIgnoredDirs are the directories that are not searched for policies.
+	policyPackIgnoredDirs = map[string]bool{".git": true, "node_modules": true, "venv": true, "__pycache__": true}
+)
+
+// policyPackPolicies returns the policies defined by the source files of the Policy Pack at root, in the order in
+// which they are defined. Policies are found by inspecting the source text rather than by running the Policy Pack, so
+// the result is a best effort: a policy without its own enforcement level is given the Policy Pack's, or "advisory"
+// if the Policy Pack doesn't set one either.
+func policyPackPolicies(root string) ([]policyPackPolicy, error) {
+	var policies []policyPackPolicy
+	packLevel := ""
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && policyPackIgnoredDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !policyPackSourceExtensions[filepath.Ext(path)] || strings.HasSuffix(path, ".d.ts") {
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		// An enforcement level belongs to the policy or PolicyPack whose definition most recently preceded it.
+		current := -1
+		inPack := false
+		for _, match := range policyPackDefinitionRegexp.FindAllStringSubmatch(string(b), -1) {
+			switch {
+			case match[1] != "":
+				policies = append(policies, policyPackPolicy{Name: match[1]})
+				current, inPack = len(policies)-1, false
+			case match[2] != "":
+				level := strings.ToLower(match[2])
+				if inPack {
+					packLevel = level
+				} else if current >= 0 && policies[current].EnforcementLevel == "" {
+					policies[current].EnforcementLevel = level
+				}
+			default:
+				inPack = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if packLevel == "" {
+		packLevel = "advisory"
+	}
+	for i := range policies {
+		if policies[i].EnforcementLevel == "" {
+			policies[i].EnforcementLevel = packLevel
+		}
+	}
+	return policies, nil
+}
+
+// renderPolicyPackReadme renders a README.md for the named Policy Pack that lists its policies.
+func renderPolicyPackReadme(name string, description *string, policies []policyPackPolicy) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "# %s\n\n", name)
+	if description != nil && *description != "" {
+		fmt.Fprintf(b, "%s\n\n", *description)
+	}
+	b.WriteString("## Policies\n\n")
+	b.WriteString("| Policy | Enforcement level |\n")
+	b.WriteString("| --- | --- |\n")
+	for _, policy := range policies {
+		fmt.Fprintf(b, "| `%s` | %s |\n", policy.Name, policy.EnforcementLevel)
+	}
+	return b.String()
+}
+
+// writePolicyPackReadme writes a README.md that lists the policies of the Policy Pack at root. Nothing is written if
+// the template in templateDir includes its own README.md or if no policies can be found in the Policy Pack's source.
+// An existing README.md is only overwritten if force is set, in which case writePolicyPackReadme returns true.
+func writePolicyPackReadme(templateDir, root, name string, description *string, force bool) (bool, error) {
+	if _, err := os.Stat(filepath.Join(templateDir, "README.md")); err == nil {
+		return false, nil
+	}
+
+	path := filepath.Join(root, "README.md")
+	_, statErr := os.Stat(path)
+	exists := statErr == nil
+	if exists && !force {
+		return false, nil
+	}
+
+	// The README is a convenience, so a Policy Pack whose policies can't be found just goes without one.
+	policies, err := policyPackPolicies(root)
+	if err != nil || len(policies) == 0 {
+		return false, nil
+	}
+
+	contents := renderPolicyPackReadme(name, description, policies)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		return false, fmt.Errorf("writing README.md: %w", err)
+	}
+	return exists, nil
+}
+
+// policyPackOverwrites returns the paths, relative to dir, of the existing files in dir that copying the template in
+// templateDir would overwrite.
+func policyPackOverwrites(templateDir, dir, description string, variables map[string]string) ([]string, error) {
+	files, err := workspace.PreviewTemplateFiles(templateDir, dir, "", description, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	var overwrites []string
+	for _, file := range files {
+		if !file.Exists {
+			continue
+		}
+		rel, err := filepath.Rel(dir, file.Path)
+		if err != nil {
+			return nil, err
+		}
+		overwrites = append(overwrites, rel)
+	}
+	return overwrites, nil
+}
+
+// renderPolicyPackOverwriteWarning renders a warning that lists the files that --force overwrote, or returns "" if
+// none were.
+func renderPolicyPackOverwriteWarning(overwrites []string) string {
+	if len(overwrites) == 0 {
+		return ""
+	}
+
+	sorted := make([]string, len(overwrites))
+	copy(sorted, overwrites)
+	sort.Strings(sorted)
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%swarning: --force overwrote %d existing file(s):\n", colors.SpecWarning, len(sorted))
+	for _, path := range sorted {
+		fmt.Fprintf(b, "    %s\n", path)
+	}
+	b.WriteString(colors.Reset)
+	return b.String()
+}
+
+// policyPackProgress reports the phases of creating a Policy Pack.
+type policyPackProgress struct {
+	out   io.Writer // The writer that messages are printed to.
+	spin  bool      // Whether to show a spinner while a phase runs.
+	quiet bool      // Whether to run phases without reporting them.
+	color colors.Colorization
+}
+
+// run runs a phase, reporting the given message while it runs. The message is shown next to a spinner if spinners
+// are enabled and the phase does not write to the console itself; otherwise, it is printed to out on its own line.
+// Quiet progress reports nothing.
+func (p policyPackProgress) run(message string, writesOutput bool, phase func() error) error {
+	if p.quiet {
+		return phase()
+	}
+	if !p.spin || writesOutput {
+		fmt.Fprintln(p.out, p.color.Colorize(colors.SpecInfo+message+colors.Reset))
+		return phase()
+	}
+
+	spinner, ticker := cmdutil.NewSpinnerAndTicker(message+" ", nil, p.color, 8 /*timesPerSecond*/)
+	defer ticker.Stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- phase()
+	}()
+	for {
+		select {
+		case <-ticker.C:
+			spinner.Tick()
+		case err := <-done:
+			spinner.Reset()
+			return err
+		}
+	}
+}
+
+// policyPackInstallError is returned when the dependencies of a Policy Pack could not be installed. It records the
+// runtime of the Policy Pack so that failures can be diagnosed.
+type policyPackInstallError struct {
+	Runtime string
+	Err     error
+}
+
+func (e *policyPackInstallError) Error() string {
+	return fmt.Sprintf("installing %s dependencies failed; rerun manually to try again: %v", e.Runtime, e.Err)
+}
+
+func (e *policyPackInstallError) Unwrap() error {
+	return e.Err
+}
+
+func (e *policyPackInstallError) Is(target error) bool {
+	return target == ErrDependencyInstall
+}
+
+// policyPackInstaller installs the dependencies of the Policy Pack located at root, writing the output of the tools it
+// runs to stdout and os.Stderr.
+type policyPackInstaller func(ctx context.Context,
+	proj *workspace.PolicyPackProject, projPath, root string, stdout io.Writer) error
+
+// policyPackInstallers maps the name of a Policy Pack runtime to the installer for its dependencies.
+var policyPackInstallers = map[string]policyPackInstaller{
+	"nodejs": installNodejsPolicyPackDependencies,
+	"python": installPythonPolicyPackDependencies,
+	"go":     installGoPolicyPackDependencies,
+	"dotnet": installDotnetPolicyPackDependencies,
+}
+
+func installPolicyPackDependencies(ctx context.Context,
+	proj *workspace.PolicyPackProject, projPath, root string, stdout io.Writer, color colors.Colorization) error {
+	// TODO[pulumi/pulumi#1334]: move to the language plugins so we don't have to hard code here.
+	runtime := strings.ToLower(proj.Runtime.Name())
+	install, ok := policyPackInstallers[runtime]
+	if !ok {
+		return nil
+	}
+
+	fmt.Fprintln(stdout)
+
+	if err := install(ctx, proj, projPath, root, stdout); err != nil {
+		return &policyPackInstallError{Runtime: runtime, Err: err}
+	}
+
+	fmt.Fprintln(stdout, color.Colorize(colors.SpecInfo+"Finished installing dependencies"+colors.Reset))
+	fmt.Fprintln(stdout)
+	return nil
+}
+
+func installNodejsPolicyPackDependencies(ctx context.Context,
+	proj *workspace.PolicyPackProject, projPath, root string, stdout io.Writer) error {
+	bin, err := npm.Install(ctx, root, false /*production*/, stdout, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("`%s install` failed: %w", bin, err)
+	}
+	return nil
+}
+
+func installPythonPolicyPackDependencies(ctx context.Context,
+	proj *workspace.PolicyPackProject, projPath, root string, stdout io.Writer) error {
+	const venvDir = "venv"
+	err := python.InstallDependenciesWithWriters(ctx, root, venvDir, true /*showOutput*/, stdout, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	// Save project with venv info.
+	proj.Runtime.SetOption("virtualenv", venvDir)
+	if err := proj.Save(projPath); err != nil {
+		return fmt.Errorf("saving project at %s: %w", projPath, err)
+	}
+	return nil
+}
+
+func installGoPolicyPackDependencies(ctx context.Context,
+	proj *workspace.PolicyPackProject, projPath, root string, stdout io.Writer) error {
+	return runPolicyPackInstallCommand(ctx, root, stdout, "go", "mod", "download")
+}
+
+func installDotnetPolicyPackDependencies(ctx context.Context,
+	proj *workspace.PolicyPackProject, projPath, root string, stdout io.Writer) error {
+	return runPolicyPackInstallCommand(ctx, root, stdout, "dotnet", "restore")
+}
+
+// shouldRunPolicyPackHooks reports whether a template's hooks should run and, if they should not, why. Hooks run
+// arbitrary commands, so they require consent. For templates given by URL, consent must be given explicitly with
+// --allow-hooks or at the prompt; --yes is not enough. Hooks never run with --generate-only.
+func shouldRunPolicyPackHooks(hooks []string, remote bool, args newPolicyArgs, opts display.Options,
+	confirm func(hooks []string) bool) (bool, string) {
+
+	switch {
+	case len(hooks) == 0:
+		return false, ""
+	case args.generateOnly:
+		return false, "skipping the template's hooks because of --generate-only"
+	case args.allowHooks, args.yes && !remote:
+		return true, ""
+	case opts.IsInteractive && !args.yes:
+		if confirm(hooks) {
+			return true, ""
+		}
+		return false, "skipping the template's hooks because they were not confirmed"
+	default:
+		return false, "skipping the hooks of a template given by URL; rerun with --allow-hooks to run them"
+	}
+}
+
+// confirmPolicyPackHooks asks the user whether to run the given hooks.
+func confirmPolicyPackHooks(hooks []string, opts display.Options) bool {
+	surveycore.DisableColor = true
+	surveycore.QuestionIcon = ""
+	surveycore.SelectFocusIcon = opts.Color.Colorize(colors.BrightGreen + ">" + colors.Reset)
+	prompt := opts.Color.Colorize(colors.SpecWarning+"warning"+colors.Reset+": ") +
+		"The template wants to run the following commands in the Policy Pack's directory:\n  " +
+		strings.Join(hooks, "\n  ") + "\nRun them?"
+	cmdutil.EndKeypadTransmitMode()
+
+	confirm := false
+	if err := survey.AskOne(&survey.Confirm{Message: prompt}, &confirm, nil); err != nil {
+		return false
+	}
+	return confirm
+}
+
+// runPolicyPackHooks runs each of the given hooks in the Policy Pack's root directory, streaming their output to stdout
+// and os.Stderr. A hook is a program followed by its arguments, which are split like a shell's words, so that an
+// argument that contains whitespace can be quoted.
+func runPolicyPackHooks(ctx context.Context, root string, stdout io.Writer, color colors.Colorization,
+	hooks []string) error {
+
+	for _, hook := range hooks {
+		fields, err := shlex.Split(hook)
+		if err != nil {
+			return fmt.Errorf("parsing the template's hook `%s`: %w", hook, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		fmt.Fprintln(stdout, color.Colorize(colors.SpecInfo+"Running `"+hook+"`..."+colors.Reset))
+		if err := runPolicyPackInstallCommand(ctx, root, stdout, fields[0], fields[1:]...); err != nil {
+			return fmt.Errorf("running the template's hooks: %w", err)
+		}
+	}
+	return nil
+}
+
+// runPolicyPackInstallCommand runs the given program with args in the Policy Pack's root directory, streaming its
+// output to stdout and os.Stderr.
+func runPolicyPackInstallCommand(ctx context.Context, root string, stdout io.Writer, program string,
+	args ...string) error {
+	bin, err := executable.FindExecutable(program)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = root
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("`%s %s` failed: %w", program, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+func printPolicyPackNextSteps(w io.Writer, proj *workspace.PolicyPackProject, root string, generateOnly bool,
+	opts display.Options) {
+
+	var commands []string
+	if generateOnly {
+		// We didn't install dependencies, so instruct the user to do so.
+		if strings.EqualFold(proj.Runtime.Name(), "nodejs") {
+			commands = append(commands, "npm install")
+		} else if strings.EqualFold(proj.Runtime.Name(), "python") {
+			commands = append(commands, pythonCommands()...)
+		} else if strings.EqualFold(proj.Runtime.Name(), "go") {
+			commands = append(commands, "go mod download")
+		} else if strings.EqualFold(proj.Runtime.Name(), "dotnet") {
+			commands = append(commands, "dotnet restore")
+		}
+	}
+
+	if len(commands) == 1 {
+		installMsg := fmt.Sprintf("To install dependencies for the Policy Pack, run `%s`", commands[0])
+		installMsg = colors.Highlight(installMsg, commands[0], colors.BrightBlue+colors.Bold)
+		fmt.Fprintln(w, opts.Color.Colorize(installMsg))
+		fmt.Fprintln(w)
+	}
+
+	if len(commands) > 1 {
+		fmt.Fprintln(w, opts.Color.Colorize("To install dependencies for the Policy Pack, run the following commands:"))
+		fmt.Fprintln(w)
+		for i, cmd := range commands {
+			cmdColors := colors.BrightBlue + colors.Bold + cmd + colors.Reset
+			fmt.Fprintln(w, opts.Color.Colorize(fmt.Sprintf("   %d. %s", i+1, cmdColors)))
+		}
+		fmt.Fprintln(w)
+	}
+
+	usageCommandPreambles :=
+		[]string{"run the Policy Pack against a Pulumi program, in the directory of the Pulumi program run"}
+	usageCommands := []string{fmt.Sprintf("pulumi up --policy-pack %s", root)}
+
+	if strings.EqualFold(proj.Runtime.Name(), "nodejs") || strings.EqualFold(proj.Runtime.Name(), "python") {
+		usageCommandPreambles = append(usageCommandPreambles, "publish the Policy Pack, run")
+		usageCommands = append(usageCommands, "pulumi policy publish [org-name]")
+	}
+
+	contract.Assert(len(usageCommandPreambles) == len(usageCommands))
+
+	if len(usageCommands) == 1 {
+		usageMsg := fmt.Sprintf("Once you're done editing your Policy Pack, to %s `%s`", usageCommandPreambles[0],
+			usageCommands[0])
+		usageMsg = colors.Highlight(usageMsg, usageCommands[0], colors.BrightBlue+colors.Bold)
+		fmt.Fprintln(w, opts.Color.Colorize(usageMsg))
+		fmt.Fprintln(w)
+	} else {
+		fmt.Fprintln(w, opts.Color.Colorize("Once you're done editing your Policy Pack:"))
+		fmt.Fprintln(w)
+		for i, cmd := range usageCommands {
+			cmdColors := colors.BrightBlue + colors.Bold + cmd + colors.Reset
+			fmt.Fprintln(w, opts.Color.Colorize(fmt.Sprintf("   * To %s `%s`", usageCommandPreambles[i], cmdColors)))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// renderPolicyPackPreview renders the files that generating a Policy Pack from the template would write to root. For
+// each file that already exists, a line diff of its current content against the template's content is included. The
+// result contains colorization directives.
+func renderPolicyPackPreview(
+	template workspace.PolicyPackTemplate, files []workspace.TemplateFile, root string) string {
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Creating a Policy Pack from template '%s' in %s would write the following files:\n\n",
+		template.Name, root)
+
+	for _, file := range files {
+		path := file.Path
+		if rel, err := filepath.Rel(root, file.Path); err == nil {
+			path = rel
+		}
+
+		switch {
+		case !file.Exists:
+			fmt.Fprintf(&b, "%s  create     %s%s\n", colors.SpecCreate, path, colors.Reset)
+		case bytes.Equal(file.Existing, file.Content):
+			fmt.Fprintf(&b, "  unchanged  %s\n", path)
+		default:
+			fmt.Fprintf(&b, "%s  overwrite  %s%s\n", colors.SpecUpdate, path, colors.Reset)
+			renderPolicyPackFileDiff(&b, string(file.Existing), string(file.Content))
+		}
+	}
+
+	b.WriteString("\nNo files were written; rerun the command without --preview to create the Policy Pack\n")
+	return b.String()
+}
+
+// renderPolicyPackFileDiff renders a line diff between the old and new content of a file. Removed lines are
+// prefixed with `-` and added lines with `+`.
+func renderPolicyPackFileDiff(b *strings.Builder, old, new string) {
+	differ := diffmatchpatch.New()
+	differ.DiffTimeout = 0
+
+	hashed1, hashed2, lineArray := differ.DiffLinesToChars(old, new)
+	diffs := differ.DiffCharsToLines(differ.DiffMain(hashed1, hashed2, false), lineArray)
+
+	for _, diff := range diffs {
+		prefix, color := " ", colors.Reset
+		switch diff.Type {
+		case diffmatchpatch.DiffInsert:
+			prefix, color = "+", colors.SpecCreate
+		case diffmatchpatch.DiffDelete:
+			prefix, color = "-", colors.SpecDelete
+		}
+
+		for _, line := range strings.SplitAfter(diff.Text, "\n") {
+			if line == "" {
+				continue
+			}
+			fmt.Fprintf(b, "%s      %s %s%s\n", color, prefix, strings.TrimSuffix(line, "\n"), colors.Reset)
+		}
+	}
+}
+
+// parsePolicyTemplateProxy parses the value of --proxy. An empty value results in a nil URL, which means that the
+// proxy configured by the environment, if any, is used.
+func parsePolicyTemplateProxy(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: expected a URL such as http://proxy.example.com:8080", raw)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return u, nil
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: the scheme must be http, https, or socks5", raw)
+	}
+}
+
+// policyTemplateToken returns the token to authenticate the download of the template at the given URL with, and the
+// host to send it to. No token is sent unless the template is given by URL, so that the token doesn't reach the
+// templates-policy repo or any other host.
+func policyTemplateToken(templateNameOrURL string) (string, string) {
+	if !workspace.IsTemplateURL(templateNameOrURL) {
+		return "", ""
+	}
+	u, err := url.Parse(templateNameOrURL)
+	if err != nil || u.Hostname() == "" {
+		return "", ""
+	}
+
+	host := u.Hostname()
+	if token := os.Getenv(pulumiTemplateTokenEnvVar); token != "" {
+		return token, host
+	}
+	if host == gitutil.GitHubHostName {
+		if token := os.Getenv(githubTokenEnvVar); token != "" {
+			return token, host
+		}
+	}
+	return "", ""
+}
+
+// retrievePolicyPackTemplates returns the available policy templates. Templates found in the directories on the
+// template search path come first and shadow templates with the same name from the templates-policy repo. If the
+// requested template is found on the search path, the repo is not retrieved at all. If there is no template with the
+// requested name, the templates whose names start with it are returned instead. The returned function cleans up the
+// retrieved repo and must be called once the templates are no longer needed.
+func retrievePolicyPackTemplates(args newPolicyArgs, stderr io.Writer,
+	color colors.Colorization) ([]workspace.PolicyPackTemplate, func(), error) {
+
+	searched, err := searchPolicyPackTemplates(args.templateSearchPaths)
+	if err != nil {
+		return nil, nil, err
+	}
+	local := searched
+	if args.templateNameOrURL != "" {
+		for _, template := range local {
+			if template.Name == args.templateNameOrURL {
+				return []workspace.PolicyPackTemplate{template}, func() {}, nil
+			}
+		}
+		local = nil
+	}
+
+	// Download through the proxy given by --proxy, or else the one configured by the environment.
+	proxy, err := parsePolicyTemplateProxy(args.proxy)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := gitutil.HTTPOptions{Proxy: proxy}
+
+	// Trust the certificate authorities in PULUMI_CA_BUNDLE, if it is set, as well as the system's.
+	if bundle := os.Getenv(pulumiCABundleEnvVar); bundle != "" {
+		if opts.RootCAs, err = gitutil.LoadCABundle(bundle); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", pulumiCABundleEnvVar, err)
+		}
+	}
+
+	// Authenticate with the token in PULUMI_TEMPLATE_TOKEN, or with GITHUB_TOKEN for github.com, if either is set.
+	opts.Token, opts.TokenHost = policyTemplateToken(args.templateNameOrURL)
+
+	// Retrieve the templates-policy repo. If it has no template with the requested name, use all of the templates in
+	// the repo that was searched so that they can be matched by prefix.
+	repo, err := workspace.RetrieveTemplatesWithOptions(args.templateNameOrURL, args.offline,
+		workspace.TemplateKindPolicyPack, opts)
+	var notFound *workspace.TemplateNotFoundError
+	if errors.As(err, &notFound) {
+		local = searched
+		repo, err = notFound.Repository, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := policyPackTemplateCleanup(repo, args.keepTemp, stderr, color)
+
+	// Make sure the cached templates haven't changed since they were downloaded, as they can't be downloaded again.
+	if args.offline && !args.noVerify {
+		if err := repo.VerifyChecksums(); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("%w\nRun without --offline to download the templates again, "+
+				"or pass --no-verify to use them as they are", err)
+		}
+	}
+
+	// List the templates from the repo.
+	builtins, err := repo.PolicyTemplates()
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	names := make(map[string]bool, len(local))
+	for _, template := range local {
+		names[template.Name] = true
+	}
+	templates := local
+	for _, template := range builtins {
+		if !names[template.Name] {
+			templates = append(templates, template)
+		}
+	}
+
+	if notFound != nil {
+		matches := policyPackTemplatesWithPrefix(templates, args.templateNameOrURL)
+		switch {
+		case len(matches) == 0:
+			cleanup()
+			return nil, nil, newPolicyPackError(ErrTemplateNotFound, notFound)
+		case len(matches) > 1 && !args.interactive && !args.listTemplates:
+			// Without prompts, there is no way to choose between the matches.
+			cleanup()
+			return nil, nil, newAmbiguousPolicyPackTemplateError(args.templateNameOrURL, matches)
+		}
+		templates = matches
+	}
+	return templates, cleanup, nil
+}
+
+// policyPackTemplateCleanup returns a function that deletes the retrieved templates repo. With --keep-temp, a repo
+// that would have been deleted is kept instead, and its location is written to w so its raw files can be inspected.
+func policyPackTemplateCleanup(repo workspace.TemplateRepository, keep bool, w io.Writer,
+	color colors.Colorization) func() {
+
+	return func() {
+		if keep && repo.ShouldDelete {
+			fmt.Fprintln(w, color.Colorize(colors.SpecInfo+"Kept the retrieved templates in "+repo.Root+colors.Reset))
+			return
+		}
+		contract.IgnoreError(repo.Delete())
+	}
+}
+
+// policyPackTemplatesWithPrefix returns the templates whose names start with prefix, ignoring case.
+func policyPackTemplatesWithPrefix(
+	templates []workspace.PolicyPackTemplate, prefix string) []workspace.PolicyPackTemplate {
+
+	prefix = strings.ToLower(prefix)
+	var matches []workspace.PolicyPackTemplate
+	for _, template := range templates {
+		if strings.HasPrefix(strings.ToLower(template.Name), prefix) {
+			matches = append(matches, template)
+		}
+	}
+	return matches
+}
+
+// newAmbiguousPolicyPackTemplateError returns an error for a template name that is the prefix of several templates.
+func newAmbiguousPolicyPackTemplateError(prefix string, matches []workspace.PolicyPackTemplate) error {
+	names := make([]string, len(matches))
+	for i, template := range matches {
+		names[i] = template.Name
+	}
+	sort.Strings(names)
+
+	message := fmt.Sprintf("template '%s' not found; several templates start with it:\n", prefix)
+	for _, name := range names {
+		message += fmt.Sprintf("\t%s\n", name)
+	}
+	return errors.New(message + "rerun with the full name of the template you want")
+}
+
+// searchPolicyPackTemplates returns the policy templates in the given directories, tagged with the directory they were
+// found in. If several directories contain a template with the same name, the first one wins.
+func searchPolicyPackTemplates(paths []string) ([]workspace.PolicyPackTemplate, error) {
+	var result []workspace.PolicyPackTemplate
+	names := make(map[string]bool)
+	for _, path := range paths {
+		repo := workspace.TemplateRepository{Root: path, SubDirectory: path}
+		templates, err := repo.PolicyTemplates()
+		if err != nil {
+			return nil, fmt.Errorf("searching '%s' for templates: %w", path, err)
+		}
+		for _, template := range templates {
+			if names[template.Name] {
+				continue
+			}
+			names[template.Name] = true
+			template.Source = path
+			result = append(result, template)
+		}
+	}
+	return result, nil
+}
+
+// policyPackTemplateDescription returns the description of a template, tagged with its source if it is not a built-in
+// template.
+func policyPackTemplateDescription(template workspace.PolicyPackTemplate) string {
+	if template.Source == "" {
+		return template.Description
+	}
+	return fmt.Sprintf("%s [%s]", template.Description, template.Source)
+}
+
+// listPolicyPackTemplates prints the available policy templates, honoring --offline and --language.
+func listPolicyPackTemplates(args newPolicyArgs, opts display.Options) error {
+	templates, cleanup, err := retrievePolicyPackTemplates(args, os.Stderr, opts.Color)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// List the templates for any of the languages, each of which must have some.
+	if languages := parsePolicyPackLanguages(args.language); len(languages) > 0 {
+		matched := map[string]bool{}
+		for _, language := range languages {
+			matching := filterPolicyPackTemplatesByLanguage(templates, language)
+			if len(matching) == 0 {
+				return fmt.Errorf("no templates found for language '%s'", language)
+			}
+			for _, template := range matching {
+				matched[template.Dir] = true
+			}
+		}
+		var filtered []workspace.PolicyPackTemplate
+		for _, template := range templates {
+			if matched[template.Dir] {
+				filtered = append(filtered, template)
+			}
+		}
+		templates = filtered
+	}
+	if len(templates) == 0 {
+		return errors.New("no templates")
+	}
+
+	fmt.Print(opts.Color.Colorize(renderPolicyPackTemplateList(templates)))
+	return nil
+}
+
+// renderPolicyPackTemplateList renders the names and descriptions of the given templates in aligned columns, sorted
+// by name.
+func renderPolicyPackTemplateList(templates []workspace.PolicyPackTemplate) string {
+	sorted := make([]workspace.PolicyPackTemplate, len(templates))
+	copy(sorted, templates)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	maxNameLength := 0
+	for _, template := range sorted {
+		if len(template.Name) > maxNameLength {
+			maxNameLength = len(template.Name)
+		}
+	}
+
+	var b strings.Builder
+	for _, template := range sorted {
+		fmt.Fprintf(&b, "%s%-*s%s    %s\n",
+			colors.SpecSubHeadline, maxNameLength, template.Name, colors.Reset, policyPackTemplateDescription(template))
+	}
+	return b.String()
+}
+
+// choosePolicyPackTemplate will prompt the user to choose amongst the available templates. If several templates are
+// variants of the same pack in different languages (e.g. `aws-typescript` and `aws-python`), the user first chooses
+// the pack and then its language.
+func choosePolicyPackTemplate(templates []workspace.PolicyPackTemplate,
+	opts display.Options) (workspace.PolicyPackTemplate, error) {
+
+	const chooseTemplateErr = "no template selected; please use `pulumi policy new` to choose one"
+	if !opts.IsInteractive {
+		return workspace.PolicyPackTemplate{}, errors.New(chooseTemplateErr)
+	}
+
+	// Customize the prompt a little bit (and disable color since it doesn't match our scheme).
+	surveycore.DisableColor = true
+	surveycore.QuestionIcon = ""
+	surveycore.SelectFocusIcon = opts.Color.Colorize(colors.BrightGreen + ">" + colors.Reset)
+
+	cmdutil.EndKeypadTransmitMode()
+
+	groups := groupPolicyPackTemplates(templates)
+	if len(groups) == len(templates) {
+		options, optionToTemplateMap := policyTemplatesToOptionArrayAndMap(templates, policyPackTemplateTags, opts)
+		option, err := askPolicyPackOption("Please choose a template:", options,
+			policyPackOptionFilter(optionToTemplateMap), opts)
+		if err != nil {
+			return workspace.PolicyPackTemplate{}, errors.New(chooseTemplateErr)
+		}
+		return optionToTemplateMap[option], nil
+	}
+
+	// First choose the pack. Each pack is described by its first variant and tagged with the languages it is
+	// available in.
+	packs := make([]workspace.PolicyPackTemplate, 0, len(groups))
+	for name, variants := range groups {
+		packs = append(packs, workspace.PolicyPackTemplate{Name: name, Description: variants[0].Description})
+	}
+	packLanguages := func(pack workspace.PolicyPackTemplate) []string {
+		var languages []string
+		for _, language := range policyPackLanguages(groups[pack.Name]) {
+			if language != "" {
+				languages = append(languages, language)
+			}
+		}
+		return languages
+	}
+	options, optionToPackMap := policyTemplatesToOptionArrayAndMap(packs, packLanguages, opts)
+	option, err := askPolicyPackOption("Please choose a Policy Pack:", options,
+		policyPackOptionFilter(optionToPackMap), opts)
+	if err != nil {
+		return workspace.PolicyPackTemplate{}, errors.New(chooseTemplateErr)
+	}
+	variants := groups[optionToPackMap[option].Name]
+	if len(variants) == 1 {
+		return variants[0], nil
+	}
+
+	// Then choose the language.
+	languageToTemplateMap := make(map[string]workspace.PolicyPackTemplate, len(variants))
+	for _, template := range variants {
+		_, language := policyPackTemplateVariant(template)
+		languageToTemplateMap[language] = template
+	}
+	language, err := askPolicyPackOption("Please choose a language:", policyPackLanguages(variants), nil, opts)
+	if err != nil {
+		return workspace.PolicyPackTemplate{}, errors.New(chooseTemplateErr)
+	}
+	return languageToTemplateMap[language], nil
+}
+
+// policyPackLanguages returns the sorted languages of the given variants of a Policy Pack.
+func policyPackLanguages(variants []workspace.PolicyPackTemplate) []string {
+	languages := make([]string, 0, len(variants))
+	for _, template := range variants {
+		_, language := policyPackTemplateVariant(template)
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// choosePolicyPackLanguageTemplates returns a template for each of the given languages. The templates are variants
+// of the same Policy Pack, which the user is asked to choose if several are available in all of the languages. It is
+// an error if there is no template for one of the languages.
+func choosePolicyPackLanguageTemplates(templates []workspace.PolicyPackTemplate, languages []string,
+	opts display.Options) ([]workspace.PolicyPackTemplate, error) {
+
+	matches := make([][]workspace.PolicyPackTemplate, len(languages))
+	var missing []string
+	for i, language := range languages {
+		matches[i] = filterPolicyPackTemplatesByLanguage(templates, language)
+		if len(matches[i]) == 0 {
+			missing = append(missing, "'"+language+"'")
+		}
+	}
+	switch len(missing) {
+	case 0:
+	case 1:
+		return nil, newPolicyPackError(ErrTemplateNotFound,
+			fmt.Errorf("no templates found for language %s", missing[0]))
+	default:
+		return nil, newPolicyPackError(ErrTemplateNotFound,
+			fmt.Errorf("no templates found for languages %s", strings.Join(missing, ", ")))
+	}
+
+	// variant returns the variant of the named pack among the given templates, if there is one.
+	variant := func(candidates []workspace.PolicyPackTemplate, pack string) (workspace.PolicyPackTemplate, bool) {
+		for _, template := range candidates {
+			if name, _ := policyPackTemplateVariant(template); name == pack {
+				return template, true
+			}
+		}
+		return workspace.PolicyPackTemplate{}, false
+	}
+
+	// Find the packs that are available in all of the languages.
+	var packs []workspace.PolicyPackTemplate
+	seen := map[string]bool{}
+	for _, template := range matches[0] {
+		name, _ := policyPackTemplateVariant(template)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		common := true
+		for _, candidates := range matches[1:] {
+			if _, ok := variant(candidates, name); !ok {
+				common = false
+				break
+			}
+		}
+		if common {
+			packs = append(packs, workspace.PolicyPackTemplate{Name: name, Description: template.Description})
+		}
+	}
+
+	var pack workspace.PolicyPackTemplate
+	switch len(packs) {
+	case 0:
+		return nil, fmt.Errorf("no template is available in all of the languages %s", strings.Join(languages, ", "))
+	case 1:
+		pack = packs[0]
+	default:
+		var err error
+		if pack, err = choosePolicyPackTemplate(packs, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]workspace.PolicyPackTemplate, len(languages))
+	for i, candidates := range matches {
+		template, ok := variant(candidates, pack.Name)
+		contract.Assert(ok)
+		if len(template.Packs) > 0 {
+			return nil, fmt.Errorf("template '%s' bundles several Policy Packs and cannot be used with several "+
+				"languages", template.Name)
+		}
+		result[i] = template
+	}
+	return result, nil
+}
+
+// askPolicyPackOption prompts the user to choose one of the given options. Typing narrows the options down to those
+// accepted by the filter, or to those whose text contains what was typed if the filter is nil.
+func askPolicyPackOption(message string, options []string, filter func(filter, option string) bool,
+	opts display.Options) (string, error) {
+
+	message = opts.Color.Colorize(colors.SpecPrompt + "\r" + message + colors.Reset)
+
+	prompt := &survey.Select{
+		Message:  message,
+		Options:  options,
+		PageSize: len(options),
+	}
+	if filter != nil {
+		prompt.FilterFn = func(text string, options []string) []string {
+			var matches []string
+			for _, option := range options {
+				if filter(text, option) {
+					matches = append(matches, option)
+				}
+			}
+			return matches
+		}
+	}
+
+	var option string
+	err := survey.AskOne(prompt, &option, nil)
+	return option, err
+}
+
+// policyPackOptionFilter returns a filter that matches each option against the name and description of the template
+// it stands for, rather than against its rendered (and possibly colorized) text.
+func policyPackOptionFilter(
+	optionToTemplateMap map[string]workspace.PolicyPackTemplate) func(filter, option string) bool {
+
+	return func(filter, option string) bool {
+		template, ok := optionToTemplateMap[option]
+		return ok && policyPackTemplateMatchesFilter(template, filter)
+	}
+}
+
+// policyPackTemplateMatchesFilter returns true if the template's name or description contains the filter, ignoring
+// case. An empty filter matches every template.
+func policyPackTemplateMatchesFilter(template workspace.PolicyPackTemplate, filter string) bool {
+	filter = strings.ToLower(filter)
+	return strings.Contains(strings.ToLower(template.Name), filter) ||
+		strings.Contains(strings.ToLower(template.Description), filter)
+}
+
+// policyPackTemplateLanguages are the languages that may appear as the final dash-separated part of a policy
+// template's name.
+var policyPackTemplateLanguages = []string{"csharp", "dotnet", "fsharp", "go", "javascript", "python", "typescript"}
+
+// policyPackTemplateVariant splits a template's name into the name of the pack and the language it is written in,
+// e.g. `aws-typescript` into `aws` and `typescript`. If the name does not end in a language, the whole name is the
+// pack and the template's runtime is its language.
+func policyPackTemplateVariant(template workspace.PolicyPackTemplate) (string, string) {
+	if i := strings.LastIndex(template.Name, "-"); i > 0 {
+		suffix := template.Name[i+1:]
+		if strings.EqualFold(suffix, template.Runtime) {
+			return template.Name[:i], suffix
+		}
+		for _, language := range policyPackTemplateLanguages {
+			if strings.EqualFold(suffix, language) {
+				return template.Name[:i], suffix
+			}
+		}
+	}
+	return template.Name, template.Runtime
+}
+
+// groupPolicyPackTemplates groups templates that are variants of the same pack in different languages. Within each
+// group, templates keep their original order.
+func groupPolicyPackTemplates(
+	templates []workspace.PolicyPackTemplate) map[string][]workspace.PolicyPackTemplate {
+
+	groups := make(map[string][]workspace.PolicyPackTemplate)
+	for _, template := range templates {
+		name, _ := policyPackTemplateVariant(template)
+		groups[name] = append(groups[name], template)
+	}
+	return groups
+}
+
+// projectPolicyPackLanguage returns the language, as accepted by --language, of the Pulumi project in the first of
+// dirs that contains one, along with that directory. Node.js projects are assumed to use TypeScript unless they opt
+// out of it. If none of dirs contains a project that can be loaded, projectPolicyPackLanguage returns "".
+func projectPolicyPackLanguage(dirs ...string) (string, string) {
+	for _, dir := range dirs {
+		for _, ext := range encoding.Exts {
+			path := filepath.Join(dir, workspace.ProjectFile+ext)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			proj, err := workspace.LoadProject(path)
+			if err != nil {
+				return "", ""
+			}
+
+			runtime := strings.ToLower(proj.Runtime.Name())
+			if runtime == "nodejs" {
+				if typescript, ok := proj.Runtime.Options()["typescript"].(bool); ok && !typescript {
+					return "javascript", dir
+				}
+				return "typescript", dir
+			}
+			return runtime, dir
+		}
+	}
+	return "", ""
+}
+
+// policyPackLanguageAliases maps the short names that --language accepts to the languages that they stand for.
+var policyPackLanguageAliases = map[string]string{
+	"cs": "csharp",
+	"js": "javascript",
+	"py": "python",
+	"ts": "typescript",
+}
+
+// parsePolicyPackLanguages splits the value of --language into the comma-separated languages that it lists. Surrounding
+// whitespace is trimmed, and empty and duplicate languages are dropped.
+func parsePolicyPackLanguages(value string) []string {
+	var languages []string
+	seen := map[string]bool{}
+	for _, language := range strings.Split(value, ",") {
+		language = strings.TrimSpace(language)
+		if language == "" || seen[strings.ToLower(language)] {
+			continue
+		}
+		seen[strings.ToLower(language)] = true
+		languages = append(languages, language)
+	}
+	return languages
+}
+
+// filterPolicyPackTemplatesByLanguage returns the templates that are written in the given language. A template matches
+// if its runtime is the language (e.g. `nodejs`) or if one of the dash-separated parts of its name is the language
+// (e.g. `typescript` for `aws-typescript`).
+func filterPolicyPackTemplatesByLanguage(
+	templates []workspace.PolicyPackTemplate, language string) []workspace.PolicyPackTemplate {
+
+	if name, ok := policyPackLanguageAliases[strings.ToLower(language)]; ok {
+		language = name
+	}
+
+	var result []workspace.PolicyPackTemplate
+	for _, template := range templates {
+		if strings.EqualFold(template.Runtime, language) {
+			result = append(result, template)
+			continue
+		}
+		for _, part := range strings.Split(template.Name, "-") {
+			if strings.EqualFold(part, language) {
+				result = append(result, template)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// maxPolicyPackOptionDescriptionLength is the length at which descriptions are truncated in the template chooser, so
+// that a long description does not push the annotations of every other template out of view.
+const maxPolicyPackOptionDescriptionLength = 60
+
+// policyPackTemplateTags returns the annotations shown for a template in the template chooser: its language and, if
+// it is not a built-in template, where it was found.
+func policyPackTemplateTags(template workspace.PolicyPackTemplate) []string {
+	var tags []string
+	if _, language := policyPackTemplateVariant(template); language != "" {
+		tags = append(tags, language)
+	}
+	if template.Source != "" {
+		tags = append(tags, template.Source)
+	}
+	return tags
+}
+
+// policyTemplatesToOptionArrayAndMap returns an array of option strings and a map of option strings to policy
+// templates. Each option string is made up of the template name, its description, and its tags, in aligned columns;
+// the tags are right-aligned.
+func policyTemplatesToOptionArrayAndMap(templates []workspace.PolicyPackTemplate,
+	tags func(workspace.PolicyPackTemplate) []string,
+	opts display.Options) ([]string, map[string]workspace.PolicyPackTemplate) {
+
+	// Find the widths of the columns.
+	names := make([]string, len(templates))
+	descriptions := make([]string, len(templates))
+	annotations := make([]string, len(templates))
+	maxNameLength, maxDescriptionLength, maxAnnotationLength := 0, 0, 0
+	for i, template := range templates {
+		names[i] = template.Name
+		descriptions[i] = truncatePolicyPackOptionText(template.Description, maxPolicyPackOptionDescriptionLength)
+		if t := tags(template); len(t) > 0 {
+			annotations[i] = "[" + strings.Join(t, ", ") + "]"
+		}
+
+		if n := utf8.RuneCountInString(names[i]); n > maxNameLength {
+			maxNameLength = n
+		}
+		if n := utf8.RuneCountInString(descriptions[i]); n > maxDescriptionLength {
+			maxDescriptionLength = n
+		}
+		if n := utf8.RuneCountInString(annotations[i]); n > maxAnnotationLength {
+			maxAnnotationLength = n
+		}
+	}
+
+	// Build the array and map.
+	var options []string
+	nameToTemplateMap := make(map[string]workspace.PolicyPackTemplate)
+	for i, template := range templates {
+		option := opts.Color.Colorize(colors.SpecSubHeadline+padPolicyPackOptionText(names[i], maxNameLength)+
+			colors.Reset) + "    " + descriptions[i]
+		if maxAnnotationLength > 0 {
+			padding := maxDescriptionLength - utf8.RuneCountInString(descriptions[i]) +
+				maxAnnotationLength - utf8.RuneCountInString(annotations[i])
+			option += "    " + strings.Repeat(" ", padding) +
+				opts.Color.Colorize(colors.SpecInfo+annotations[i]+colors.Reset)
+		}
+
+		// Add it to the array and map.
+		options = append(options, option)
 		nameToTemplateMap[option] = template
 	}
 	sort.Strings(options)
 	return options, nameToTemplateMap
 }
+
+// padPolicyPackOptionText pads text with spaces to the given number of characters.
+func padPolicyPackOptionText(text string, width int) string {
+	if n := utf8.RuneCountInString(text); n < width {
+		return text + strings.Repeat(" ", width-n)
+	}
+	return text
+}
+
+// truncatePolicyPackOptionText shortens text to at most max characters, replacing the end with "..." if it is cut.
+func truncatePolicyPackOptionText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-3]) + "..."
+}
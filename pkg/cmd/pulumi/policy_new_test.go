@@ -14,14 +14,27 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi/pkg/v3/backend/display"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag/colors"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/cmdutil"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
 )
 
 //nolint:paralleltest // changes directory for process
@@ -114,3 +127,1763 @@ func assertNotFoundError(t *testing.T, err error) {
 	}
 	assert.Failf(t, "Error message does not contain \"not found\" or \"no such file or directory\": %s", msg)
 }
+
+//nolint:paralleltest // mutates policyPackInstallers
+func TestInstallPolicyPackDependenciesChoosesInstaller(t *testing.T) {
+	runtimes := []string{"nodejs", "python", "go", "dotnet"}
+
+	original := policyPackInstallers
+	defer func() { policyPackInstallers = original }()
+
+	var called []string
+	policyPackInstallers = map[string]policyPackInstaller{}
+	for _, runtime := range runtimes {
+		runtime := runtime
+		policyPackInstallers[runtime] = func(
+			context.Context, *workspace.PolicyPackProject, string, string, io.Writer) error {
+			called = append(called, runtime)
+			return nil
+		}
+	}
+
+	for _, runtime := range runtimes {
+		called = nil
+		proj := &workspace.PolicyPackProject{Runtime: workspace.NewProjectRuntimeInfo(runtime, nil)}
+		err := installPolicyPackDependencies(context.Background(), proj, "", "", ioutil.Discard, colors.Never)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{runtime}, called)
+	}
+
+	// Runtime names are matched case-insensitively.
+	called = nil
+	proj := &workspace.PolicyPackProject{Runtime: workspace.NewProjectRuntimeInfo("NodeJS", nil)}
+	assert.NoError(t, installPolicyPackDependencies(context.Background(), proj, "", "", ioutil.Discard, colors.Never))
+	assert.Equal(t, []string{"nodejs"}, called)
+
+	// Unknown runtimes have nothing to install.
+	called = nil
+	proj = &workspace.PolicyPackProject{Runtime: workspace.NewProjectRuntimeInfo("java", nil)}
+	assert.NoError(t, installPolicyPackDependencies(context.Background(), proj, "", "", ioutil.Discard, colors.Never))
+	assert.Empty(t, called)
+}
+
+//nolint:paralleltest // mutates policyPackInstallers
+func TestInstallPolicyPackDependenciesError(t *testing.T) {
+	original := policyPackInstallers
+	defer func() { policyPackInstallers = original }()
+
+	installErr := errors.New("boom")
+	policyPackInstallers = map[string]policyPackInstaller{
+		"go": func(context.Context, *workspace.PolicyPackProject, string, string, io.Writer) error {
+			return installErr
+		},
+	}
+
+	proj := &workspace.PolicyPackProject{Runtime: workspace.NewProjectRuntimeInfo("go", nil)}
+	err := installPolicyPackDependencies(context.Background(), proj, "", "", ioutil.Discard, colors.Never)
+
+	var typed *policyPackInstallError
+	if assert.True(t, errors.As(err, &typed)) {
+		assert.Equal(t, "go", typed.Runtime)
+	}
+	assert.ErrorIs(t, err, installErr)
+	assert.ErrorIs(t, err, ErrDependencyInstall)
+	assert.Contains(t, err.Error(), "go")
+}
+
+//nolint:paralleltest // changes directory for process, sets environment variables
+func TestNewPolicyPackErrors(t *testing.T) {
+	templateDir := t.TempDir()
+	t.Setenv("PULUMI_POLICY_TEMPLATE_PATH", templateDir)
+	require.NoError(t, os.Mkdir(filepath.Join(templateDir, "aws-typescript"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "aws-typescript", "PulumiPolicy.yaml"),
+		[]byte("runtime: nodejs\n"), 0600))
+
+	dir := t.TempDir()
+	chdir(t, dir)
+	args := newPolicyArgs{
+		generateOnly:      true,
+		noVerify:          true,
+		offline:           true,
+		templateNameOrURL: "gcp-go",
+		yes:               true,
+	}
+
+	// Each error can be told apart without matching its message, which is unchanged.
+	err := runNewPolicyPack(context.TODO(), args)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.NotErrorIs(t, err, ErrDirectoryNotEmpty)
+	var notFound *workspace.TemplateNotFoundError
+	assert.True(t, errors.As(err, &notFound), "unexpected error: %v", err)
+
+	args.templateNameOrURL, args.language = "aws-typescript", "python"
+	err = runNewPolicyPack(context.TODO(), args)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.EqualError(t, err, "no templates found for language 'python'")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes\n"), 0600))
+	args.language = ""
+	err = runNewPolicyPack(context.TODO(), args)
+	assert.ErrorIs(t, err, ErrDirectoryNotEmpty)
+	assert.NotErrorIs(t, err, ErrTemplateNotFound)
+	assert.Contains(t, err.Error(), "is not empty")
+}
+
+func TestFilterPolicyPackTemplatesByLanguage(t *testing.T) {
+	t.Parallel()
+
+	templates := []workspace.PolicyPackTemplate{
+		{Name: "aws-typescript", Runtime: "nodejs"},
+		{Name: "aws-javascript", Runtime: "nodejs"},
+		{Name: "aws-python", Runtime: "python"},
+		{Name: "azure-python", Runtime: "python"},
+		{Name: "gcp-go", Runtime: "go"},
+	}
+
+	names := func(templates []workspace.PolicyPackTemplate) []string {
+		var result []string
+		for _, template := range templates {
+			result = append(result, template.Name)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"aws-typescript"},
+		names(filterPolicyPackTemplatesByLanguage(templates, "typescript")))
+	assert.Equal(t, []string{"aws-typescript", "aws-javascript"},
+		names(filterPolicyPackTemplatesByLanguage(templates, "nodejs")))
+	assert.Equal(t, []string{"aws-python", "azure-python"},
+		names(filterPolicyPackTemplatesByLanguage(templates, "Python")))
+	assert.Equal(t, []string{"gcp-go"},
+		names(filterPolicyPackTemplatesByLanguage(templates, "go")))
+	assert.Empty(t, filterPolicyPackTemplatesByLanguage(templates, "dotnet"))
+
+	// Short names stand for the languages they abbreviate.
+	assert.Equal(t, []string{"aws-typescript"},
+		names(filterPolicyPackTemplatesByLanguage(templates, "ts")))
+	assert.Equal(t, []string{"aws-python", "azure-python"},
+		names(filterPolicyPackTemplatesByLanguage(templates, "PY")))
+}
+
+func TestParsePolicyPackLanguages(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, parsePolicyPackLanguages(""))
+	assert.Equal(t, []string{"python"}, parsePolicyPackLanguages("python"))
+	assert.Equal(t, []string{"ts", "python"}, parsePolicyPackLanguages("ts,python"))
+	assert.Equal(t, []string{"ts", "python"}, parsePolicyPackLanguages(" ts , ,TS,python, "))
+}
+
+func TestRenderPolicyPackPreview(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join("path", "to", "pack")
+	template := workspace.PolicyPackTemplate{Name: "aws-typescript"}
+	files := []workspace.TemplateFile{
+		{
+			Path:    filepath.Join(root, "PulumiPolicy.yaml"),
+			Content: []byte("runtime: nodejs\n"),
+		},
+		{
+			Path:     filepath.Join(root, "index.ts"),
+			Content:  []byte("a\nc\n"),
+			Exists:   true,
+			Existing: []byte("a\nb\n"),
+		},
+		{
+			Path:     filepath.Join(root, "package.json"),
+			Content:  []byte("{}\n"),
+			Exists:   true,
+			Existing: []byte("{}\n"),
+		},
+	}
+
+	actual := colors.Never.Colorize(renderPolicyPackPreview(template, files, root))
+	expected := "Creating a Policy Pack from template 'aws-typescript' in " + root +
+		" would write the following files:\n" +
+		"\n" +
+		"  create     PulumiPolicy.yaml\n" +
+		"  overwrite  index.ts\n" +
+		"        a\n" +
+		"      - b\n" +
+		"      + c\n" +
+		"  unchanged  package.json\n" +
+		"\n" +
+		"No files were written; rerun the command without --preview to create the Policy Pack\n"
+	assert.Equal(t, expected, actual)
+}
+
+func TestRenderPolicyPackTemplateList(t *testing.T) {
+	t.Parallel()
+
+	templates := []workspace.PolicyPackTemplate{
+		{Name: "gcp-python", Description: "A minimal Policy Pack for GCP using Python."},
+		{Name: "aws-typescript", Description: "A minimal Policy Pack for AWS using TypeScript."},
+	}
+
+	actual := colors.Never.Colorize(renderPolicyPackTemplateList(templates))
+	expected := "aws-typescript    A minimal Policy Pack for AWS using TypeScript.\n" +
+		"gcp-python        A minimal Policy Pack for GCP using Python.\n"
+	assert.Equal(t, expected, actual)
+
+	// The input is left untouched.
+	assert.Equal(t, "gcp-python", templates[0].Name)
+}
+
+func TestPolicyTemplatesToOptionArrayAndMap(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("A very long description. ", 4)
+	templates := []workspace.PolicyPackTemplate{
+		{Name: "aws-typescript", Description: "A minimal Policy Pack for AWS using TypeScript.", Runtime: "nodejs"},
+		{Name: "gcp-python", Description: "GCP.", Runtime: "python", Source: "./local"},
+		{Name: "custom", Description: long},
+	}
+
+	options, optionToTemplateMap := policyTemplatesToOptionArrayAndMap(
+		templates, policyPackTemplateTags, display.Options{Color: colors.Never})
+	require.Len(t, options, 3)
+
+	// Options are sorted by name, and each maps back to its template.
+	assert.True(t, strings.HasPrefix(options[0], "aws-typescript    "))
+	assert.True(t, strings.HasPrefix(options[1], "custom            "))
+	assert.True(t, strings.HasPrefix(options[2], "gcp-python        "))
+	for i, name := range []string{"aws-typescript", "custom", "gcp-python"} {
+		assert.Equal(t, name, optionToTemplateMap[options[i]].Name)
+	}
+
+	// Long descriptions are truncated, and the tags are right-aligned after the widest description.
+	assert.Equal(t, "aws-typescript    A minimal Policy Pack for AWS using TypeScript."+
+		strings.Repeat(" ", 22)+"[typescript]", options[0])
+	assert.Equal(t, "custom            "+long[:57]+"...", strings.TrimRight(options[1], " "))
+	assert.True(t, strings.HasSuffix(options[2], "GCP."+strings.Repeat(" ", 60)+"[python, ./local]"))
+	for _, option := range options {
+		assert.Len(t, option, len(options[0]))
+	}
+}
+
+func TestPolicyPackOptionFilter(t *testing.T) {
+	t.Parallel()
+
+	templates := []workspace.PolicyPackTemplate{
+		{Name: "aws-typescript", Description: "A minimal Policy Pack for AWS using TypeScript.", Runtime: "nodejs"},
+		{Name: "gcp-python", Description: "A minimal Policy Pack for GCP using Python.", Runtime: "python"},
+		{Name: "custom", Description: strings.Repeat("x", 80) + " with a hidden suffix"},
+	}
+
+	// Use colorized options to make sure the escape codes don't take part in the matching.
+	options, optionToTemplateMap := policyTemplatesToOptionArrayAndMap(
+		templates, policyPackTemplateTags, display.Options{Color: colors.Always})
+	filter := policyPackOptionFilter(optionToTemplateMap)
+
+	matches := func(text string) []string {
+		var names []string
+		for _, option := range options {
+			if filter(text, option) {
+				names = append(names, optionToTemplateMap[option].Name)
+			}
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"aws-typescript", "custom", "gcp-python"}, matches(""))
+	assert.Equal(t, []string{"aws-typescript"}, matches("AWS"))
+	assert.Equal(t, []string{"gcp-python"}, matches("pyth"))
+	assert.Equal(t, []string{"aws-typescript", "gcp-python"}, matches("minimal policy"))
+	assert.Equal(t, []string{"custom"}, matches("hidden suffix"), "truncated descriptions are still matched")
+	assert.Empty(t, matches("azure"))
+	assert.Empty(t, matches("[0m"))
+	assert.False(t, filter("", "not an option"))
+}
+
+func TestWritePolicyPackGitignore(t *testing.T) {
+	t.Parallel()
+
+	readGitignore := func(t *testing.T, root string) string {
+		b, err := ioutil.ReadFile(filepath.Join(root, ".gitignore"))
+		assert.NoError(t, err)
+		return string(b)
+	}
+
+	t.Run("WritesForRuntime", func(t *testing.T) {
+		t.Parallel()
+
+		templateDir, root := t.TempDir(), t.TempDir()
+		overwrote, err := writePolicyPackGitignore(templateDir, root, "nodejs", false)
+		assert.NoError(t, err)
+		assert.False(t, overwrote)
+		assert.Equal(t, "/bin/\n/node_modules/\n", readGitignore(t, root))
+	})
+
+	t.Run("TemplateIncludesGitignore", func(t *testing.T) {
+		t.Parallel()
+
+		templateDir, root := t.TempDir(), t.TempDir()
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, ".gitignore"), []byte("custom\n"), 0600))
+		_, err := writePolicyPackGitignore(templateDir, root, "python", false)
+		assert.NoError(t, err)
+		_, err = os.Stat(filepath.Join(root, ".gitignore"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("ExistingGitignore", func(t *testing.T) {
+		t.Parallel()
+
+		templateDir, root := t.TempDir(), t.TempDir()
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, ".gitignore"), []byte("mine\n"), 0600))
+
+		overwrote, err := writePolicyPackGitignore(templateDir, root, "python", false)
+		assert.NoError(t, err)
+		assert.False(t, overwrote)
+		assert.Equal(t, "mine\n", readGitignore(t, root))
+
+		overwrote, err = writePolicyPackGitignore(templateDir, root, "python", true)
+		assert.NoError(t, err)
+		assert.True(t, overwrote)
+		assert.Equal(t, "*.pyc\n__pycache__/\nvenv/\n", readGitignore(t, root))
+	})
+}
+
+func TestGroupPolicyPackTemplates(t *testing.T) {
+	t.Parallel()
+
+	templates := []workspace.PolicyPackTemplate{
+		{Name: "aws-typescript", Runtime: "nodejs"},
+		{Name: "aws-python", Runtime: "python"},
+		{Name: "azure-python", Runtime: "python"},
+		{Name: "kubernetes", Runtime: "nodejs"},
+		{Name: "gcp-nodejs", Runtime: "nodejs"},
+	}
+
+	groups := groupPolicyPackTemplates(templates)
+	assert.Len(t, groups, 4)
+	assert.Equal(t, []workspace.PolicyPackTemplate{templates[0], templates[1]}, groups["aws"])
+	assert.Equal(t, []workspace.PolicyPackTemplate{templates[2]}, groups["azure"])
+	assert.Equal(t, []workspace.PolicyPackTemplate{templates[3]}, groups["kubernetes"])
+	assert.Equal(t, []workspace.PolicyPackTemplate{templates[4]}, groups["gcp"])
+
+	pack, language := policyPackTemplateVariant(templates[0])
+	assert.Equal(t, "aws", pack)
+	assert.Equal(t, "typescript", language)
+
+	pack, language = policyPackTemplateVariant(templates[3])
+	assert.Equal(t, "kubernetes", pack)
+	assert.Equal(t, "nodejs", language)
+
+	// Unique names are not grouped.
+	unique := []workspace.PolicyPackTemplate{templates[0], templates[2], templates[3]}
+	assert.Len(t, groupPolicyPackTemplates(unique), len(unique))
+}
+
+func TestSetPolicyPackDescription(t *testing.T) {
+	t.Parallel()
+
+	write := func(t *testing.T, contents string) string {
+		path := filepath.Join(t.TempDir(), "PulumiPolicy.yaml")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+		return path
+	}
+	read := func(t *testing.T, path string) string {
+		b, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		return string(b)
+	}
+
+	t.Run("Replace", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, "# The runtime.\nruntime: nodejs\ndescription: A template # the description\nversion: 0.0.1\n")
+		assert.NoError(t, setPolicyPackDescription(path, "My policies"))
+		assert.Equal(t,
+			"# The runtime.\nruntime: nodejs\ndescription: My policies # the description\nversion: 0.0.1\n",
+			read(t, path))
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, "runtime: python\n")
+		assert.NoError(t, setPolicyPackDescription(path, "My policies"))
+		assert.Equal(t, "runtime: python\ndescription: My policies\n", read(t, path))
+	})
+
+	t.Run("NotAMapping", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, "- runtime\n")
+		assert.Error(t, setPolicyPackDescription(path, "My policies"))
+	})
+}
+
+func TestRemovePolicyPackTemplateManifest(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "PulumiPolicy.yaml")
+	contents := "# The runtime.\nruntime: nodejs\ntemplate:\n  hooks:\n    - npm run format\ndescription: Policies\n"
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+
+	assert.NoError(t, removePolicyPackTemplateManifest(path))
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "# The runtime.\nruntime: nodejs\ndescription: Policies\n", string(b))
+}
+
+func TestShouldRunPolicyPackHooks(t *testing.T) {
+	t.Parallel()
+
+	hooks := []string{"npm run format"}
+	interactive := display.Options{IsInteractive: true}
+	cases := []struct {
+		name      string
+		hooks     []string
+		remote    bool
+		args      newPolicyArgs
+		opts      display.Options
+		confirmed bool
+		run       bool
+		skipped   bool
+	}{
+		{name: "NoHooks", args: newPolicyArgs{yes: true}},
+		{name: "GenerateOnly", hooks: hooks, args: newPolicyArgs{yes: true, generateOnly: true}, skipped: true},
+		{name: "Yes", hooks: hooks, args: newPolicyArgs{yes: true}, run: true},
+		{name: "Confirmed", hooks: hooks, opts: interactive, confirmed: true, run: true},
+		{name: "Declined", hooks: hooks, opts: interactive, skipped: true},
+		{name: "RemoteWithYes", hooks: hooks, remote: true, args: newPolicyArgs{yes: true}, skipped: true},
+		{name: "RemoteConfirmed", hooks: hooks, remote: true, opts: interactive, confirmed: true, run: true},
+		{name: "RemoteAllowed", hooks: hooks, remote: true, args: newPolicyArgs{yes: true, allowHooks: true}, run: true},
+		{name: "RemoteInteractiveWithYes", hooks: hooks, remote: true, args: newPolicyArgs{yes: true}, opts: interactive,
+			skipped: true},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			asked := false
+			run, skipped := shouldRunPolicyPackHooks(c.hooks, c.remote, c.args, c.opts, func(hooks []string) bool {
+				asked = true
+				assert.Equal(t, c.hooks, hooks)
+				return c.confirmed
+			})
+			assert.Equal(t, c.run, run)
+			assert.Equal(t, c.skipped, skipped != "")
+			// --yes skips the confirmation.
+			assert.Equal(t, c.opts.IsInteractive && !c.args.yes && !c.args.generateOnly && !c.args.allowHooks, asked)
+		})
+	}
+}
+
+func TestRunPolicyPackHooks(t *testing.T) {
+	t.Parallel()
+
+	// `go version` stands in for a hook that does nothing of note.
+	var stdout bytes.Buffer
+	err := runPolicyPackHooks(context.Background(), t.TempDir(), &stdout, colors.Never, []string{"go version", "  "})
+	assert.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Running `go version`...\n")
+	assert.Contains(t, stdout.String(), "go version go")
+
+	err = runPolicyPackHooks(context.Background(), t.TempDir(), ioutil.Discard, colors.Never,
+		[]string{"go no-such-command"})
+	assert.Error(t, err)
+
+	// Arguments may be quoted. Were the quotes kept, `go env` would print empty values.
+	stdout.Reset()
+	err = runPolicyPackHooks(context.Background(), t.TempDir(), &stdout, colors.Never, []string{`go env "GOOS" 'GOARCH'`})
+	assert.NoError(t, err)
+	assert.Contains(t, stdout.String(), runtime.GOOS+"\n"+runtime.GOARCH+"\n")
+
+	err = runPolicyPackHooks(context.Background(), t.TempDir(), ioutil.Discard, colors.Never, []string{`go env "GOOS`})
+	assert.Error(t, err)
+}
+
+func TestRetrievePolicyPackTemplatesFromSearchPath(t *testing.T) {
+	t.Parallel()
+
+	writeTemplate := func(t *testing.T, root, name, description string) {
+		dir := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(dir, 0700))
+		contents := "runtime: nodejs\ndescription: " + description + "\n"
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"), []byte(contents), 0600))
+	}
+
+	first, second := t.TempDir(), t.TempDir()
+	writeTemplate(t, first, "curated", "First")
+	writeTemplate(t, second, "curated", "Second")
+	writeTemplate(t, second, "extra", "Extra")
+
+	templates, err := searchPolicyPackTemplates([]string{first, second})
+	assert.NoError(t, err)
+	if assert.Len(t, templates, 2) {
+		assert.Equal(t, "curated", templates[0].Name)
+		assert.Equal(t, first, templates[0].Source)
+		assert.Equal(t, "First ["+first+"]", policyPackTemplateDescription(templates[0]))
+		assert.Equal(t, "extra", templates[1].Name)
+		assert.Equal(t, second, templates[1].Source)
+	}
+
+	// A template on the search path is used without retrieving the templates-policy repo.
+	templates, cleanup, err := retrievePolicyPackTemplates(newPolicyArgs{
+		templateNameOrURL:   "extra",
+		templateSearchPaths: []string{first, second},
+	}, ioutil.Discard, colors.Never)
+	require.NoError(t, err)
+	defer cleanup()
+	if assert.Len(t, templates, 1) {
+		assert.Equal(t, filepath.Join(second, "extra"), templates[0].Dir)
+	}
+
+	_, err = searchPolicyPackTemplates([]string{filepath.Join(first, "missing")})
+	assert.Error(t, err)
+}
+
+//nolint:paralleltest // sets environment variables
+func TestRetrievePolicyPackTemplatesCABundle(t *testing.T) {
+	// A CA bundle that can't be read stops templates from being downloaded without it.
+	t.Setenv("PULUMI_CA_BUNDLE", filepath.Join(t.TempDir(), "missing.pem"))
+	_, _, err := retrievePolicyPackTemplates(newPolicyArgs{offline: true, templateNameOrURL: "aws-typescript"},
+		ioutil.Discard, colors.Never)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "PULUMI_CA_BUNDLE")
+	assert.Contains(t, err.Error(), "missing.pem")
+}
+
+//nolint:paralleltest // sets environment variables
+func TestPolicyTemplateToken(t *testing.T) {
+	t.Setenv("PULUMI_TEMPLATE_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "github")
+
+	// The GitHub token is only sent to github.com, and no token is sent for templates from the templates-policy repo.
+	token, host := policyTemplateToken("https://github.com/acme/policy-templates")
+	assert.Equal(t, "github", token)
+	assert.Equal(t, "github.com", host)
+	token, _ = policyTemplateToken("https://git.acme.internal/policy-templates")
+	assert.Empty(t, token)
+	token, _ = policyTemplateToken("aws-typescript")
+	assert.Empty(t, token)
+
+	// The template token is sent to the template's host only.
+	t.Setenv("PULUMI_TEMPLATE_TOKEN", "template")
+	token, host = policyTemplateToken("https://git.acme.internal:8443/policy-templates")
+	assert.Equal(t, "template", token)
+	assert.Equal(t, "git.acme.internal", host)
+	token, _ = policyTemplateToken("aws-typescript")
+	assert.Empty(t, token)
+}
+
+//nolint:paralleltest // sets environment variables
+func TestRetrievePolicyPackTemplatesOfflineVerifiesChecksums(t *testing.T) {
+	templateDir := t.TempDir()
+	t.Setenv("PULUMI_POLICY_TEMPLATE_PATH", templateDir)
+
+	packDir := filepath.Join(templateDir, "aws-typescript")
+	require.NoError(t, os.MkdirAll(packDir, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(packDir, "PulumiPolicy.yaml"), []byte("runtime: nodejs\n"), 0600))
+	// Record a checksum that no longer matches, as if the file had been edited after it was downloaded.
+	checksums := `{"files": {"aws-typescript/PulumiPolicy.yaml": "0000"}}`
+	require.NoError(t, ioutil.WriteFile(templateDir+".checksums.json", []byte(checksums), 0600))
+
+	args := newPolicyArgs{
+		offline:           true,
+		templateNameOrURL: "aws-typescript",
+	}
+	_, _, err := retrievePolicyPackTemplates(args, ioutil.Discard, colors.Never)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "modified: aws-typescript/PulumiPolicy.yaml")
+	assert.Contains(t, err.Error(), "--no-verify")
+
+	args.noVerify = true
+	templates, cleanup, err := retrievePolicyPackTemplates(args, ioutil.Discard, colors.Never)
+	require.NoError(t, err)
+	defer cleanup()
+	if assert.Len(t, templates, 1) {
+		assert.Equal(t, "aws-typescript", templates[0].Name)
+	}
+}
+
+func TestPolicyPackTemplateCleanupKeepTemp(t *testing.T) {
+	t.Parallel()
+
+	newRepo := func(t *testing.T) workspace.TemplateRepository {
+		root := filepath.Join(t.TempDir(), "templates")
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "aws-typescript"), 0700))
+		return workspace.TemplateRepository{Root: root, SubDirectory: root, ShouldDelete: true}
+	}
+
+	// By default, the retrieved repo is deleted.
+	repo := newRepo(t)
+	var out bytes.Buffer
+	policyPackTemplateCleanup(repo, false, &out, colors.Never)()
+	assert.NoDirExists(t, repo.Root)
+	assert.Empty(t, out.String())
+
+	// With --keep-temp, it persists and its path is reported.
+	repo = newRepo(t)
+	out.Reset()
+	policyPackTemplateCleanup(repo, true, &out, colors.Never)()
+	assert.DirExists(t, filepath.Join(repo.Root, "aws-typescript"))
+	assert.Equal(t, "Kept the retrieved templates in "+repo.Root+"\n", out.String())
+
+	// The notice honors the color setting.
+	repo = newRepo(t)
+	out.Reset()
+	policyPackTemplateCleanup(repo, true, &out, colors.Always)()
+	assert.Contains(t, out.String(), "\x1b[")
+	assert.Contains(t, out.String(), "Kept the retrieved templates in "+repo.Root)
+
+	// A repo that isn't temporary, such as a local directory of templates, is never reported.
+	repo = newRepo(t)
+	repo.ShouldDelete = false
+	out.Reset()
+	policyPackTemplateCleanup(repo, true, &out, colors.Never)()
+	assert.DirExists(t, repo.Root)
+	assert.Empty(t, out.String())
+}
+
+func TestParsePolicyPackTemplateVariables(t *testing.T) {
+	t.Parallel()
+
+	variables, err := parsePolicyPackTemplateVariables([]string{"ORG=acme", "QUERY=a=b", "EMPTY="})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"ORG": "acme", "QUERY": "a=b", "EMPTY": ""}, variables)
+
+	_, err = parsePolicyPackTemplateVariables([]string{"ORG"})
+	assert.EqualError(t, err, "invalid --set value 'ORG': expected key=value")
+
+	_, err = parsePolicyPackTemplateVariables([]string{"=acme"})
+	assert.Error(t, err)
+
+	// Keys use the same grammar as the placeholders that are reported when left unresolved.
+	variables, err = parsePolicyPackTemplateVariables([]string{"org_name=acme", "_region=us"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"org_name": "acme", "_region": "us"}, variables)
+	_, err = parsePolicyPackTemplateVariables([]string{"org-name=acme"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid template variable name 'org-name'")
+	_, err = parsePolicyPackTemplateVariables([]string{"1ORG=acme"})
+	assert.Error(t, err)
+}
+
+func TestPolicyPackProgress(t *testing.T) {
+	t.Parallel()
+
+	for _, spin := range []bool{false, true} {
+		var out bytes.Buffer
+		progress := policyPackProgress{out: &out, spin: spin, color: colors.Never}
+
+		calls := 0
+		assert.NoError(t, progress.run("Working...", false, func() error {
+			calls++
+			return nil
+		}))
+		assert.Equal(t, 1, calls)
+		if !spin {
+			assert.Equal(t, "Working...\n", out.String())
+		}
+
+		// Phases that write output themselves get their message on its own line, even with a spinner.
+		out.Reset()
+		phaseErr := errors.New("boom")
+		assert.ErrorIs(t, progress.run("Writing...", true, func() error {
+			return phaseErr
+		}), phaseErr)
+		assert.Equal(t, "Writing...\n", out.String())
+		assert.ErrorIs(t, progress.run("Working...", false, func() error {
+			return phaseErr
+		}), phaseErr)
+	}
+}
+
+func TestNewPolicyPackSummary(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join("work", "pack")
+	files := []string{
+		filepath.Join(root, "index.ts"),
+		filepath.Join(root, "PulumiPolicy.yaml"),
+		filepath.Join(root, "src", "rules.ts"),
+	}
+	files = appendPolicyPackFile(files, filepath.Join(root, ".gitignore"))
+	files = appendPolicyPackFile(files, filepath.Join(root, "index.ts"))
+
+	summary := newPolicyPackSummary("aws-typescript", root, "nodejs", files)
+	assert.Equal(t, policyPackSummary{
+		Template: "aws-typescript",
+		Dir:      root,
+		Runtime:  "nodejs",
+		Files:    []string{".gitignore", "PulumiPolicy.yaml", "index.ts", "src/rules.ts"},
+	}, summary)
+
+	b, err := json.Marshal(summary)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"files":[".gitignore","PulumiPolicy.yaml","index.ts","src/rules.ts"]`)
+}
+
+func TestDescribePolicyPackScaffold(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join("work", "pack")
+	template := workspace.PolicyPackTemplate{Name: "aws-typescript", Runtime: "nodejs"}
+	assert.Equal(t, "Creating Policy Pack from template 'aws-typescript' (nodejs) in "+dir,
+		describePolicyPackScaffold(template, dir))
+
+	template.Runtime = ""
+	assert.Equal(t, "Creating Policy Pack from template 'aws-typescript' in "+dir,
+		describePolicyPackScaffold(template, dir))
+}
+
+func TestExistingPolicyPackPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	_, err := existingPolicyPackPath(dir, "--from-existing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no existing Policy Pack found")
+
+	path := filepath.Join(dir, "PulumiPolicy.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("runtime: nodejs\n"), 0600))
+	found, err := existingPolicyPackPath(dir, "--from-existing")
+	assert.NoError(t, err)
+	assert.Equal(t, path, found)
+
+	// A Policy Pack in a parent directory does not count.
+	sub := filepath.Join(dir, "sub")
+	require.NoError(t, os.Mkdir(sub, 0700))
+	_, err = existingPolicyPackPath(sub, "--from-existing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no existing Policy Pack found")
+}
+
+//nolint:paralleltest // changes directory for process
+func TestNewPolicyPackFromExistingRequiresPolicyPack(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	err := runNewPolicyPack(context.TODO(), newPolicyArgs{
+		fromExisting:      true,
+		offline:           true,
+		templateNameOrURL: "aws-typescript",
+		yes:               true,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no existing Policy Pack found")
+}
+
+func TestPolicyPackOverwrites(t *testing.T) {
+	t.Parallel()
+
+	templateDir, dir := t.TempDir(), t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "PulumiPolicy.yaml"),
+		[]byte("runtime: nodejs\n"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(templateDir, "src"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "src", "index.ts"), []byte("// new\n"), 0600))
+
+	// Only the file that already exists is reported.
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "src"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "src", "index.ts"), []byte("// mine\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("unrelated\n"), 0600))
+
+	overwrites, err := policyPackOverwrites(templateDir, dir, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join("src", "index.ts")}, overwrites)
+
+	warning := colors.Never.Colorize(renderPolicyPackOverwriteWarning(overwrites))
+	assert.Equal(t, "warning: --force overwrote 1 existing file(s):\n    "+filepath.Join("src", "index.ts")+"\n", warning)
+
+	assert.Equal(t, "", renderPolicyPackOverwriteWarning(nil))
+}
+
+//nolint:paralleltest // changes directory for process
+func TestNewPolicyPackForceOverwrites(t *testing.T) {
+	searchPath := t.TempDir()
+	templateDir := filepath.Join(searchPath, "local-pack")
+	require.NoError(t, os.Mkdir(templateDir, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "PulumiPolicy.yaml"),
+		[]byte("runtime: nodejs\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "index.ts"), []byte("// new\n"), 0600))
+
+	dir := t.TempDir()
+	chdir(t, dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "index.ts"), []byte("// mine\n"), 0600))
+
+	overwrites, err := policyPackOverwrites(templateDir, dir, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"index.ts"}, overwrites)
+
+	// The overwrite is reported, not prevented.
+	err = runNewPolicyPack(context.TODO(), newPolicyArgs{
+		force:               true,
+		generateOnly:        true,
+		noGitignore:         true,
+		offline:             true,
+		templateNameOrURL:   "local-pack",
+		templateSearchPaths: []string{searchPath},
+		yes:                 true,
+	})
+	require.NoError(t, err)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "index.ts"))
+	require.NoError(t, err)
+	assert.Equal(t, "// new\n", string(b))
+}
+
+func TestExcludePolicyPackTemplateFiles(t *testing.T) {
+	t.Parallel()
+
+	templateDir := filepath.Join(t.TempDir(), "local-pack")
+	for _, file := range []string{
+		"PulumiPolicy.yaml", "index.ts", "ci.yml", filepath.Join(".github", "workflows", "ci.yml"),
+		filepath.Join("examples", "extra.ts"), filepath.Join("examples", "nested", "deep.ts"),
+	} {
+		path := filepath.Join(templateDir, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(file+"\n"), 0600))
+	}
+	template := workspace.PolicyPackTemplate{Dir: templateDir, Name: "local-pack"}
+
+	// Without patterns, the template is used as it is.
+	unchanged, cleanup, err := excludePolicyPackTemplateFiles(template, nil)
+	require.NoError(t, err)
+	cleanup()
+	assert.Equal(t, template, unchanged)
+
+	// Patterns match paths relative to the template and file names; PulumiPolicy.yaml is always kept.
+	excluded, cleanup, err := excludePolicyPackTemplateFiles(template,
+		[]string{".github", "examples/*.ts", "*.yml", "PulumiPolicy.yaml"})
+	require.NoError(t, err)
+	assert.NotEqual(t, templateDir, excluded.Dir)
+	assert.Equal(t, "local-pack", excluded.Name)
+
+	var files []string
+	require.NoError(t, filepath.Walk(excluded.Dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, relErr := filepath.Rel(excluded.Dir, path)
+			require.NoError(t, relErr)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	}))
+	assert.Equal(t, []string{"PulumiPolicy.yaml", "examples/nested/deep.ts", "index.ts"}, files)
+
+	cleanup()
+	assert.NoDirExists(t, excluded.Dir)
+	assert.FileExists(t, filepath.Join(templateDir, ".github", "workflows", "ci.yml"))
+
+	assert.NoError(t, validatePolicyPackExcludes([]string{".github", "examples/*"}))
+	assert.Error(t, validatePolicyPackExcludes([]string{"[examples"}))
+}
+
+//nolint:paralleltest // changes directory for process
+func TestNewPolicyPackExclude(t *testing.T) {
+	searchPath := t.TempDir()
+	templateDir := filepath.Join(searchPath, "local-pack")
+	require.NoError(t, os.MkdirAll(filepath.Join(templateDir, ".github"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "PulumiPolicy.yaml"),
+		[]byte("runtime: nodejs\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "index.ts"), []byte("// new\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "example.ts"), []byte("// example\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, ".github", "ci.yml"), []byte("on: push\n"), 0600))
+
+	// An existing file that the template would overwrite isn't reported once it is excluded.
+	dir := t.TempDir()
+	chdir(t, dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "example.ts"), []byte("// mine\n"), 0600))
+
+	err := runNewPolicyPack(context.TODO(), newPolicyArgs{
+		excludes:            []string{".github", "example.ts"},
+		force:               true,
+		generateOnly:        true,
+		noGitignore:         true,
+		offline:             true,
+		templateNameOrURL:   "local-pack",
+		templateSearchPaths: []string{searchPath},
+		yes:                 true,
+	})
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "index.ts"))
+	require.NoError(t, err)
+	assert.Equal(t, "// new\n", string(b))
+	b, err = ioutil.ReadFile(filepath.Join(dir, "example.ts"))
+	require.NoError(t, err)
+	assert.Equal(t, "// mine\n", string(b))
+	assert.NoDirExists(t, filepath.Join(dir, ".github"))
+}
+
+//nolint:paralleltest // changes directory for process
+func TestNewPolicyPackArchive(t *testing.T) {
+	searchPath := t.TempDir()
+	templateDir := filepath.Join(searchPath, "local-pack")
+	require.NoError(t, os.MkdirAll(filepath.Join(templateDir, "policies"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "PulumiPolicy.yaml"),
+		[]byte("runtime: nodejs\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "index.ts"), []byte("// new\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "policies", "tags.ts"), []byte("// tags\n"), 0600))
+
+	// The working directory isn't written to, so it doesn't need to be empty.
+	dir := t.TempDir()
+	chdir(t, dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes\n"), 0600))
+
+	archive := filepath.Join(t.TempDir(), "pack.zip")
+	args := newPolicyArgs{
+		archive:             archive,
+		offline:             true,
+		templateNameOrURL:   "local-pack",
+		templateSearchPaths: []string{searchPath},
+		yes:                 true,
+	}
+	require.NoError(t, runNewPolicyPack(context.TODO(), args))
+
+	r, err := zip.OpenReader(archive)
+	require.NoError(t, err)
+	contents := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		contents[f.Name] = string(b)
+	}
+	require.NoError(t, r.Close())
+	assert.Equal(t, map[string]string{
+		"PulumiPolicy.yaml": "runtime: nodejs\n",
+		"index.ts":          "// new\n",
+		"policies/tags.ts":  "// tags\n",
+	}, contents)
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "notes.txt", entries[0].Name())
+
+	// An existing archive is only replaced with --force.
+	err = runNewPolicyPack(context.TODO(), args)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+	args.force = true
+	assert.NoError(t, runNewPolicyPack(context.TODO(), args))
+}
+
+//nolint:paralleltest // changes directory for process
+func TestNewPolicyPackUpgrade(t *testing.T) {
+	searchPath := t.TempDir()
+	templateDir := filepath.Join(searchPath, "local-pack")
+	require.NoError(t, os.Mkdir(templateDir, 0700))
+	writeTemplate := func(files map[string]string) {
+		for name, content := range files {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, name), []byte(content), 0600))
+		}
+	}
+	writeTemplate(map[string]string{
+		"PulumiPolicy.yaml": "runtime: nodejs\ntemplate:\n  framework:\n" +
+			"    - package.json\n    - tsconfig.json\n    - .eslintrc*\n    - build.sh\n",
+		"package.json":  "{ \"version\": 1 }\n",
+		"tsconfig.json": "{ \"strict\": false }\n",
+		"index.ts":      "// policies v1\n",
+	})
+
+	dir := t.TempDir()
+	chdir(t, dir)
+	args := newPolicyArgs{
+		generateOnly:        true,
+		noGitignore:         true,
+		offline:             true,
+		templateNameOrURL:   "local-pack",
+		templateSearchPaths: []string{searchPath},
+		yes:                 true,
+	}
+	require.NoError(t, runNewPolicyPack(context.TODO(), args))
+	assert.FileExists(t, filepath.Join(dir, policyPackTemplateRecordFile))
+
+	// The user writes their policies and customizes one of the framework files, and then the template evolves.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "index.ts"), []byte("// my policies\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "tsconfig.json"), []byte("{ \"mine\": true }\n"), 0600))
+	writeTemplate(map[string]string{
+		"package.json":   "{ \"version\": 2 }\n",
+		"tsconfig.json":  "{ \"strict\": true }\n",
+		"index.ts":       "// policies v2\n",
+		".eslintrc.json": "{}\n",
+		"build.sh":       "#!/bin/sh\n",
+	})
+	require.NoError(t, os.Chmod(filepath.Join(templateDir, "build.sh"), 0700))
+	require.NoError(t, os.Chmod(filepath.Join(dir, "package.json"), 0644))
+
+	readFile := func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	// The template is found from the Policy Pack's record. Framework files that weren't changed are updated and new
+	// ones added, but the customized framework file and the policies are left alone.
+	args.templateNameOrURL, args.upgrade = "", true
+	require.NoError(t, runNewPolicyPack(context.TODO(), args))
+	assert.Equal(t, "{ \"version\": 2 }\n", readFile("package.json"))
+	assert.Equal(t, "{}\n", readFile(".eslintrc.json"))
+	assert.Equal(t, "{ \"mine\": true }\n", readFile("tsconfig.json"))
+	assert.Equal(t, "// my policies\n", readFile("index.ts"))
+
+	// New framework files get the template file's permissions, and updated ones keep their own.
+	mode := func(name string) os.FileMode {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return info.Mode().Perm()
+	}
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0644), mode("package.json"))
+		assert.Equal(t, os.FileMode(0600), mode(".eslintrc.json"))
+		assert.Equal(t, os.FileMode(0700), mode("build.sh"))
+	}
+
+	// With --force, the customized framework file is replaced too, but the policies still aren't.
+	args.force = true
+	require.NoError(t, runNewPolicyPack(context.TODO(), args))
+	assert.Equal(t, "{ \"strict\": true }\n", readFile("tsconfig.json"))
+	assert.Equal(t, "// my policies\n", readFile("index.ts"))
+}
+
+func TestPlanPolicyPackUpgrade(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join("path", "to", "pack")
+	file := func(name, existing, content string) workspace.TemplateFile {
+		return workspace.TemplateFile{
+			Path:     filepath.Join(dir, name),
+			Content:  []byte(content),
+			Exists:   existing != "",
+			Existing: []byte(existing),
+		}
+	}
+	files := []workspace.TemplateFile{
+		file("PulumiPolicy.yaml", "runtime: nodejs\n", "runtime: nodejs\ntemplate: {}\n"),
+		file("index.ts", "// mine\n", "// new\n"),
+		file("package.json", "old\n", "new\n"),
+		file("tsconfig.json", "mine\n", "new\n"),
+		file("jest.config.js", "", "new\n"),
+		file("settings.json", "new\n", "new\n"),
+	}
+	patterns := []string{"*.json", "*.config.js", "PulumiPolicy.yaml"}
+	record := &policyPackTemplateRecord{Files: map[string]string{
+		"package.json":  policyPackChecksum([]byte("old\n")),
+		"tsconfig.json": policyPackChecksum([]byte("old\n")),
+	}}
+
+	actions := func(plan []policyPackUpgradeFile) map[string]policyPackUpgradeAction {
+		result := map[string]policyPackUpgradeAction{}
+		for _, file := range plan {
+			result[file.Path] = file.Action
+		}
+		return result
+	}
+
+	plan, err := planPolicyPackUpgrade(files, dir, patterns, record)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]policyPackUpgradeAction{
+		"package.json":   policyPackUpgradeUpdate,
+		"tsconfig.json":  policyPackUpgradeModified,
+		"jest.config.js": policyPackUpgradeCreate,
+		"settings.json":  policyPackUpgradeUnchanged,
+	}, actions(plan))
+
+	// Without a record, any difference from the template counts as a modification.
+	plan, err = planPolicyPackUpgrade(files, dir, patterns, nil)
+	require.NoError(t, err)
+	assert.Equal(t, policyPackUpgradeModified, actions(plan)["package.json"])
+}
+
+// captureStdout runs fn and returns what it wrote to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	f, err := ioutil.TempFile(t.TempDir(), "stdout")
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = f
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	fn()
+	require.NoError(t, f.Close())
+	b, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	return string(b)
+}
+
+//nolint:paralleltest // changes directory for process, sets the global colorization
+func TestNewPolicyPackColorization(t *testing.T) {
+	searchPath := t.TempDir()
+	templateDir := filepath.Join(searchPath, "local-pack")
+	require.NoError(t, os.Mkdir(templateDir, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "PulumiPolicy.yaml"),
+		[]byte("runtime: nodejs\n"), 0600))
+	t.Cleanup(func() {
+		require.NoError(t, cmdutil.SetGlobalColorization("auto"))
+	})
+
+	newPolicyPack := func(color string) string {
+		require.NoError(t, cmdutil.SetGlobalColorization(color))
+		chdir(t, t.TempDir())
+		return captureStdout(t, func() {
+			require.NoError(t, runNewPolicyPack(context.TODO(), newPolicyArgs{
+				generateOnly:        true,
+				offline:             true,
+				templateNameOrURL:   "local-pack",
+				templateSearchPaths: []string{searchPath},
+				yes:                 true,
+			}))
+		})
+	}
+
+	// With --color=never, neither escape codes nor colorization directives leak into the output.
+	output := newPolicyPack("never")
+	assert.Contains(t, output, "Created Policy Pack!\n")
+	assert.Contains(t, output, "npm install")
+	assert.NotContains(t, output, "\x1b[")
+	assert.NotContains(t, output, "<{%")
+
+	// With --color=always, the messages are colorized, including the ones that used to bypass colorization.
+	output = newPolicyPack("always")
+	assert.Regexp(t, "\x1b\\[[0-9;]*mCreated Policy Pack!", output)
+	assert.NotContains(t, output, "<{%")
+}
+
+func TestErrorIfPolicyPackDirectoryNotEmptyIgnoresMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		dirs    []string
+		files   []string
+		wantErr bool
+	}{
+		{name: "Empty"},
+		{name: "GitRepository", dirs: []string{".git"}},
+		{
+			name:  "EditorMetadata",
+			dirs:  []string{".git", ".vscode", ".idea"},
+			files: []string{".gitignore", ".DS_Store"},
+		},
+		{name: "SourceFile", dirs: []string{".git"}, files: []string{"index.ts"}, wantErr: true},
+		{name: "OtherDirectory", dirs: []string{"src"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for _, name := range tt.dirs {
+				require.NoError(t, os.Mkdir(filepath.Join(dir, name), 0700))
+			}
+			for _, name := range tt.files {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600))
+			}
+
+			err := errorIfPolicyPackDirectoryNotEmpty(dir)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, ErrDirectoryNotEmpty)
+				assert.Contains(t, err.Error(), "is not empty")
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// `pulumi new` still requires a directory without any entries.
+			if len(tt.dirs) > 0 || len(tt.files) > 0 {
+				assert.Error(t, errorIfNotEmptyDirectory(dir))
+			}
+		})
+	}
+}
+
+//nolint:paralleltest // sets environment variables
+func TestRetrievePolicyPackTemplatesByPrefix(t *testing.T) {
+	templateDir := t.TempDir()
+	t.Setenv("PULUMI_POLICY_TEMPLATE_PATH", templateDir)
+	for _, name := range []string{"aws-python", "aws-typescript", "azure-go"} {
+		dir := filepath.Join(templateDir, name)
+		require.NoError(t, os.Mkdir(dir, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"), []byte("runtime: nodejs\n"), 0600))
+	}
+
+	retrieveWithArgs := func(args newPolicyArgs) ([]string, error) {
+		templates, cleanup, err := retrievePolicyPackTemplates(args, ioutil.Discard, colors.Never)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+
+		var names []string
+		for _, template := range templates {
+			names = append(names, template.Name)
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+	retrieve := func(name string, interactive bool) ([]string, error) {
+		return retrieveWithArgs(newPolicyArgs{interactive: interactive, offline: true, templateNameOrURL: name})
+	}
+
+	t.Run("UniquePrefix", func(t *testing.T) {
+		names, err := retrieve("azure", false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"azure-go"}, names)
+
+		// Exact names still win.
+		names, err = retrieve("aws-python", false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"aws-python"}, names)
+	})
+
+	t.Run("AmbiguousPrefix", func(t *testing.T) {
+		// The matches are offered in the chooser...
+		names, err := retrieve("AWS", true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"aws-python", "aws-typescript"}, names)
+
+		// ...even with --yes, which only skips confirmations...
+		names, err = retrieveWithArgs(newPolicyArgs{interactive: true, yes: true, offline: true, templateNameOrURL: "aws"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"aws-python", "aws-typescript"}, names)
+
+		// ...but without prompts, there's no way to choose.
+		_, err = retrieve("aws", false)
+		require.Error(t, err)
+		assert.Equal(t, "template 'aws' not found; several templates start with it:\n"+
+			"\taws-python\n\taws-typescript\nrerun with the full name of the template you want", err.Error())
+	})
+
+	t.Run("NoMatch", func(t *testing.T) {
+		_, err := retrieve("azure-goo", false)
+		var notFound *workspace.TemplateNotFoundError
+		require.True(t, errors.As(err, &notFound), "unexpected error: %v", err)
+		assert.Equal(t, []string{"azure-go"}, notFound.Suggestions)
+		assert.Contains(t, err.Error(), "template 'azure-goo' not found")
+		assert.ErrorIs(t, err, ErrTemplateNotFound)
+	})
+}
+
+//nolint:paralleltest // sets environment variables
+func TestParsePolicyTemplateProxy(t *testing.T) {
+	t.Parallel()
+
+	proxy, err := parsePolicyTemplateProxy("")
+	assert.NoError(t, err)
+	assert.Nil(t, proxy, "no proxy falls back to the environment")
+
+	for _, raw := range []string{"http://proxy.example.com:8080", "https://proxy.example.com", "socks5://127.0.0.1:1080"} {
+		proxy, err := parsePolicyTemplateProxy(raw)
+		if assert.NoError(t, err, raw) {
+			assert.Equal(t, raw, proxy.String())
+		}
+	}
+
+	for _, raw := range []string{"proxy.example.com:8080", "ftp://proxy.example.com", "http://", "://bad"} {
+		_, err := parsePolicyTemplateProxy(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestPolicyPackTemplateNameOrURL(t *testing.T) {
+	t.Setenv(pulumiPolicyTemplateURLEnvVar, "")
+	assert.Equal(t, "", policyPackTemplateNameOrURL(nil))
+	assert.Equal(t, "aws-typescript", policyPackTemplateNameOrURL([]string{"aws-typescript"}))
+
+	const url = "https://github.com/example/policy-templates"
+	t.Setenv(pulumiPolicyTemplateURLEnvVar, url)
+	assert.Equal(t, url, policyPackTemplateNameOrURL(nil))
+	// A template on the command line takes precedence.
+	assert.Equal(t, "aws-typescript", policyPackTemplateNameOrURL([]string{"aws-typescript"}))
+}
+
+//nolint:paralleltest // sets environment variables
+func TestPolicyPackTemplateURLEnvVarIsRetrieved(t *testing.T) {
+	// The environment variable can name anything a template argument can, including a local directory of templates.
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "internal-pack"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "internal-pack", "PulumiPolicy.yaml"),
+		[]byte("runtime: nodejs\ndescription: Internal\n"), 0600))
+	t.Setenv(pulumiPolicyTemplateURLEnvVar, dir)
+
+	templates, cleanup, err := retrievePolicyPackTemplates(newPolicyArgs{
+		templateNameOrURL: policyPackTemplateNameOrURL(nil),
+	}, ioutil.Discard, colors.Never)
+	require.NoError(t, err)
+	defer cleanup()
+	if assert.Len(t, templates, 1) {
+		assert.Equal(t, "internal-pack", templates[0].Name)
+		assert.Equal(t, "Internal", templates[0].Description)
+	}
+}
+
+func TestPolicyPackPolicies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		file     string
+		source   string
+		expected []policyPackPolicy
+	}{
+		{
+			name: "TypeScript",
+			file: "index.ts",
+			source: `new PolicyPack("aws-typescript", {
+    policies: [{
+        name: "s3-no-public-read",
+        description: "Prohibits public S3 buckets.",
+        enforcementLevel: "mandatory",
+        validateResource: validateResourceOfType(aws.s3.Bucket, (bucket, args, reportViolation) => {}),
+    }, {
+        name: "ec2-tags",
+        description: "Requires tags.",
+        validateResource: validateResourceOfType(aws.ec2.Instance, (instance, args, reportViolation) => {}),
+    }],
+});
+`,
+			expected: []policyPackPolicy{
+				{Name: "s3-no-public-read", EnforcementLevel: "mandatory"},
+				{Name: "ec2-tags", EnforcementLevel: "advisory"},
+			},
+		},
+		{
+			name: "Python",
+			file: "__main__.py",
+			source: `s3_no_public_read = ResourceValidationPolicy(
+    name="s3-no-public-read",
+    description="Prohibits public S3 buckets.",
+    validate=s3_no_public_read_validator,
+)
+
+ec2_tags = ResourceValidationPolicy(
+    name="ec2-tags",
+    description="Requires tags.",
+    enforcement_level=EnforcementLevel.DISABLED,
+    validate=ec2_tags_validator,
+)
+
+PolicyPack(
+    name="aws-python",
+    enforcement_level=EnforcementLevel.MANDATORY,
+    policies=[
+        s3_no_public_read,
+        ec2_tags,
+    ],
+)
+`,
+			expected: []policyPackPolicy{
+				{Name: "s3-no-public-read", EnforcementLevel: "mandatory"},
+				{Name: "ec2-tags", EnforcementLevel: "disabled"},
+			},
+		},
+		{
+			name:   "NoPolicies",
+			file:   "main.go",
+			source: "package main\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := t.TempDir()
+			require.NoError(t, ioutil.WriteFile(filepath.Join(root, tt.file), []byte(tt.source), 0600))
+			policies, err := policyPackPolicies(root)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, policies)
+		})
+	}
+}
+
+//nolint:paralleltest // changes directory for process
+func TestNewPolicyPackWritesReadme(t *testing.T) {
+	searchPath := t.TempDir()
+	templateDir := filepath.Join(searchPath, "local-pack")
+	require.NoError(t, os.Mkdir(templateDir, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "PulumiPolicy.yaml"),
+		[]byte("runtime: nodejs\ndescription: Checks buckets\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "index.ts"), []byte(`new PolicyPack("local-pack", {
+    enforcementLevel: "mandatory",
+    policies: [{
+        name: "s3-no-public-read",
+        description: "Prohibits public S3 buckets.",
+    }, {
+        name: "ec2-tags",
+        description: "Requires tags.",
+        enforcementLevel: "advisory",
+    }],
+});
+`), 0600))
+
+	dir := t.TempDir()
+	chdir(t, dir)
+	err := runNewPolicyPack(context.TODO(), newPolicyArgs{
+		generateOnly:        true,
+		noGitignore:         true,
+		offline:             true,
+		readme:              true,
+		templateNameOrURL:   "local-pack",
+		templateSearchPaths: []string{searchPath},
+		yes:                 true,
+	})
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "# local-pack\n\n"+
+		"Checks buckets\n\n"+
+		"## Policies\n\n"+
+		"| Policy | Enforcement level |\n"+
+		"| --- | --- |\n"+
+		"| `s3-no-public-read` | mandatory |\n"+
+		"| `ec2-tags` | advisory |\n", string(b))
+
+	// An existing README.md is only replaced with --force, and one that ships with the template is never replaced.
+	readme := filepath.Join(dir, "README.md")
+	require.NoError(t, ioutil.WriteFile(readme, []byte("mine\n"), 0600))
+	overwrote, err := writePolicyPackReadme(templateDir, dir, "local-pack", nil, false)
+	require.NoError(t, err)
+	assert.False(t, overwrote)
+	overwrote, err = writePolicyPackReadme(templateDir, dir, "local-pack", nil, true)
+	require.NoError(t, err)
+	assert.True(t, overwrote)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "README.md"), []byte("template\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(readme, []byte("mine\n"), 0600))
+	overwrote, err = writePolicyPackReadme(templateDir, dir, "local-pack", nil, true)
+	require.NoError(t, err)
+	assert.False(t, overwrote)
+	b, err = ioutil.ReadFile(readme)
+	require.NoError(t, err)
+	assert.Equal(t, "mine\n", string(b))
+}
+
+//nolint:paralleltest // changes directory for process
+func TestNewPolicyPackAll(t *testing.T) {
+	searchPath, err := filepath.Abs(filepath.Join("testdata", "policy-templates"))
+	require.NoError(t, err)
+
+	// With --all, only the subdirectories that the Policy Packs are created in need to be empty.
+	dir := t.TempDir()
+	chdir(t, dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes\n"), 0600))
+
+	args := newPolicyArgs{
+		all:                 true,
+		generateOnly:        true,
+		noGitignore:         true,
+		offline:             true,
+		readme:              true,
+		templateNameOrURL:   "governance",
+		templateSearchPaths: []string{searchPath},
+		yes:                 true,
+	}
+	require.NoError(t, runNewPolicyPack(context.TODO(), args))
+
+	policies := map[string]string{
+		"security": "s3-no-public-read",
+		"cost":     "ec2-instance-size",
+		"tagging":  "required-tags",
+	}
+	for pack, policy := range policies {
+		b, err := ioutil.ReadFile(filepath.Join(dir, pack, "index.ts"))
+		require.NoError(t, err, pack)
+		assert.Contains(t, string(b), policy)
+
+		proj, err := workspace.LoadPolicyPack(filepath.Join(dir, pack, "PulumiPolicy.yaml"))
+		require.NoError(t, err, pack)
+		assert.Equal(t, "nodejs", proj.Runtime.Name())
+
+		b, err = ioutil.ReadFile(filepath.Join(dir, pack, "README.md"))
+		require.NoError(t, err, pack)
+		assert.Contains(t, string(b), "| `"+policy+"` |")
+	}
+	_, err = os.Stat(filepath.Join(dir, "PulumiPolicy.yaml"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Existing files in any of the subdirectories stop all of the Policy Packs from being created, unless --force is
+	// given.
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "tagging")))
+	err = runNewPolicyPack(context.TODO(), args)
+	assert.ErrorIs(t, err, ErrDirectoryNotEmpty)
+	assert.Contains(t, err.Error(), "is not empty")
+	_, err = os.Stat(filepath.Join(dir, "tagging"))
+	assert.True(t, os.IsNotExist(err))
+
+	args.force = true
+	require.NoError(t, runNewPolicyPack(context.TODO(), args))
+	_, err = os.Stat(filepath.Join(dir, "tagging", "index.ts"))
+	assert.NoError(t, err)
+
+	// Without --all, a template that bundles several Policy Packs is rejected.
+	args.all = false
+	err = runNewPolicyPack(context.TODO(), args)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rerun with --all")
+}
+
+func TestResolvePolicyPackTemplateParameters(t *testing.T) {
+	t.Parallel()
+
+	template, err := workspace.LoadPolicyPackTemplate(filepath.Join("testdata", "policy-templates", "tagged-resources"))
+	require.NoError(t, err)
+	require.Len(t, template.Parameters, 2)
+
+	// Without prompts, parameters are taken from --set or else their defaults.
+	variables, err := resolvePolicyPackTemplateParameters(template.Parameters, map[string]string{"ORG": "acme"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ORG": "acme", "ENVIRONMENT": "dev"}, variables)
+
+	_, err = resolvePolicyPackTemplateParameters(template.Parameters, nil, nil)
+	assert.EqualError(t, err, "template parameter 'ORG' is required; pass --set ORG=<value>")
+
+	// With prompts, each parameter that --set doesn't give a value is asked for.
+	var asked []string
+	answers := map[string]string{"ORG": "initech", "ENVIRONMENT": "prod"}
+	prompt := func(param workspace.PolicyPackTemplateParameter) (string, error) {
+		asked = append(asked, param.Name)
+		return answers[param.Name], nil
+	}
+	variables, err = resolvePolicyPackTemplateParameters(template.Parameters, nil, prompt)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ORG", "ENVIRONMENT"}, asked)
+	assert.Equal(t, answers, variables)
+
+	asked = nil
+	variables, err = resolvePolicyPackTemplateParameters(template.Parameters, map[string]string{"ORG": "acme"}, prompt)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ENVIRONMENT"}, asked)
+	assert.Equal(t, map[string]string{"ORG": "acme", "ENVIRONMENT": "prod"}, variables)
+
+	// A required parameter can't be answered with nothing.
+	answers["ORG"] = ""
+	_, err = resolvePolicyPackTemplateParameters(template.Parameters, nil, prompt)
+	assert.Error(t, err)
+
+	promptErr := errors.New("interrupted")
+	_, err = resolvePolicyPackTemplateParameters(template.Parameters, nil,
+		func(workspace.PolicyPackTemplateParameter) (string, error) { return "", promptErr })
+	assert.ErrorIs(t, err, promptErr)
+}
+
+//nolint:paralleltest // changes directory for process
+func TestNewPolicyPackTemplateParameters(t *testing.T) {
+	searchPath, err := filepath.Abs(filepath.Join("testdata", "policy-templates"))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	chdir(t, dir)
+
+	args := newPolicyArgs{
+		generateOnly:        true,
+		noGitignore:         true,
+		offline:             true,
+		templateNameOrURL:   "tagged-resources",
+		templateSearchPaths: []string{searchPath},
+		yes:                 true,
+	}
+
+	// A required parameter without a value stops the Policy Pack from being created.
+	err = runNewPolicyPack(context.TODO(), args)
+	assert.EqualError(t, err, "template parameter 'ORG' is required; pass --set ORG=<value>")
+	_, err = os.Stat(filepath.Join(dir, "index.ts"))
+	assert.True(t, os.IsNotExist(err))
+
+	args.variables = []string{"ORG=acme"}
+	require.NoError(t, runNewPolicyPack(context.TODO(), args))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "index.ts"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `new PolicyPack("acme-tagged-resources", {`)
+	assert.Contains(t, string(b), "tagged with the dev environment.")
+
+	// The parameters are part of the template's manifest, which isn't copied to the Policy Pack. The file is read
+	// directly because LoadPolicyPack caches the project it loaded before the manifest was removed.
+	b, err = ioutil.ReadFile(filepath.Join(dir, "PulumiPolicy.yaml"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "template:")
+	assert.NotContains(t, string(b), "ORG")
+}
+
+//nolint:paralleltest // changes directory for process, sets environment variables
+func TestNewPolicyPackUsesProjectLanguage(t *testing.T) {
+	templateDir := t.TempDir()
+	t.Setenv("PULUMI_POLICY_TEMPLATE_PATH", templateDir)
+	templates := map[string]string{
+		"aws-typescript": "nodejs",
+		"aws-javascript": "nodejs",
+		"aws-python":     "python",
+	}
+	for name, runtime := range templates {
+		dir := filepath.Join(templateDir, name)
+		require.NoError(t, os.Mkdir(dir, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"),
+			[]byte("runtime: "+runtime+"\n"), 0600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "template.txt"), []byte(name), 0600))
+	}
+
+	// newPolicyPack creates a Policy Pack in the policy subdirectory of a project with the given Pulumi.yaml and
+	// returns the name of the template that it was created from.
+	newPolicyPack := func(t *testing.T, project, language string) string {
+		dir := t.TempDir()
+		chdir(t, dir)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Pulumi.yaml"), []byte(project), 0600))
+
+		err := runNewPolicyPack(context.TODO(), newPolicyArgs{
+			dir:          "policy",
+			generateOnly: true,
+			language:     language,
+			noGitignore:  true,
+			offline:      true,
+			yes:          true,
+		})
+		require.NoError(t, err)
+		b, err := ioutil.ReadFile(filepath.Join(dir, "policy", "template.txt"))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	t.Run("TypeScript", func(t *testing.T) {
+		assert.Equal(t, "aws-typescript", newPolicyPack(t, "name: app\nruntime: nodejs\n", ""))
+	})
+	t.Run("JavaScript", func(t *testing.T) {
+		project := "name: app\nruntime:\n  name: nodejs\n  options:\n    typescript: false\n"
+		assert.Equal(t, "aws-javascript", newPolicyPack(t, project, ""))
+	})
+	t.Run("Python", func(t *testing.T) {
+		assert.Equal(t, "aws-python", newPolicyPack(t, "name: app\nruntime: python\n", ""))
+	})
+	t.Run("LanguageFlagWins", func(t *testing.T) {
+		assert.Equal(t, "aws-python", newPolicyPack(t, "name: app\nruntime: nodejs\n", "python"))
+	})
+}
+
+//nolint:paralleltest // changes directory for process, sets environment variables, mutates policyPackInstallers
+func TestNewPolicyPackDirCleanup(t *testing.T) {
+	templateDir := t.TempDir()
+	t.Setenv("PULUMI_POLICY_TEMPLATE_PATH", templateDir)
+	require.NoError(t, os.Mkdir(filepath.Join(templateDir, "gcp-go"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templateDir, "gcp-go", "PulumiPolicy.yaml"),
+		[]byte("runtime: go\n"), 0600))
+
+	original := policyPackInstallers
+	defer func() { policyPackInstallers = original }()
+	installErr := errors.New("boom")
+	var installRoot string
+	policyPackInstallers = map[string]policyPackInstaller{
+		"go": func(_ context.Context, _ *workspace.PolicyPackProject, _, root string, _ io.Writer) error {
+			installRoot = root
+			return installErr
+		},
+	}
+
+	chdir(t, t.TempDir())
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	args := newPolicyArgs{
+		dir:         filepath.Join("nested", "policy"),
+		noGitignore: true,
+		offline:     true,
+		yes:         true,
+	}
+	packDir := filepath.Join(dir, "nested", "policy")
+
+	// A failure before anything is written removes the directory that was created for the Policy Pack.
+	args.templateNameOrURL = "aws-typescript"
+	err = runNewPolicyPack(context.TODO(), args)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.NotContains(t, err.Error(), "partially created")
+	_, err = os.Stat(packDir)
+	assert.True(t, os.IsNotExist(err), "unexpected error: %v", err)
+
+	// A failure after files are written leaves them in place and says where they are.
+	args.templateNameOrURL = "gcp-go"
+	err = runNewPolicyPack(context.TODO(), args)
+	assert.ErrorIs(t, err, installErr)
+	assert.ErrorIs(t, err, ErrDependencyInstall)
+	assert.Contains(t, err.Error(), "The partially created Policy Pack was left in "+packDir)
+	assert.FileExists(t, filepath.Join(packDir, "PulumiPolicy.yaml"))
+
+	// The Policy Pack is created by path; the working directory is left unchanged.
+	assert.Equal(t, packDir, installRoot)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, dir, cwd)
+
+	// A directory that existed beforehand is left alone.
+	require.NoError(t, os.RemoveAll(packDir))
+	require.NoError(t, os.MkdirAll(packDir, 0700))
+	args.templateNameOrURL = "aws-typescript"
+	err = runNewPolicyPack(context.TODO(), args)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.DirExists(t, packDir)
+}
+
+//nolint:paralleltest // changes directory for process, sets environment variables
+func TestNewPolicyPackMultipleLanguages(t *testing.T) {
+	templateDir := t.TempDir()
+	t.Setenv("PULUMI_POLICY_TEMPLATE_PATH", templateDir)
+	templates := map[string]string{
+		"aws-typescript": "nodejs",
+		"aws-python":     "python",
+		"gcp-python":     "python",
+		"gcp-go":         "go",
+	}
+	for name, runtime := range templates {
+		dir := filepath.Join(templateDir, name)
+		require.NoError(t, os.Mkdir(dir, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"),
+			[]byte("runtime: "+runtime+"\n"), 0600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "template.txt"), []byte(name), 0600))
+	}
+
+	newPolicyPacks := func(t *testing.T, language string) (string, error) {
+		dir := t.TempDir()
+		chdir(t, dir)
+		return dir, runNewPolicyPack(context.TODO(), newPolicyArgs{
+			generateOnly: true,
+			language:     language,
+			noGitignore:  true,
+			offline:      true,
+			yes:          true,
+		})
+	}
+
+	t.Run("TwoLanguages", func(t *testing.T) {
+		// The variants of the only pack that is available in both languages are created side by side.
+		dir, err := newPolicyPacks(t, "ts,python")
+		require.NoError(t, err)
+
+		expected := map[string]string{"ts": "aws-typescript", "python": "aws-python"}
+		for subdir, template := range expected {
+			b, err := ioutil.ReadFile(filepath.Join(dir, subdir, "template.txt"))
+			require.NoError(t, err, subdir)
+			assert.Equal(t, template, string(b))
+		}
+		proj, err := workspace.LoadPolicyPack(filepath.Join(dir, "python", "PulumiPolicy.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "python", proj.Runtime.Name())
+	})
+
+	t.Run("PartialMatch", func(t *testing.T) {
+		// A language without templates stops any of the Policy Packs from being created.
+		dir, err := newPolicyPacks(t, "ts,dotnet")
+		require.Error(t, err)
+		assert.Equal(t, "no templates found for language 'dotnet'", err.Error())
+		assert.ErrorIs(t, err, ErrTemplateNotFound)
+
+		infos, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, infos)
+	})
+
+	t.Run("NoCommonPack", func(t *testing.T) {
+		dir, err := newPolicyPacks(t, "ts,go")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no template is available in all of the languages ts, go")
+
+		infos, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, infos)
+	})
+}
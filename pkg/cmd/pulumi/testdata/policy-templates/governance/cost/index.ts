@@ -0,0 +1,10 @@
+import { PolicyPack } from "@pulumi/policy";
+
+new PolicyPack("cost", {
+    policies: [{
+        name: "ec2-instance-size",
+        description: "Limits the size of EC2 instances.",
+        enforcementLevel: "advisory",
+        validateResource: (args, reportViolation) => {},
+    }],
+});
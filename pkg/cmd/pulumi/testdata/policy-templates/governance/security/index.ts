@@ -0,0 +1,10 @@
+import { PolicyPack } from "@pulumi/policy";
+
+new PolicyPack("security", {
+    policies: [{
+        name: "s3-no-public-read",
+        description: "Prohibits public S3 buckets.",
+        enforcementLevel: "mandatory",
+        validateResource: (args, reportViolation) => {},
+    }],
+});
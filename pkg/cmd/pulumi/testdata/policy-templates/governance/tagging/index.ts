@@ -0,0 +1,10 @@
+import { PolicyPack } from "@pulumi/policy";
+
+new PolicyPack("tagging", {
+    policies: [{
+        name: "required-tags",
+        description: "Requires resources to be tagged.",
+        enforcementLevel: "mandatory",
+        validateResource: (args, reportViolation) => {},
+    }],
+});
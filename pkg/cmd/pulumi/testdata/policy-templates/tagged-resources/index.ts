@@ -0,0 +1,10 @@
+import { PolicyPack } from "@pulumi/policy";
+
+new PolicyPack("${ORG}-tagged-resources", {
+    policies: [{
+        name: "required-tags",
+        description: "Requires resources to be tagged with the ${ENVIRONMENT} environment.",
+        enforcementLevel: "mandatory",
+        validateResource: (args, reportViolation) => {},
+    }],
+});
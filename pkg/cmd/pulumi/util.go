@@ -497,7 +497,12 @@ func readPolicyProject() (*workspace.PolicyPackProject, string, string, error) {
 	if err != nil {
 		return nil, "", "", err
 	}
+	return readPolicyProjectFrom(pwd)
+}
 
+// readPolicyProjectFrom is like readPolicyProject, but searches for the PulumiPolicy.yaml file upwards from pwd
+// instead of from the current working directory.
+func readPolicyProjectFrom(pwd string) (*workspace.PolicyPackProject, string, string, error) {
 	// Now that we got here, we have a path, so we will try to load it.
 	path, err := workspace.DetectPolicyPackPathFrom(pwd)
 	if err != nil {
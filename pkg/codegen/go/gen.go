@@ -26,11 +26,14 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"unicode"
 
+	"github.com/blang/semver"
+
 	"github.com/pulumi/pulumi/pkg/v3/codegen"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
@@ -146,6 +149,9 @@ type pkgContext struct {
 	tool            string
 	packages        map[string]*pkgContext
 
+	// envParsers tracks the optional environment variable parsers that must be emitted in the package's utilities.
+	envParsers codegen.StringSet
+
 	// Name overrides set in GoPackageInfo
 	modToPkg         map[string]string // Module name -> package name
 	pkgImportAliases map[string]string // Package name -> import alias
@@ -853,6 +859,37 @@ func printCommentWithDeprecationMessage(w io.Writer, comment, deprecationMessage
 	}
 }
 
+// propertyComment returns the doc comment for the struct field of the given input property. If the property's
+// default value is read from environment variables, the comment lists them in the order they are checked.
+func propertyComment(p *schema.Property) string {
+	if p.DefaultValue == nil || len(p.DefaultValue.Environment) == 0 {
+		return p.Comment
+	}
+
+	var envComment string
+	if vars := p.DefaultValue.Environment; len(vars) == 1 {
+		envComment = fmt.Sprintf("If unset, defaults to the value of the %s environment variable.", vars[0])
+	} else {
+		envComment = fmt.Sprintf("If unset, defaults to the value of the first of the %s environment variables "+
+			"that is set, checked in that order.", strings.Join(vars, ", "))
+	}
+
+	comment := strings.TrimRight(p.Comment, "\n")
+	if comment == "" {
+		return envComment
+	}
+	return comment + "\n\n" + envComment
+}
+
+// objectPropertyComment is like propertyComment, but for the properties of object types, whose defaults are only
+// applied if object defaults are enabled for the package.
+func (pkg *pkgContext) objectPropertyComment(p *schema.Property) string {
+	if pkg.disableObjectDefaults {
+		return p.Comment
+	}
+	return propertyComment(p)
+}
+
 func (pkg *pkgContext) genInputInterface(w io.Writer, name string) {
 	printComment(w, pkg.getInputUsage(name), false)
 	fmt.Fprintf(w, "type %sInput interface {\n", name)
@@ -1295,7 +1332,7 @@ func (pkg *pkgContext) genPlainType(w io.Writer, name, comment, deprecationMessa
 	printCommentWithDeprecationMessage(w, comment, deprecationMessage, false)
 	fmt.Fprintf(w, "type %s struct {\n", name)
 	for _, p := range properties {
-		printCommentWithDeprecationMessage(w, p.Comment, p.DeprecationMessage, true)
+		printCommentWithDeprecationMessage(w, pkg.objectPropertyComment(p), p.DeprecationMessage, true)
 		fmt.Fprintf(w, "\t%s %s `pulumi:\"%s\"`\n", Title(p.Name), pkg.typeString(codegen.ResolvedType(p.Type)), p.Name)
 	}
 	fmt.Fprintf(w, "}\n\n")
@@ -1424,7 +1461,7 @@ func (pkg *pkgContext) genInputArgsStruct(w io.Writer, typeName string, t *schem
 	printComment(w, t.Comment, false)
 	fmt.Fprintf(w, "type %s struct {\n", typeName)
 	for _, p := range t.Properties {
-		printCommentWithDeprecationMessage(w, p.Comment, p.DeprecationMessage, true)
+		printCommentWithDeprecationMessage(w, pkg.objectPropertyComment(p), p.DeprecationMessage, true)
 		fmt.Fprintf(w, "\t%s %s `pulumi:\"%s\"`\n", Title(p.Name), pkg.typeString(p.Type), p.Name)
 	}
 	fmt.Fprintf(w, "}\n\n")
@@ -1573,26 +1610,79 @@ func (pkg *pkgContext) getDefaultValue(dv *schema.DefaultValue, t schema.Type) (
 
 	if len(dv.Environment) > 0 {
 		pkg.needsUtils = true
+		pkg.envParsers.Add("logEnvDefault")
+
+		var info GoDefaultInfo
+		if i, ok := dv.Language["go"].(GoDefaultInfo); ok {
+			info = i
+		}
 
 		parser, typDefault, typ := "nil", "\"\"", "string"
-		switch codegen.UnwrapType(t).(type) {
+		switch t := codegen.UnwrapType(t).(type) {
 		case *schema.ArrayType:
+			if codegen.UnwrapType(t.ElementType) == schema.AnyType {
+				pkg.envParsers.Add("parseEnvJSON")
+				parser, typDefault, typ = "parseEnvJSON", "pulumi.Array{}", "pulumi.Array"
+				break
+			}
 			parser, typDefault, typ = "parseEnvStringArray", "pulumi.StringArray{}", "pulumi.StringArray"
+			if info.EnvironmentFormat == "flexibleList" {
+				pkg.envParsers.Add("parseEnvStringArrayFlexible")
+				parser = "parseEnvStringArrayFlexible"
+			} else if delimiter := info.EnvironmentDelimiter; delimiter != "" && delimiter != ";" {
+				pkg.envParsers.Add("parseEnvStringArrayWithDelimiter")
+				parser = fmt.Sprintf("parseEnvStringArrayWithDelimiter(%q)", delimiter)
+			}
+		case *schema.MapType:
+			switch codegen.UnwrapType(t.ElementType) {
+			case schema.StringType:
+				pkg.envParsers.Add("parseEnvStringMap")
+				parser, typDefault, typ = "parseEnvStringMap", "pulumi.StringMap{}", "pulumi.StringMap"
+			case schema.AnyType:
+				pkg.envParsers.Add("parseEnvJSON")
+				parser, typDefault, typ = "parseEnvJSON", "pulumi.Map{}", "pulumi.Map"
+			}
 		}
 		switch t {
+		case schema.StringType:
+			switch info.EnvironmentFormat {
+			case "duration":
+				pkg.envParsers.Add("parseEnvDuration")
+				parser = "parseEnvDuration"
+			case "base64":
+				pkg.envParsers.Add("parseEnvBase64")
+				parser = "parseEnvBase64"
+			}
 		case schema.BoolType:
 			parser, typDefault, typ = "parseEnvBool", "false", "bool"
 		case schema.IntType:
 			parser, typDefault, typ = "parseEnvInt", "0", "int"
 		case schema.NumberType:
 			parser, typDefault, typ = "parseEnvFloat", "0.0", "float64"
+			if info.EnvironmentFormat == "percentage" {
+				pkg.envParsers.Add("parseEnvPercentage")
+				parser = "parseEnvPercentage"
+			}
 		}
 
 		if val == "" {
 			val = typDefault
 		}
 
-		val = fmt.Sprintf("getEnvOrDefault(%s, %s", val, parser)
+		switch {
+		case info.EnvironmentAllowEmpty:
+			if info.EnvironmentType != "" {
+				pkg.envParsers.Add("getEnvOrDefaultByType")
+				parser = fmt.Sprintf("envParsers[%q]", info.EnvironmentType)
+			}
+			pkg.envParsers.Add("lookupEnvOrDefault")
+			val = fmt.Sprintf("lookupEnvOrDefault(%s, %s", val, parser)
+		case info.EnvironmentType != "":
+			pkg.envParsers.Add("getEnvOrDefaultByType")
+			val = fmt.Sprintf("getEnvOrDefaultByType(%s, %q", val, info.EnvironmentType)
+		default:
+			val = fmt.Sprintf("getEnvOrDefault(%s, %s", val, parser)
+		}
 		for _, e := range dv.Environment {
 			val += fmt.Sprintf(", %q", e)
 		}
@@ -1823,7 +1913,7 @@ func (pkg *pkgContext) genResource(w io.Writer, r *schema.Resource, generateReso
 	// Emit the args types.
 	fmt.Fprintf(w, "type %sArgs struct {\n", camel(name))
 	for _, p := range r.InputProperties {
-		printCommentWithDeprecationMessage(w, p.Comment, p.DeprecationMessage, true)
+		printCommentWithDeprecationMessage(w, propertyComment(p), p.DeprecationMessage, true)
 		fmt.Fprintf(w, "\t%s %s `pulumi:\"%s\"`\n", Title(p.Name), pkg.typeString(codegen.ResolvedType(p.Type)), p.Name)
 	}
 	fmt.Fprintf(w, "}\n\n")
@@ -1841,7 +1931,7 @@ func (pkg *pkgContext) genResource(w io.Writer, r *schema.Resource, generateReso
 			})
 		}
 
-		printCommentWithDeprecationMessage(w, p.Comment, p.DeprecationMessage, true)
+		printCommentWithDeprecationMessage(w, propertyComment(p), p.DeprecationMessage, true)
 		fmt.Fprintf(w, "\t%s %s\n", Title(p.Name), pkg.typeString(typ))
 	}
 	fmt.Fprintf(w, "}\n\n")
@@ -3021,6 +3111,7 @@ func generatePackageContextMap(tool string, pkg *schema.Package, goInfo GoPackag
 				renamed:                       map[string]string{},
 				duplicateTokens:               map[string]bool{},
 				functionNames:                 map[*schema.Function]string{},
+				envParsers:                    codegen.NewStringSet(),
 				tool:                          tool,
 				modToPkg:                      goInfo.ModuleToPackage,
 				pkgImportAliases:              goInfo.PackageImportAliases,
@@ -3543,6 +3634,17 @@ func GeneratePackage(tool string, pkg *schema.Package) (map[string][]byte, error
 	}
 	files[path.Join(pathPrefix, "pulumi-plugin.json")] = pulumiPluginJSON
 
+	if version := goPkgInfo.PinnedVersion; version != "" {
+		if _, err := semver.Parse(version); err != nil {
+			return nil, fmt.Errorf("invalid pinnedVersion %q: %w", version, err)
+		}
+	}
+
+	// Generate version.txt, which is embedded into the root module.
+	if goPkgInfo.EmbedVersion && goPkgInfo.PinnedVersion == "" && pkg.Version != nil {
+		files[path.Join(pathPrefix, "version.txt")] = []byte(pkg.Version.String() + "\n")
+	}
+
 	setFile := func(relPath, contents string) {
 		relPath = path.Join(pathPrefix, relPath)
 		if _, ok := files[relPath]; ok {
@@ -3711,9 +3813,21 @@ func GeneratePackage(tool string, pkg *schema.Package) (map[string][]byte, error
 				"github.com/blang/semver":                   "",
 				"github.com/pulumi/pulumi/sdk/v3/go/pulumi": "",
 			}
-			pkg.genHeader(buffer, []string{"fmt", "os", "reflect", "regexp", "strconv", "strings"}, importsAndAliases)
+			goImports := codegen.NewStringSet("os", "reflect", "strconv", "strings")
+			if pkg.pinnedVersion() == "" {
+				// These are only needed to determine the package's version at runtime.
+				goImports.Add("fmt")
+				goImports.Add("regexp")
+			}
+			for _, i := range pkg.envParserImports() {
+				goImports.Add(i)
+			}
+			if pkg.embedsVersion() {
+				goImports.Add(`_ "embed"`)
+			}
+			pkg.genHeader(buffer, goImports.SortedValues(), importsAndAliases)
 
-			packageRegex := fmt.Sprintf("^.*/pulumi-%s/sdk(/v\\d+)?", pkg.pkg.Name)
+			packageRegex := fmt.Sprintf("^.*/pulumi-%s/sdk(/v\\d+)?", regexp.QuoteMeta(pkg.pkg.Name))
 			if pkg.rootPackageName != "" {
 				packageRegex = fmt.Sprintf("^%s(/v\\d+)?", pkg.importBasePath)
 			}
@@ -3753,12 +3867,20 @@ func (pkg *pkgContext) GenUtilitiesFile(w io.Writer, packageRegex string) {
 	const utilitiesFile = `
 type envParser func(v string) interface{}
 
+// parseEnvBool parses v with strconv.ParseBool. It also accepts the words "yes", "y", "on", "enable", and "enabled"
+// for true and "no", "n", "off", "disable", and "disabled" for false, ignoring case. It returns nil if v is none of
+// these, so that the default value applies.
 func parseEnvBool(v string) interface{} {
-	b, err := strconv.ParseBool(v)
-	if err != nil {
-		return nil
+	if b, err := strconv.ParseBool(v); err == nil {
+		return b
+	}
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "yes", "y", "on", "enable", "enabled":
+		return true
+	case "no", "n", "off", "disable", "disabled":
+		return false
 	}
-	return b
+	return nil
 }
 
 func parseEnvInt(v string) interface{} {
@@ -3777,54 +3899,322 @@ func parseEnvFloat(v string) interface{} {
 	return f
 }
 
+// parseEnvStringArray splits v on ";". Surrounding whitespace is trimmed from each element and empty elements are
+// dropped, so "a; b;" yields ["a", "b"], and a value with no elements yields an empty array.
 func parseEnvStringArray(v string) interface{} {
-	var result pulumi.StringArray
+	result := pulumi.StringArray{}
 	for _, item := range strings.Split(v, ";") {
-		result = append(result, pulumi.String(item))
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, pulumi.String(item))
+		}
 	}
 	return result
 }
 
+// getEnvOrDefault returns the value of the first of vars that is set to a non-empty string, parsed by parser unless
+// it is nil. If none of vars is set, or the parser cannot parse the value, def is returned.
 func getEnvOrDefault(def interface{}, parser envParser, vars ...string) interface{} {
 	for _, v := range vars {
-		if value := os.Getenv(v); value != "" {
-			if parser != nil {
-				return parser(value)
+		if value := os.Getenv(v); value != "" {%[1]s
+			if parser == nil {
+				return value
 			}
-			return value
+			if result := parser(value); result != nil {
+				return result
+			}
+			return def
 		}
 	}
 	return def
 }
 
+%[2]s
+// isZero is a null safe check for if a value is it's types zero value.
+func isZero(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	return reflect.ValueOf(v).IsZero()
+}
+`
+	var pkgVersion string
+	if version := pkg.pinnedVersion(); version != "" {
+		pkgVersion = fmt.Sprintf(pinnedPkgVersion, version)
+	} else {
+		versionFallback := ""
+		if pkg.embedsVersion() {
+			versionFallback = `
+	if v, err := semver.ParseTolerant(strings.TrimSpace(embeddedVersion)); err == nil {
+		return v, nil
+	}`
+			_, err := fmt.Fprint(w, "\n//go:embed version.txt\nvar embeddedVersion string\n")
+			contract.AssertNoError(err)
+		}
+		pkgVersion = fmt.Sprintf(reflectedPkgVersion, packageRegex, versionFallback)
+	}
+
+	// Packages with environment defaults log the variable that supplies each of them under PULUMI_DEBUG_ENV.
+	var logEnvDefault string
+	if pkg.envParsers.Has("logEnvDefault") {
+		logEnvDefault = `
+			if debugEnvDefaults() {
+				logEnvDefault(v, vars)
+			}`
+	}
+
+	_, err := fmt.Fprintf(w, utilitiesFile, logEnvDefault, pkgVersion)
+	contract.AssertNoError(err)
+	pkg.genEnvParsers(w)
+	pkg.GenPkgDefaultOpts(w)
+}
+
+// embedsVersion returns true if the package's version should be embedded in its root module.
+func (pkg *pkgContext) embedsVersion() bool {
+	if pkg.mod != "" || pkg.pkg.Version == nil {
+		return false
+	}
+	info, ok := pkg.pkg.Language["go"].(GoPackageInfo)
+	return ok && info.EmbedVersion && info.PinnedVersion == ""
+}
+
+// pinnedVersion returns the version that the package's PkgVersion function returns, or "" if PkgVersion determines
+// the version at runtime.
+func (pkg *pkgContext) pinnedVersion() string {
+	info, ok := pkg.pkg.Language["go"].(GoPackageInfo)
+	if !ok {
+		return ""
+	}
+	return info.PinnedVersion
+}
+
+// reflectedPkgVersion is the PkgVersion function for packages whose version is determined at runtime. It is
+// formatted with the regex that matches the package's import path and the code that runs if the regex does not match.
+const reflectedPkgVersion = `// pkgVersionRegexp matches the import path of this package. It is compiled once
+// rather than by each call to PkgVersion.
+var pkgVersionRegexp = regexp.MustCompile(%q)
+
 // PkgVersion uses reflection to determine the version of the current package.
 // If a version cannot be determined, v1 will be assumed. The second return
 // value is always nil.
 func PkgVersion() (semver.Version, error) {
 	type sentinal struct{}
 	pkgPath := reflect.TypeOf(sentinal{}).PkgPath()
-	re := regexp.MustCompile(%q)
-	if match := re.FindStringSubmatch(pkgPath); match != nil {
+	if match := pkgVersionRegexp.FindStringSubmatch(pkgPath); match != nil {
 		vStr := match[1]
 		if len(vStr) == 0 { // If the version capture group was empty, default to v1.
 			return semver.Version{Major: 1}, nil
 		}
 		return semver.MustParse(fmt.Sprintf("%%s.0.0", vStr[2:])), nil
-	}
+	}%s
 	return semver.Version{Major: 1}, nil
 }
+`
 
-// isZero is a null safe check for if a value is it's types zero value.
-func isZero(v interface{}) bool {
-	if v == nil {
-		return true
-	}
-	return reflect.ValueOf(v).IsZero()
+// pinnedPkgVersion is the PkgVersion function for packages whose version was pinned when they were generated. It is
+// formatted with the version.
+const pinnedPkgVersion = `// pkgVersion is the version of this package, which was pinned when the package was generated.
+const pkgVersion = %q
+
+// PkgVersion returns the version of the current package. The second return value is always nil.
+func PkgVersion() (semver.Version, error) {
+	return semver.MustParse(pkgVersion), nil
 }
 `
-	_, err := fmt.Fprintf(w, utilitiesFile, packageRegex)
-	contract.AssertNoError(err)
-	pkg.GenPkgDefaultOpts(w)
+
+// optionalEnvParsers holds the environment variable parsers that are only emitted into a package's utilities when
+// one of the package's default values requires them.
+var optionalEnvParsers = map[string]string{
+	"parseEnvStringArrayWithDelimiter": `
+// parseEnvStringArrayWithDelimiter returns a parser like parseEnvStringArray that splits on the given delimiter.
+func parseEnvStringArrayWithDelimiter(delimiter string) envParser {
+	return func(v string) interface{} {
+		result := pulumi.StringArray{}
+		for _, item := range strings.Split(v, delimiter) {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, pulumi.String(item))
+			}
+		}
+		return result
+	}
+}
+`,
+	"parseEnvStringArrayFlexible": `
+// parseEnvStringArrayFlexible splits v on any run of commas and whitespace, so "a,b", "a b", and "a, b" all yield
+// ["a", "b"]. Empty elements are dropped, and a value with no elements yields an empty array.
+func parseEnvStringArrayFlexible(v string) interface{} {
+	result := pulumi.StringArray{}
+	for _, item := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		result = append(result, pulumi.String(item))
+	}
+	return result
+}
+`,
+	"parseEnvStringMap": `
+func parseEnvStringMap(v string) interface{} {
+	result := pulumi.StringMap{}
+	for _, item := range strings.Split(v, ";") {
+		pair := strings.SplitN(item, "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			continue
+		}
+		result[pair[0]] = pulumi.String(pair[1])
+	}
+	return result
+}
+`,
+	"getEnvOrDefaultByType": `
+// envParsers maps type tokens to the parsers used for environment variable defaults of those types. Hand-written code
+// in this package may register parsers for custom types, e.g. from an init function.
+var envParsers = map[string]envParser{
+	"boolean": parseEnvBool,
+	"integer": parseEnvInt,
+	"number":  parseEnvFloat,
+	"array":   parseEnvStringArray,
+}
+
+func getEnvOrDefaultByType(def interface{}, typ string, vars ...string) interface{} {
+	return getEnvOrDefault(def, envParsers[typ], vars...)
+}
+`,
+	"logEnvDefault": `
+// debugEnvDefaults returns true if PULUMI_DEBUG_ENV is set to a true value, in which case the environment variable
+// that supplies each default value is logged.
+func debugEnvDefaults() bool {
+	return parseEnvBool(os.Getenv("PULUMI_DEBUG_ENV")) == true
+}
+
+// logEnvDefault logs that the environment variable name supplied a default value for which vars were candidates.
+func logEnvDefault(name string, vars []string) {
+	log.Printf("using environment variable %s for a default value (candidates: %s)", name, strings.Join(vars, ", "))
+}
+`,
+	"lookupEnvOrDefault": `
+// lookupEnvOrDefault is like getEnvOrDefault, but uses the first of vars that is set, even if it is set to the empty
+// string. If the parser cannot parse the value, def is returned.
+func lookupEnvOrDefault(def interface{}, parser envParser, vars ...string) interface{} {
+	for _, v := range vars {
+		if value, ok := os.LookupEnv(v); ok {
+			if debugEnvDefaults() {
+				logEnvDefault(v, vars)
+			}
+			if parser == nil {
+				return value
+			}
+			if result := parser(value); result != nil {
+				return result
+			}
+			return def
+		}
+	}
+	return def
+}
+`,
+	"parseEnvJSON": `
+// parseEnvJSON parses a JSON object or array into a pulumi.Map or pulumi.Array, respectively. It returns nil if the
+// value is not a valid JSON object or array.
+func parseEnvJSON(v string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(v), &value); err != nil {
+		return nil
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return envJSONToInput(value)
+	default:
+		return nil
+	}
+}
+
+func envJSONToInput(v interface{}) pulumi.Input {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		result := pulumi.Map{}
+		for key, element := range v {
+			result[key] = envJSONToInput(element)
+		}
+		return result
+	case []interface{}:
+		result := pulumi.Array{}
+		for _, element := range v {
+			result = append(result, envJSONToInput(element))
+		}
+		return result
+	case string:
+		return pulumi.String(v)
+	case float64:
+		return pulumi.Float64(v)
+	case bool:
+		return pulumi.Bool(v)
+	default:
+		return pulumi.Any(v)
+	}
+}
+`,
+	"parseEnvPercentage": `
+// parseEnvPercentage parses v as a number like parseEnvFloat, but ignores surrounding whitespace and accepts an
+// optional trailing "%", in which case the value is converted to a fraction: "50%" yields 0.5, as does "0.5". It
+// returns nil if v is not a number or is outside the range 0 to 1, i.e. 0% to 100%, so that the default value applies.
+func parseEnvPercentage(v string) interface{} {
+	v = strings.TrimSpace(v)
+	scale := 1.0
+	if strings.HasSuffix(v, "%") {
+		v, scale = strings.TrimSpace(strings.TrimSuffix(v, "%")), 100
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || !(f/scale >= 0 && f/scale <= 1) {
+		return nil
+	}
+	return f / scale
+}
+`,
+	"parseEnvDuration": `
+func parseEnvDuration(v string) interface{} {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return nil
+	}
+	return d.String()
+}
+`,
+	"parseEnvBase64": `
+// parseEnvBase64 decodes v from standard base64, ignoring surrounding whitespace, and returns the decoded bytes as a
+// string. It returns nil if v is not valid base64, so that the default value applies.
+func parseEnvBase64(v string) interface{} {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+`,
+}
+
+// optionalEnvParserImports holds the standard library imports required by each of the optional environment variable
+// parsers.
+var optionalEnvParserImports = map[string][]string{
+	"logEnvDefault":               {"log"},
+	"parseEnvBase64":              {"encoding/base64"},
+	"parseEnvDuration":            {"time"},
+	"parseEnvJSON":                {"encoding/json"},
+	"parseEnvStringArrayFlexible": {"unicode"},
+}
+
+// envParserImports returns the standard library imports required by the package's optional environment variable
+// parsers.
+func (pkg *pkgContext) envParserImports() []string {
+	var imports []string
+	for _, name := range pkg.envParsers.SortedValues() {
+		imports = append(imports, optionalEnvParserImports[name]...)
+	}
+	return imports
+}
+
+// genEnvParsers emits the optional environment variable parsers required by the package.
+func (pkg *pkgContext) genEnvParsers(w io.Writer) {
+	for _, name := range pkg.envParsers.SortedValues() {
+		_, err := fmt.Fprint(w, optionalEnvParsers[name])
+		contract.AssertNoError(err)
+	}
 }
 
 func (pkg *pkgContext) GenPkgDefaultOpts(w io.Writer) {
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -403,3 +404,192 @@ import (
 	}
 	assert.Truef(t, found, `Didn't find a line that complies with "%v"`, autogenerated)
 }
+
+func TestGenerateEnvDefaults(t *testing.T) {
+	t.Parallel()
+
+	pkg := readSchemaFile(filepath.Join("schema", "go-env-defaults.json"))
+	files, err := GeneratePackage("test", pkg)
+	require.NoError(t, err)
+
+	resource := string(files["envdefaults/settings.go"])
+	utilities := string(files["envdefaults/pulumiUtilities.go"])
+
+	// Array defaults are split on ";" unless the schema specifies a delimiter.
+	assert.Contains(t, resource,
+		`getEnvOrDefault(pulumi.StringArray{}, parseEnvStringArray, "ENV_DEFAULTS_HOSTS").(pulumi.StringArray)`)
+	assert.Contains(t, resource,
+		`getEnvOrDefault(pulumi.StringArray{}, parseEnvStringArrayWithDelimiter(","), "ENV_DEFAULTS_COMMA_HOSTS")`)
+	assert.Contains(t, resource,
+		`getEnvOrDefault(pulumi.StringArray{}, parseEnvStringArrayWithDelimiter("\n"), "ENV_DEFAULTS_LINE_HOSTS")`)
+	assert.Contains(t, utilities, "func parseEnvStringArrayWithDelimiter(delimiter string) envParser {")
+	// Elements are trimmed and empty elements dropped.
+	assert.Contains(t, utilities, "\t\t\tif item = strings.TrimSpace(item); item != \"\" {\n")
+
+	// Flexible lists are split on commas and whitespace alike.
+	assert.Contains(t, resource,
+		`getEnvOrDefault(pulumi.StringArray{}, parseEnvStringArrayFlexible, "ENV_DEFAULTS_FLEXIBLE_HOSTS")`)
+	assert.Contains(t, utilities, "func parseEnvStringArrayFlexible(v string) interface{} {")
+	assert.Contains(t, utilities, "\t\"unicode\"\n")
+
+	// Duration defaults are validated with time.ParseDuration; other string defaults are used as-is.
+	assert.Contains(t, resource, `getEnvOrDefault("5m", parseEnvDuration, "ENV_DEFAULTS_TIMEOUT").(string)`)
+	assert.Contains(t, resource, `getEnvOrDefault("", nil, "ENV_DEFAULTS_REGION").(string)`)
+	assert.Contains(t, utilities, "func parseEnvDuration(v string) interface{} {")
+	assert.Contains(t, utilities, "\t\"time\"\n")
+
+	// String map defaults are parsed from "k1=v1;k2=v2".
+	assert.Contains(t, resource,
+		`getEnvOrDefault(pulumi.StringMap{}, parseEnvStringMap, "ENV_DEFAULTS_TAGS").(pulumi.StringMap)`)
+	assert.Contains(t, utilities, "func parseEnvStringMap(v string) interface{} {")
+
+	// Custom-typed defaults look up their parser in the extensible envParsers map.
+	assert.Contains(t, resource,
+		`getEnvOrDefaultByType("", "env-defaults:index:Cidr", "ENV_DEFAULTS_CIDR").(string)`)
+	assert.Contains(t, utilities, "var envParsers = map[string]envParser{")
+	assert.Contains(t, utilities, "func getEnvOrDefaultByType(def interface{}, typ string, vars ...string) interface{} {")
+
+	// Defaults that allow empty values distinguish unset variables from empty ones with os.LookupEnv.
+	assert.Contains(t, resource,
+		`lookupEnvOrDefault("https://example.com", nil, "ENV_DEFAULTS_ENDPOINT").(string)`)
+	assert.Contains(t, resource, `lookupEnvOrDefault(false, parseEnvBool, "ENV_DEFAULTS_VERBOSE").(bool)`)
+	assert.Contains(t, resource, `getEnvOrDefault("", nil, "ENV_DEFAULTS_REGION").(string)`)
+	assert.Contains(t, utilities, "if value, ok := os.LookupEnv(v); ok {")
+
+	// Any-typed maps and arrays are parsed from JSON into inputs that match the property types.
+	assert.Contains(t, resource,
+		`getEnvOrDefault(pulumi.Map{}, parseEnvJSON, "ENV_DEFAULTS_SETTINGS").(pulumi.Map)`)
+	assert.Contains(t, resource,
+		`getEnvOrDefault(pulumi.Array{}, parseEnvJSON, "ENV_DEFAULTS_FILTERS").(pulumi.Array)`)
+	assert.Contains(t, utilities, "func parseEnvJSON(v string) interface{} {")
+	assert.Contains(t, utilities, "\t\"encoding/json\"\n")
+
+	// Integer defaults are parsed with the width of int.
+	assert.Contains(t, resource, `getEnvOrDefault(3, parseEnvInt, "ENV_DEFAULTS_RETRIES").(int)`)
+	assert.Contains(t, utilities, "func parseEnvInt(v string) interface{} {")
+
+	// Number defaults are parsed strictly unless the schema marks them as percentages.
+	assert.Contains(t, resource, `getEnvOrDefault(1.5, parseEnvFloat, "ENV_DEFAULTS_RATIO").(float64)`)
+	assert.Contains(t, resource, `getEnvOrDefault(0.0, parseEnvPercentage, "ENV_DEFAULTS_SAMPLE_RATE").(float64)`)
+	assert.Contains(t, utilities, "func parseEnvPercentage(v string) interface{} {")
+
+	// String defaults marked as base64 are decoded.
+	assert.Contains(t, resource, `getEnvOrDefault("", parseEnvBase64, "ENV_DEFAULTS_PRIVATE_KEY").(string)`)
+	assert.Contains(t, utilities, "func parseEnvBase64(v string) interface{} {")
+	assert.Contains(t, utilities, "\t\"encoding/base64\"\n")
+
+	// The variable that supplies each default is logged under PULUMI_DEBUG_ENV, which is read when it is needed.
+	assert.Contains(t, utilities, "func debugEnvDefaults() bool {")
+	assert.Contains(t, utilities, "func logEnvDefault(name string, vars []string) {")
+	assert.Contains(t, utilities, "\t\"log\"\n")
+}
+
+func TestGenerateEmbedVersion(t *testing.T) {
+	t.Parallel()
+
+	pkg := readSchemaFile(filepath.Join("schema", "go-embed-version.json"))
+	files, err := GeneratePackage("test", pkg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "1.2.3\n", string(files["plant/version.txt"]))
+
+	utilities := string(files["plant/pulumiUtilities.go"])
+	assert.Contains(t, utilities, "//go:embed version.txt\nvar embeddedVersion string\n")
+	assert.Contains(t, utilities, "semver.ParseTolerant(strings.TrimSpace(embeddedVersion))")
+
+	// Without environment defaults, there is nothing to log.
+	assert.NotContains(t, utilities, "PULUMI_DEBUG_ENV")
+	assert.NotContains(t, utilities, "\t\"log\"\n")
+
+	// PkgVersion only falls back to the embedded version if its regex does not match the package path.
+	match := regexp.MustCompile(`pkgVersionRegexp = regexp\.MustCompile\(("[^"]*")\)`).FindStringSubmatch(utilities)
+	require.NotNil(t, match)
+	pattern, err := strconv.Unquote(match[1])
+	require.NoError(t, err)
+	re := regexp.MustCompile(pattern)
+
+	assert.Equal(t, "/v2", re.FindStringSubmatch("github.com/pulumi/pulumi-plant/sdk/v2/go/plant")[1])
+	// A relocated module path doesn't match, so PkgVersion falls back to the embedded version.
+	assert.Nil(t, re.FindStringSubmatch("example.com/monorepo/third_party/plant/go/plant"))
+}
+
+// BenchmarkPkgVersionRegexp compares matching a package path with the regex that PkgVersion uses when the regex is
+// compiled by each call, as PkgVersion used to do, and when it is compiled once, as the generated code now does.
+func BenchmarkPkgVersionRegexp(b *testing.B) {
+	pkg := readSchemaFile(filepath.Join("schema", "go-embed-version.json"))
+	files, err := GeneratePackage("test", pkg)
+	require.NoError(b, err)
+	utilities := string(files["plant/pulumiUtilities.go"])
+	match := regexp.MustCompile(`pkgVersionRegexp = regexp\.MustCompile\(("[^"]*")\)`).FindStringSubmatch(utilities)
+	require.NotNil(b, match)
+	pattern, err := strconv.Unquote(match[1])
+	require.NoError(b, err)
+
+	const pkgPath = "github.com/pulumi/pulumi-plant/sdk/v2/go/plant"
+
+	b.Run("CompiledPerCall", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			regexp.MustCompile(pattern).FindStringSubmatch(pkgPath)
+		}
+	})
+
+	b.Run("CompiledOnce", func(b *testing.B) {
+		re := regexp.MustCompile(pattern)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			re.FindStringSubmatch(pkgPath)
+		}
+	})
+}
+
+func TestGeneratePinnedVersion(t *testing.T) {
+	t.Parallel()
+
+	pinnedPkg := readSchemaFile(filepath.Join("schema", "go-pinned-version.json"))
+	pinnedFiles, err := GeneratePackage("test", pinnedPkg)
+	require.NoError(t, err)
+
+	// The same package without a pinned version uses reflection.
+	reflectedPkg := readSchemaFile(filepath.Join("schema", "go-pinned-version.json"))
+	info := reflectedPkg.Language["go"].(GoPackageInfo)
+	info.EmbedVersion, info.PinnedVersion = false, ""
+	reflectedPkg.Language["go"] = info
+	reflectedFiles, err := GeneratePackage("test", reflectedPkg)
+	require.NoError(t, err)
+
+	// A pinned version takes precedence over embedding the version.
+	assert.NotContains(t, pinnedFiles, "plant/version.txt")
+
+	pinned := string(pinnedFiles["plant/pulumiUtilities.go"])
+	assert.Contains(t, pinned, "const pkgVersion = \"1.2.3\"\n")
+	assert.Contains(t, pinned, "return semver.MustParse(pkgVersion), nil")
+	assert.NotContains(t, pinned, "reflect.TypeOf(sentinal{})")
+	assert.NotContains(t, pinned, "\"regexp\"")
+	assert.NotContains(t, pinned, "embeddedVersion")
+
+	reflected := string(reflectedFiles["plant/pulumiUtilities.go"])
+	assert.NotContains(t, reflected, "pkgVersion")
+	assert.Contains(t, reflected, "reflect.TypeOf(sentinal{})")
+	assert.Contains(t, reflected, "\"regexp\"")
+
+	// Only PkgVersion differs between the two.
+	const rest = "// isZero"
+	require.Contains(t, pinned, rest)
+	require.Contains(t, reflected, rest)
+	assert.Equal(t, reflected[strings.Index(reflected, rest):], pinned[strings.Index(pinned, rest):])
+}
+
+func TestGenerateInvalidPinnedVersion(t *testing.T) {
+	t.Parallel()
+
+	pkg := readSchemaFile(filepath.Join("schema", "go-pinned-version.json"))
+	info := pkg.Language["go"].(GoPackageInfo)
+	info.PinnedVersion = "v1"
+	pkg.Language["go"] = info
+
+	_, err := GeneratePackage("test", pkg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid pinnedVersion "v1"`)
+}
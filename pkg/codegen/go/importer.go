@@ -76,6 +76,14 @@ type GoPackageInfo struct {
 	// Respect the Pkg.Version field for emitted code.
 	RespectSchemaVersion bool `json:"respectSchemaVersion,omitempty"`
 
+	// Embed the schema version in the SDK as version.txt. PkgVersion falls back to the embedded version when the
+	// package's import path does not contain a recognizable version.
+	EmbedVersion bool `json:"embedVersion,omitempty"`
+
+	// PinnedVersion fixes the version returned by the generated PkgVersion function. If set, PkgVersion returns this
+	// version rather than deriving one from the package's import path with reflection, and EmbedVersion is ignored.
+	PinnedVersion string `json:"pinnedVersion,omitempty"`
+
 	// InternalDependencies are blank imports that are emitted in the SDK so that `go mod tidy` does not remove the
 	// associated module dependencies from the SDK's go.mod.
 	InternalDependencies []string `json:"internalDependencies,omitempty"`
@@ -86,9 +94,35 @@ var Importer schema.Language = importer(0)
 
 type importer int
 
+// GoDefaultInfo holds information required to generate the Go default value of a property.
+type GoDefaultInfo struct {
+	// The delimiter used to split the value of an environment variable for an array-typed default. If omitted,
+	// values are split on ";". Ignored if EnvironmentFormat is "flexibleList".
+	EnvironmentDelimiter string `json:"environmentDelimiter,omitempty"`
+	// The format of the value of an environment variable. For a string-typed default, "duration" parses values using
+	// time.ParseDuration and normalizes them to their canonical form, and "base64" decodes values that are encoded in
+	// standard base64, such as secrets that are not printable as they are. For a number-typed default, "percentage"
+	// ignores surrounding whitespace and accepts a trailing "%", which converts the value to a fraction, so that "50%"
+	// and "0.5" are the same; values outside the range 0 to 1, i.e. 0% to 100%, are rejected. Other number-typed values
+	// are parsed strictly with strconv.ParseFloat. For an array-typed default, "flexibleList" splits values on any run
+	// of commas and whitespace, so that lists may be delimited either way.
+	EnvironmentFormat string `json:"environmentFormat,omitempty"`
+	// A token that names a custom type for the value of an environment variable, e.g. "aws:index:Cidr". The value is
+	// parsed by the parser registered for the token in the generated envParsers map, which the package's hand-written
+	// code may extend. If no parser is registered, the value is used as-is.
+	EnvironmentType string `json:"environmentType,omitempty"`
+	// If true, an environment variable that is set to the empty string is used rather than ignored. Empty values are
+	// passed to the default's parser like any other value; if the parser rejects them, the default is used instead.
+	EnvironmentAllowEmpty bool `json:"environmentAllowEmpty,omitempty"`
+}
+
 // ImportDefaultSpec decodes language-specific metadata associated with a DefaultValue.
 func (importer) ImportDefaultSpec(def *schema.DefaultValue, raw json.RawMessage) (interface{}, error) {
-	return raw, nil
+	var info GoDefaultInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, err
+	}
+	return info, nil
 }
 
 // ImportPropertySpec decodes language-specific metadata associated with a Property.
@@ -199,6 +199,14 @@ func (s *Scope) Define(name string, def Definition) bool {
 	return false
 }
 
+// Undefine removes the definition of the given name from this scope, if any. Definitions of the name in parent scopes
+// are not affected.
+func (s *Scope) Undefine(name string) {
+	if s != nil {
+		delete(s.defs, name)
+	}
+}
+
 // DefineFunction maps the given function name to the given function definition. If the function is alreadu defined in
 // this scope, the definition is not overwritten and DefineFunction returns false.
 func (s *Scope) DefineFunction(name string, def *Function) bool {
@@ -239,6 +247,22 @@ func (s *Scope) Push(syntax hclsyntax.Node) *Scope {
 	}
 }
 
+// Overlay returns a new child of s that contains the definitions and functions of other and each of its ancestors.
+// Names defined in other shadow those defined in s, and names defined in other shadow those defined in other's
+// ancestors.
+func (s *Scope) Overlay(other *Scope) *Scope {
+	child := s.Push(syntax.None)
+	for o := other; o != nil; o = o.parent {
+		for name, def := range o.defs {
+			child.Define(name, def)
+		}
+		for name, fn := range o.functions {
+			child.DefineFunction(name, fn)
+		}
+	}
+	return child
+}
+
 // Pop returns this scope's parent.
 func (s *Scope) Pop() *Scope {
 	return s.parent
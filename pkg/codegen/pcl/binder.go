@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"sync"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
@@ -38,6 +39,11 @@ type bindOptions struct {
 	skipResourceTypecheck  bool
 	loader                 schema.Loader
 	packageCache           *PackageCache
+	dirPath                string
+
+	// components holds the components loaded while binding a program and the programs it instantiates, keyed by
+	// the absolute path of the directory that defines each component.
+	components map[string]*Component
 }
 
 func (opts bindOptions) modelOptions() []model.BindOption {
@@ -50,12 +56,18 @@ func (opts bindOptions) modelOptions() []model.BindOption {
 type binder struct {
 	options bindOptions
 
+	// packagesLock guards referencedPackages and packageReferrers.
+	packagesLock       *sync.Mutex
 	referencedPackages map[string]schema.PackageReference
+	packageReferrers   map[string]packageReferrer
 	schemaTypes        map[schema.Type]model.Type
 
 	tokens syntax.TokenMap
 	nodes  []Node
 	root   *model.Scope
+
+	// components are the components instantiated by the program, in the order they were first instantiated.
+	components []*Component
 }
 
 type BindOption func(*bindOptions)
@@ -88,6 +100,14 @@ func Cache(cache *PackageCache) BindOption {
 	}
 }
 
+// DirPath sets the directory that contains the program's source files. The source of each component block in the
+// program is resolved relative to this directory. It defaults to the current working directory.
+func DirPath(path string) BindOption {
+	return func(options *bindOptions) {
+		options.dirPath = path
+	}
+}
+
 // BindProgram performs semantic analysis on the given set of HCL2 files that represent a single program. The given
 // host, if any, is used for loading any resource plugins necessary to extract schema information.
 func BindProgram(files []*syntax.File, opts ...BindOption) (*Program, hcl.Diagnostics, error) {
@@ -117,10 +137,23 @@ func BindProgram(files []*syntax.File, opts ...BindOption) (*Program, hcl.Diagno
 		options.packageCache = NewPackageCache()
 	}
 
+	if options.dirPath == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, nil, err
+		}
+		options.dirPath = cwd
+	}
+	if options.components == nil {
+		options.components = map[string]*Component{}
+	}
+
 	b := &binder{
 		options:            options,
 		tokens:             syntax.NewTokenMapForFiles(files),
+		packagesLock:       &sync.Mutex{},
 		referencedPackages: map[string]schema.PackageReference{},
+		packageReferrers:   map[string]packageReferrer{},
 		schemaTypes:        map[schema.Type]model.Type{},
 		root:               model.NewRootScope(syntax.None),
 	}
@@ -156,15 +189,20 @@ func BindProgram(files []*syntax.File, opts ...BindOption) (*Program, hcl.Diagno
 		diagnostics = append(diagnostics, b.bindNode(n)...)
 	}
 
+	packages, packageReferrers := b.finalizePackages()
 	return &Program{
-		Nodes:  b.nodes,
-		files:  files,
-		binder: b,
+		Nodes:            b.nodes,
+		files:            files,
+		components:       b.components,
+		diagnostics:      diagnostics,
+		packages:         packages,
+		packageReferrers: packageReferrers,
+		binder:           b,
 	}, diagnostics, nil
 }
 
-// declareNodes declares all of the top-level nodes in the given file. This includes config, resources, outputs, and
-// locals.
+// declareNodes declares all of the top-level nodes in the given file. This includes config, resources, outputs,
+// locals, and component instances.
 func (b *binder) declareNodes(file *syntax.File) (hcl.Diagnostics, error) {
 	var diagnostics hcl.Diagnostics
 
@@ -249,6 +287,22 @@ func (b *binder) declareNodes(file *syntax.File) (hcl.Diagnostics, error) {
 				if err := b.loadReferencedPackageSchemas(v); err != nil {
 					return nil, err
 				}
+			case "component":
+				if len(item.Labels) != 2 {
+					diagnostics = append(diagnostics, errorf(item.DefRange(), "components must have exactly two labels"))
+					continue
+				}
+
+				instance := &ComponentInstance{
+					syntax: item,
+					typ:    model.DynamicType,
+				}
+				diags := b.declareNode(item.Labels[0], instance)
+				diagnostics = append(diagnostics, diags...)
+
+				if err := b.loadReferencedPackageSchemas(instance); err != nil {
+					return nil, err
+				}
 			}
 		}
 	}
@@ -283,12 +337,18 @@ func getStringAttrValue(attr *model.Attribute) (string, *hcl.Diagnostic) {
 func (b *binder) declareNode(name string, n Node) hcl.Diagnostics {
 	if !b.root.Define(name, n) {
 		existing, _ := b.root.BindReference(name)
-		return hcl.Diagnostics{errorf(existing.SyntaxNode().Range(), "%q already declared", name)}
+		return hcl.Diagnostics{duplicateDeclaration(name, existing, n)}
 	}
 	b.nodes = append(b.nodes, n)
 	return nil
 }
 
-func (b *binder) bindExpression(node hclsyntax.Node) (model.Expression, hcl.Diagnostics) {
-	return model.BindExpression(node, b.root, b.tokens, b.options.modelOptions()...)
+// bindExpression binds an expression in the top-level scope. If scope is non-nil, its definitions are layered over
+// the top-level scope.
+func (b *binder) bindExpression(node hclsyntax.Node, scope *model.Scope) (model.Expression, hcl.Diagnostics) {
+	root := b.root
+	if scope != nil {
+		root = root.Overlay(scope)
+	}
+	return model.BindExpression(node, root, b.tokens, b.options.modelOptions()...)
 }
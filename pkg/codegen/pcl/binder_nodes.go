@@ -15,10 +15,15 @@
 package pcl
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
+
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/pulumi/pulumi/pkg/v3/codegen"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/hcl2/model"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/hcl2/syntax"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/util/contract"
 )
 
@@ -64,6 +69,9 @@ func (b *binder) bindNode(node Node) hcl.Diagnostics {
 	case *OutputVariable:
 		diags := b.bindOutputVariable(node)
 		diagnostics = append(diagnostics, diags...)
+	case *ComponentInstance:
+		diags := b.bindComponentInstance(node)
+		diagnostics = append(diagnostics, diags...)
 	default:
 		contract.Failf("unexpected node of type %T (%v)", node, node.SyntaxNode().Range())
 	}
@@ -139,3 +147,158 @@ func (b *binder) bindOutputVariable(node *OutputVariable) hcl.Diagnostics {
 	node.Definition = block
 	return diagnostics
 }
+
+// loadComponent loads the component that the given instance instantiates. A component is defined by the PCL program
+// in the directory named by the instance's source, which is relative to the directory of the program being bound.
+// Each directory is loaded once per call to BindProgram, so all of the instances of a component share its definition.
+func (b *binder) loadComponent(node *ComponentInstance) (*Component, hcl.Diagnostics) {
+	source, sourceRange := node.Source(), node.syntax.LabelRanges[1]
+
+	dir := source
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(b.options.dirPath, dir)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, hcl.Diagnostics{errorf(sourceRange, "failed to load component '%s': %v", source, err)}
+	}
+
+	if component, ok := b.options.components[dir]; ok {
+		if component.binding {
+			return nil, hcl.Diagnostics{errorf(sourceRange, "component '%s' cannot instantiate itself", source)}
+		}
+		b.addComponent(component)
+		return component, nil
+	}
+
+	files, diagnostics, err := parseComponentFiles(dir)
+	if err != nil {
+		return nil, hcl.Diagnostics{errorf(sourceRange, "failed to load component '%s': %v", source, err)}
+	}
+	if diagnostics.HasErrors() {
+		return nil, diagnostics
+	}
+	if len(files) == 0 {
+		return nil, hcl.Diagnostics{errorf(sourceRange, "component '%s' does not contain any .pp files", source)}
+	}
+
+	// Mark the component as binding while its program is bound so that instances of the component inside of its own
+	// program, directly or through other components, are reported rather than loaded again.
+	component := &Component{Dir: dir, binding: true}
+	b.options.components[dir] = component
+
+	options := b.options
+	options.dirPath = dir
+	program, diags, err := BindProgram(files, func(o *bindOptions) {
+		*o = options
+	})
+	component.binding = false
+	if err != nil {
+		delete(b.options.components, dir)
+		return nil, hcl.Diagnostics{errorf(sourceRange, "failed to load component '%s': %v", source, err)}
+	}
+	diagnostics = append(diagnostics, diags...)
+
+	component.Program, component.Nodes = program, program.Nodes
+	component.InputTypes = map[string]model.Type{}
+	component.OutputTypes = map[string]model.Type{}
+	for _, n := range component.Nodes {
+		switch n := n.(type) {
+		case *ConfigVariable:
+			component.InputTypes[n.Name()] = n.Type()
+		case *LocalVariable:
+			component.Locals = append(component.Locals, n)
+		case *Resource:
+			component.Children = append(component.Children, n)
+		case *OutputVariable:
+			typ := n.Type()
+			if typ == model.DynamicType && n.Value != nil {
+				typ = n.Value.Type()
+			}
+			component.OutputTypes[n.Name()] = typ
+		}
+	}
+
+	b.addComponent(component)
+	return component, diagnostics
+}
+
+// addComponent records that the program instantiates the given component.
+func (b *binder) addComponent(component *Component) {
+	for _, c := range b.components {
+		if c == component {
+			return
+		}
+	}
+	b.components = append(b.components, component)
+}
+
+// parseComponentFiles parses the .pp files in the given directory.
+func parseComponentFiles(dir string) ([]*syntax.File, hcl.Diagnostics, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parser := syntax.NewParser()
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pp" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = parser.ParseFile(bytes.NewReader(contents), path); err != nil {
+			return nil, nil, err
+		}
+	}
+	return parser.Files, parser.Diagnostics, nil
+}
+
+func (b *binder) bindComponentInstance(node *ComponentInstance) hcl.Diagnostics {
+	block, diagnostics := model.BindBlock(node.syntax, model.StaticScope(b.root), b.tokens, b.options.modelOptions()...)
+	node.Definition = block
+
+	component, diags := b.loadComponent(node)
+	diagnostics = append(diagnostics, diags...)
+	if component == nil {
+		return diagnostics
+	}
+	node.Component = component
+
+	for _, item := range block.Body.Items {
+		switch item := item.(type) {
+		case *model.Attribute:
+			typ, ok := component.InputTypes[item.Name]
+			if !ok {
+				diagnostics = append(diagnostics, unsupportedAttribute(item.Name, item.Syntax.NameRange))
+				continue
+			}
+			if model.InputType(typ).ConversionFrom(item.Value.Type()) == model.NoConversion {
+				diagnostics = append(diagnostics, model.ExprNotConvertible(model.InputType(typ), item.Value))
+			}
+			node.Inputs = append(node.Inputs, item)
+		case *model.Block:
+			diagnostics = append(diagnostics, unsupportedBlock(item.Type, item.Syntax.TypeRange))
+		}
+	}
+
+	for _, n := range component.Nodes {
+		if config, ok := n.(*ConfigVariable); ok && config.Required() {
+			if _, ok := block.Body.Attribute(config.Name()); !ok {
+				diagnostics = append(diagnostics, missingRequiredAttribute(config.Name(), node.syntax.Body.SrcRange))
+			}
+		}
+	}
+
+	outputs := make(map[string]model.Type, len(component.OutputTypes))
+	for name, typ := range component.OutputTypes {
+		outputs[name] = model.NewOutputType(model.ResolveOutputs(typ))
+	}
+	node.typ = model.NewObjectType(outputs)
+
+	return diagnostics
+}
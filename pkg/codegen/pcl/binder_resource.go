@@ -49,6 +49,7 @@ func (b *binder) bindResourceTypes(node *Resource) hcl.Diagnostics {
 
 	// Find the resource's schema.
 	token, tokenRange := getResourceToken(node)
+	sourceToken := token
 	pkg, module, name, diagnostics := DecomposeToken(token, tokenRange)
 	if diagnostics.HasErrors() {
 		return diagnostics
@@ -85,6 +86,9 @@ func (b *binder) bindResourceTypes(node *Resource) hcl.Diagnostics {
 	node.Schema = res
 	inputProperties, properties = res.InputProperties, res.Properties
 	node.Token = token
+	if res.DeprecationMessage != "" {
+		diagnostics = append(diagnostics, deprecatedResource(sourceToken, res.DeprecationMessage, tokenRange))
+	}
 
 	// Create input and output types for the schema.
 	inputType := b.schemaTypeToType(&schema.ObjectType{Properties: inputProperties})
@@ -275,6 +279,21 @@ func (b *binder) bindResourceBody(node *Resource) hcl.Diagnostics {
 		}
 	}
 
+	// Warn about references to deprecated input properties.
+	if node.Schema != nil {
+		deprecated := map[string]string{}
+		for _, prop := range node.Schema.InputProperties {
+			if prop.DeprecationMessage != "" {
+				deprecated[prop.Name] = prop.DeprecationMessage
+			}
+		}
+		for _, attr := range node.Inputs {
+			if message, ok := deprecated[attr.Name]; ok {
+				diagnostics = append(diagnostics, deprecatedProperty(attr.Name, message, attr.Syntax.NameRange))
+			}
+		}
+	}
+
 	// Typecheck the attributes.
 	if objectType, ok := node.InputType.(*model.ObjectType); ok && !b.options.skipResourceTypecheck {
 		attrNames := codegen.StringSet{}
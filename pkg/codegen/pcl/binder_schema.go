@@ -139,17 +139,25 @@ func canonicalizeToken(tok string, pkg schema.PackageReference) string {
 func (b *binder) loadReferencedPackageSchemas(n Node) error {
 	// TODO: package versions
 	packageNames := codegen.StringSet{}
-
-	if r, ok := n.(*Resource); ok {
-		token, tokenRange := getResourceToken(r)
+	packageRanges := map[string]hcl.Range{}
+	addPackage := func(token string, tokenRange hcl.Range) {
 		packageName, mod, name, _ := DecomposeToken(token, tokenRange)
-		if packageName != pulumiPackage {
+		if packageName == pulumiPackage {
+			if mod != "providers" {
+				return
+			}
+			packageName = name
+		}
+		if !packageNames.Has(packageName) {
 			packageNames.Add(packageName)
-		} else if mod == "providers" {
-			packageNames.Add(name)
+			packageRanges[packageName] = tokenRange
 		}
 	}
 
+	if r, ok := n.(*Resource); ok {
+		addPackage(getResourceToken(r))
+	}
+
 	diags := hclsyntax.VisitAll(n.SyntaxNode(), func(node hclsyntax.Node) hcl.Diagnostics {
 		call, ok := node.(*hclsyntax.FunctionCallExpr)
 		if !ok {
@@ -159,29 +167,58 @@ func (b *binder) loadReferencedPackageSchemas(n Node) error {
 		if !ok {
 			return nil
 		}
-		packageName, mod, name, _ := DecomposeToken(token, tokenRange)
-		if packageName != pulumiPackage {
-			packageNames.Add(packageName)
-		} else if mod == "providers" {
-			packageNames.Add(name)
-		}
+		addPackage(token, tokenRange)
 		return nil
 	})
 	contract.Assert(len(diags) == 0)
 
 	for _, name := range packageNames.SortedValues() {
-		if _, ok := b.referencedPackages[name]; ok {
+		b.packagesLock.Lock()
+		_, ok := b.referencedPackages[name]
+		b.packagesLock.Unlock()
+		if ok {
 			continue
 		}
+
 		pkg, err := b.options.packageCache.loadPackageSchema(b.options.loader, name)
 		if err != nil {
 			return err
 		}
-		b.referencedPackages[name] = pkg.schema
+
+		b.packagesLock.Lock()
+		if _, ok := b.referencedPackages[name]; !ok {
+			b.referencedPackages[name] = pkg.schema
+			b.packageReferrers[name] = packageReferrer{node: n, tokenRange: packageRanges[name]}
+		}
+		b.packagesLock.Unlock()
 	}
 	return nil
 }
 
+// packageReferrer records where a package was first referenced: the node that referenced it and the range of the
+// resource type or function token that named it.
+type packageReferrer struct {
+	node       Node
+	tokenRange hcl.Range
+}
+
+// finalizePackages returns copies of the packages referenced by the program and where they were first referenced.
+// The copies are not modified by the binder, so they may be read concurrently once binding is complete.
+func (b *binder) finalizePackages() (map[string]schema.PackageReference, map[string]packageReferrer) {
+	b.packagesLock.Lock()
+	defer b.packagesLock.Unlock()
+
+	packages := make(map[string]schema.PackageReference, len(b.referencedPackages))
+	for name, ref := range b.referencedPackages {
+		packages[name] = ref
+	}
+	referrers := make(map[string]packageReferrer, len(b.packageReferrers))
+	for name, referrer := range b.packageReferrers {
+		referrers[name] = referrer
+	}
+	return packages, referrers
+}
+
 func buildEnumValue(v interface{}) cty.Value {
 	switch v := v.(type) {
 	case string:
@@ -15,19 +15,102 @@
 package pcl
 
 import (
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/hcl2/model"
 )
 
-// Component represents a component definition in a program.
+// Component represents a component definition. A component is defined by the PCL program in a directory: the
+// program's config variables are the component's inputs, and its outputs are the component's outputs. Components are
+// loaded when a program instantiates them with a component block, e.g.
+//
+//	component pets "./petSet" {
+//		name = "pets"
+//	}
 //
-// TODO(pdg): implement
+// The nodes of a component are bound in the scope of the component's own program, so they cannot refer to the nodes
+// of the programs that instantiate it.
 type Component struct {
-	Syntax *hclsyntax.Block
+	// Dir is the absolute path of the directory that defines the component.
+	Dir string
+	// Program is the component's bound program.
+	Program *Program
 
 	InputTypes  map[string]model.Type
 	OutputTypes map[string]model.Type
 
 	Children []*Resource
 	Locals   []*LocalVariable
+
+	// Nodes are all of the nodes declared in the component, in source order.
+	Nodes []Node
+
+	binding bool
+}
+
+// Name returns the name of the component, which is the name of the directory that defines it.
+func (c *Component) Name() string {
+	return filepath.Base(c.Dir)
+}
+
+// ComponentInstance represents an instantiation of a component inside of a program. An instance is declared by a
+// component block with two labels: the name of the instance and the path of the directory that defines the
+// component, relative to the directory of the program. The attributes of the block supply the component's inputs,
+// and the instance's type is an object that holds the component's outputs.
+type ComponentInstance struct {
+	node
+
+	syntax *hclsyntax.Block
+	typ    model.Type
+
+	// The definition of the instance.
+	Definition *model.Block
+	// The component being instantiated, if it could be loaded.
+	Component *Component
+	// The inputs passed to the component.
+	Inputs []*model.Attribute
+}
+
+// SyntaxNode returns the syntax node associated with the component instance.
+func (ci *ComponentInstance) SyntaxNode() hclsyntax.Node {
+	return ci.syntax
+}
+
+func (ci *ComponentInstance) Traverse(traverser hcl.Traverser) (model.Traversable, hcl.Diagnostics) {
+	return ci.typ.Traverse(traverser)
+}
+
+func (ci *ComponentInstance) VisitExpressions(pre, post model.ExpressionVisitor) hcl.Diagnostics {
+	return model.VisitExpressions(ci.Definition, pre, post)
+}
+
+func (ci *ComponentInstance) Name() string {
+	return ci.syntax.Labels[0]
+}
+
+// Type returns the type of the component instance.
+func (ci *ComponentInstance) Type() model.Type {
+	return ci.typ
+}
+
+// Kind returns the kind of the component instance.
+func (*ComponentInstance) Kind() NodeKind {
+	return NodeKindComponent
+}
+
+// Source returns the path of the directory that defines the component being instantiated, as written in the
+// component block. The path is available even if the component could not be loaded.
+func (ci *ComponentInstance) Source() string {
+	return ci.syntax.Labels[1]
+}
+
+// InputValues returns the bound values of the inputs passed to the component, keyed by input name.
+func (ci *ComponentInstance) InputValues() map[string]model.Expression {
+	values := make(map[string]model.Expression, len(ci.Inputs))
+	for _, input := range ci.Inputs {
+		values[input.Name] = input.Value
+	}
+	return values
 }
@@ -55,3 +55,19 @@ func (cv *ConfigVariable) Name() string {
 func (cv *ConfigVariable) Type() model.Type {
 	return cv.typ
 }
+
+// Default returns the bound default value of the config variable, or nil if it has no default.
+func (cv *ConfigVariable) Default() model.Expression {
+	return cv.DefaultValue
+}
+
+// Required returns true if a value must be supplied for the config variable, i.e. it has neither a default value nor
+// an optional type.
+func (cv *ConfigVariable) Required() bool {
+	return cv.DefaultValue == nil && !model.IsOptionalType(cv.typ)
+}
+
+// Kind returns the kind of the config variable.
+func (*ConfigVariable) Kind() NodeKind {
+	return NodeKindConfig
+}
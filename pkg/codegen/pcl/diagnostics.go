@@ -2,6 +2,7 @@ package pcl
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
@@ -45,6 +46,29 @@ func resourceLoadError(token string, err error, tokenRange hcl.Range) *hcl.Diagn
 	return errorf(tokenRange, "error loading resource type '%s': %v", token, err)
 }
 
+func duplicateDeclaration(name string, existing model.Definition, redeclared Node) *hcl.Diagnostic {
+	existingRange, redeclaredRange := existing.SyntaxNode().Range(), redeclared.SyntaxNode().Range()
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("%q already declared", name),
+		Detail:   fmt.Sprintf("%q is declared at %v and again at %v", name, existingRange, redeclaredRange),
+		Subject:  &redeclaredRange,
+		Context:  &existingRange,
+	}
+}
+
+func packageLoadError(name string, err error, referrer packageReferrer) *hcl.Diagnostic {
+	if referrer.node == nil {
+		return errorf(hcl.Range{}, "error loading package '%s': %v", name, err)
+	}
+	// Anchor the diagnostic at the token that referenced the package if it is known, or else at the whole node.
+	rng := referrer.tokenRange
+	if rng.Filename == "" {
+		rng = referrer.node.SyntaxNode().Range()
+	}
+	return errorf(rng, "error loading package '%s' referenced by '%s': %v", name, referrer.node.Name(), err)
+}
+
 func unknownResourceType(token string, tokenRange hcl.Range) *hcl.Diagnostic {
 	return errorf(tokenRange, "unknown resource type '%s'", token)
 }
@@ -57,6 +81,32 @@ func unknownFunction(token string, tokenRange hcl.Range) *hcl.Diagnostic {
 	return errorf(tokenRange, "unknown function '%s'", token)
 }
 
+func deprecatedResource(token, message string, tokenRange hcl.Range) *hcl.Diagnostic {
+	return diagf(hcl.DiagWarning, tokenRange, "resource type '%s' is deprecated: %s", token, strings.TrimSpace(message))
+}
+
+func deprecatedFunction(token, message string, tokenRange hcl.Range) *hcl.Diagnostic {
+	return diagf(hcl.DiagWarning, tokenRange, "function '%s' is deprecated: %s", token, strings.TrimSpace(message))
+}
+
+func deprecatedProperty(name, message string, nameRange hcl.Range) *hcl.Diagnostic {
+	return diagf(hcl.DiagWarning, nameRange, "property '%s' is deprecated: %s", name, strings.TrimSpace(message))
+}
+
+func undefinedOutputReference(output, name string, nameRange hcl.Range) *hcl.Diagnostic {
+	return errorf(nameRange, "output '%s' references undefined variable '%s'", output, name)
+}
+
+func unknownOutputType(output string, valueRange hcl.Range) *hcl.Diagnostic {
+	return diagf(hcl.DiagWarning, valueRange,
+		"the type of output '%s' is unknown, so its value cannot be checked before it is exported", output)
+}
+
+func secretOutput(output string, valueRange hcl.Range) *hcl.Diagnostic {
+	return diagf(hcl.DiagWarning, valueRange,
+		"output '%s' is secret; its value is encrypted in the stack's state and hidden when outputs are shown", output)
+}
+
 func unsupportedBlock(blockType string, typeRange hcl.Range) *hcl.Diagnostic {
 	return errorf(typeRange, "unsupported block of type '%v'", blockType)
 }
@@ -80,3 +130,21 @@ func duplicateBlock(blockType string, typeRange hcl.Range) *hcl.Diagnostic {
 func stringAttributeError(attr *model.Attribute) *hcl.Diagnostic {
 	return errorf(attr.Syntax.Expr.Range(), "attribute %v must be a string literal", attr.Name)
 }
+
+func circularReference(cycle []Node) *hcl.Diagnostic {
+	names := make([]string, 0, len(cycle)+1)
+	var detail strings.Builder
+	for _, n := range cycle {
+		names = append(names, n.Name())
+		fmt.Fprintf(&detail, "%s is declared at %v\n", n.Name(), n.SyntaxNode().Range())
+	}
+	names = append(names, cycle[0].Name())
+
+	subject := cycle[0].SyntaxNode().Range()
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("circular reference: %s", strings.Join(names, " -> ")),
+		Detail:   detail.String(),
+		Subject:  &subject,
+	}
+}
@@ -88,7 +88,34 @@ func (b *binder) bindInvokeSignature(args []model.Expression) (model.StaticFunct
 		return b.zeroSignature(), diag
 	}
 
-	return sig, nil
+	return sig, deprecatedInvokeDiagnostics(fn, token, tokenRange, args[1])
+}
+
+// deprecatedInvokeDiagnostics returns warnings for an invoke of a deprecated function and for the deprecated
+// arguments that it is given. The function is named by token, the token as written in the invoke.
+func deprecatedInvokeDiagnostics(fn *schema.Function, token string, tokenRange hcl.Range,
+	args model.Expression) hcl.Diagnostics {
+
+	var diagnostics hcl.Diagnostics
+	if fn.DeprecationMessage != "" {
+		diagnostics = append(diagnostics, deprecatedFunction(token, fn.DeprecationMessage, tokenRange))
+	}
+
+	obj, ok := args.(*model.ObjectConsExpression)
+	if !ok || fn.Inputs == nil {
+		return diagnostics
+	}
+	for _, item := range obj.Items {
+		key, ok := item.Key.(*model.LiteralValueExpression)
+		if !ok || key.Value.Type() != cty.String {
+			continue
+		}
+		name := key.Value.AsString()
+		if prop, ok := fn.Inputs.Property(name); ok && prop.DeprecationMessage != "" {
+			diagnostics = append(diagnostics, deprecatedProperty(name, prop.DeprecationMessage, key.SyntaxNode().Range()))
+		}
+	}
+	return diagnostics
 }
 
 func (b *binder) makeSignature(argsType, returnType model.Type) model.StaticFunctionSignature {
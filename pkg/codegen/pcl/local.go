@@ -51,9 +51,19 @@ func (lv *LocalVariable) LogicalName() string {
 	return lv.Definition.Name
 }
 
+// Value returns the bound expression that defines the local variable's value.
+func (lv *LocalVariable) Value() model.Expression {
+	return lv.Definition.Value
+}
+
 // Type returns the type of the local variable.
 func (lv *LocalVariable) Type() model.Type {
 	return lv.Definition.Type()
 }
 
+// Kind returns the kind of the local variable.
+func (*LocalVariable) Kind() NodeKind {
+	return NodeKindLocal
+}
+
 func (*LocalVariable) isNode() {}
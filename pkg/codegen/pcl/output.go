@@ -69,3 +69,8 @@ func (ov *OutputVariable) LogicalName() string {
 func (ov *OutputVariable) Type() model.Type {
 	return ov.typ
 }
+
+// Kind returns the kind of the output variable.
+func (*OutputVariable) Kind() NodeKind {
+	return NodeKindOutput
+}
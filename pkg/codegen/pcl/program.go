@@ -15,18 +15,29 @@
 package pcl
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/pulumi/pulumi/pkg/v3/codegen"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/hcl2/model"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/hcl2/syntax"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/contract"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // Node represents a single definition in a program or component. Nodes may be config, locals, resources, or outputs.
+// Details that are specific to a kind of node are available from its concrete type; for example, the options of a
+// resource node are available by asserting that it is a *Resource and calling ResourceOptions. Likewise, the bound
+// value of a local is available from (*LocalVariable).Value, and that of an output from (*OutputVariable).Value,
+// without visiting the node's expressions.
 type Node interface {
 	model.Definition
 
@@ -36,6 +47,9 @@ type Node interface {
 	// Type returns the type of the node.
 	Type() model.Type
 
+	// Kind returns the kind of the node.
+	Kind() NodeKind
+
 	// VisitExpressions visits the expressions that make up the node's body.
 	VisitExpressions(pre, post model.ExpressionVisitor) hcl.Diagnostics
 
@@ -50,6 +64,22 @@ type Node interface {
 	isNode()
 }
 
+// NodeKind identifies the kind of a node in a program.
+type NodeKind string
+
+const (
+	// NodeKindConfig is the kind of a ConfigVariable.
+	NodeKindConfig NodeKind = "config"
+	// NodeKindLocal is the kind of a LocalVariable.
+	NodeKindLocal NodeKind = "local"
+	// NodeKindResource is the kind of a Resource.
+	NodeKindResource NodeKind = "resource"
+	// NodeKindOutput is the kind of an OutputVariable.
+	NodeKindOutput NodeKind = "output"
+	// NodeKindComponent is the kind of a ComponentInstance.
+	NodeKindComponent NodeKind = "component"
+)
+
 type node struct {
 	binding bool
 	bound   bool
@@ -86,9 +116,153 @@ func (*node) isNode() {}
 type Program struct {
 	Nodes []Node
 
-	files []*syntax.File
+	files       []*syntax.File
+	components  []*Component
+	diagnostics hcl.Diagnostics
+
+	// sourceEdits are the edits that have been applied to the source text of the program's files. Their ranges are
+	// relative to the original source text, as are the ranges of the program's syntax.
+	sourceEdits []sourceEdit
+
+	// packages and packageReferrers are the packages referenced by the program and where each was first referenced.
+	// They are finalized when binding completes and never modified afterwards.
+	packages         map[string]schema.PackageReference
+	packageReferrers map[string]packageReferrer
 
 	binder *binder
+
+	// loader, if set, resolves the referenced packages in place of the references that were bound. It is set by
+	// WithPackageLoader.
+	loader schema.Loader
+
+	snapshotsLock sync.Mutex
+	snapshots     []*schema.Package
+}
+
+// SourceFiles returns the parsed source files that make up the program, in the order in which they were bound. The
+// returned slice is a copy; the files themselves are shared with the program and must not be modified.
+func (p *Program) SourceFiles() []*syntax.File {
+	files := make([]*syntax.File, len(p.files))
+	copy(files, p.files)
+	return files
+}
+
+// Clone returns an independent copy of the program, e.g. for a transformation that must not modify the original. The
+// copy is made by binding the program's source files again with the same options, package loader, and package cache
+// as the original, so its nodes share nothing with the original's but no package schemas are loaded again.
+func (p *Program) Clone() (*Program, hcl.Diagnostics) {
+	options := p.binder.options
+	program, diagnostics, err := BindProgram(p.SourceFiles(), func(o *bindOptions) {
+		*o = options
+		// Load the program's components again rather than sharing them with the original.
+		o.components = nil
+	})
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "failed to clone program",
+			Detail:   err.Error(),
+		}}
+	}
+	return program, diagnostics
+}
+
+// WithPackageLoader returns a copy of the program that resolves its referenced packages through loader rather than
+// through the loader that the program was bound with. The copy shares the program's nodes and source files, so it is
+// not rebound; only the package definitions returned by Packages, PackagesWithDiagnostics, and PackageSnapshots are
+// affected. Packages are loaded on demand, so loader is not called until then.
+func (p *Program) WithPackageLoader(loader schema.Loader) *Program {
+	return &Program{
+		Nodes:            p.Nodes,
+		files:            p.files,
+		sourceEdits:      p.sourceEdits,
+		components:       p.components,
+		diagnostics:      p.diagnostics,
+		packages:         p.packages,
+		packageReferrers: p.packageReferrers,
+		binder:           p.binder,
+		loader:           loader,
+	}
+}
+
+// WriteSource writes the source text of the program's files to w in order of file name. Each file's text is preceded
+// by a comment that names the file, so the output can be parsed as a single file that declares the same nodes.
+func (p *Program) WriteSource(w io.Writer) error {
+	files := p.SourceFiles()
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	for i, f := range files {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "// %s\n", f.Name); err != nil {
+			return err
+		}
+		if _, err := w.Write(f.Bytes); err != nil {
+			return err
+		}
+		if len(f.Bytes) > 0 && f.Bytes[len(f.Bytes)-1] != '\n' {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Diagnostics returns the diagnostics that were reported when the program was bound. The returned slice is a copy,
+// so appending to it does not affect the program.
+func (p *Program) Diagnostics() hcl.Diagnostics {
+	diagnostics := make(hcl.Diagnostics, len(p.diagnostics))
+	copy(diagnostics, p.diagnostics)
+	return diagnostics
+}
+
+// Components returns the components that the program instantiates, in the order in which they are first
+// instantiated. Each component appears once, however many times it is instantiated. The nodes declared inside each
+// component are available from the component's Nodes field; they are not included in the program's Nodes. Components
+// that are only instantiated by other components are available from those components' programs. The returned slice
+// is a copy, but the components themselves are shared with the program and must not be modified.
+func (p *Program) Components() []*Component {
+	components := make([]*Component, len(p.components))
+	copy(components, p.components)
+	return components
+}
+
+// ComponentInstances returns the instantiations of components in the program. Top-level instances come first, in
+// source order, followed by the instances declared inside the components that the program instantiates. Unlike
+// Components, which returns the definitions of components, ComponentInstances returns their uses.
+func (p *Program) ComponentInstances() []*ComponentInstance {
+	var instances []*ComponentInstance
+	for _, n := range p.allNodes() {
+		if instance, ok := n.(*ComponentInstance); ok {
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+// allNodes returns the program's nodes followed by the nodes declared inside each component that the program
+// instantiates, directly or through other components. The nodes of each component are included once.
+func (p *Program) allNodes() []Node {
+	nodes := append([]Node(nil), p.Nodes...)
+	seen := map[*Component]bool{}
+	var addComponents func(components []*Component)
+	addComponents = func(components []*Component) {
+		for _, c := range components {
+			if !seen[c] {
+				seen[c] = true
+				nodes = append(nodes, c.Nodes...)
+				addComponents(c.Program.components)
+			}
+		}
+	}
+	addComponents(p.components)
+	return nodes
 }
 
 // NewDiagnosticWriter creates a new hcl.DiagnosticWriter for use with diagnostics generated by the program.
@@ -96,58 +270,1084 @@ func (p *Program) NewDiagnosticWriter(w io.Writer, width uint, color bool) hcl.D
 	return syntax.NewDiagnosticWriter(w, p.files, width, color)
 }
 
+// diagnosticPos is the serialized form of a position in a diagnostic's source range.
+type diagnosticPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// diagnosticRange is the serialized form of a diagnostic's source range.
+type diagnosticRange struct {
+	Filename string        `json:"filename"`
+	Start    diagnosticPos `json:"start"`
+	End      diagnosticPos `json:"end"`
+}
+
+// diagnosticJSON is the serialized form of a diagnostic.
+type diagnosticJSON struct {
+	Severity string           `json:"severity"`
+	Summary  string           `json:"summary"`
+	Detail   string           `json:"detail,omitempty"`
+	Range    *diagnosticRange `json:"range,omitempty"`
+	Source   string           `json:"source,omitempty"`
+}
+
+// WriteDiagnosticsJSON writes a JSON description of the given diagnostics to w for consumption by editors and other
+// tools. The output is an object with a single "diagnostics" array that holds an object for each diagnostic, in the
+// order given, with the following fields:
+//
+//   - "severity": "error" or "warning"
+//   - "summary": a short description of the problem
+//   - "detail": a longer description of the problem, omitted if empty
+//   - "range": the source range of the problem, omitted if the diagnostic has none. The range has a "filename" and
+//     "start" and "end" positions, each with a 1-based "line" and "column" and a 0-based "byte" offset. The end
+//     position is exclusive.
+//   - "source": the text of the source lines that the range spans, omitted if the range does not lie within one of the
+//     program's files
+func (p *Program) WriteDiagnosticsJSON(w io.Writer, diags hcl.Diagnostics) error {
+	files := make(map[string]*syntax.File, len(p.files))
+	for _, f := range p.files {
+		files[f.Name] = f
+	}
+
+	entries := make([]diagnosticJSON, len(diags))
+	for i, d := range diags {
+		entry := diagnosticJSON{
+			Severity: diagnosticSeverityString(d.Severity),
+			Summary:  d.Summary,
+			Detail:   d.Detail,
+		}
+		if d.Subject != nil && d.Subject.Filename != "" {
+			rng := *d.Subject
+			entry.Range = &diagnosticRange{
+				Filename: rng.Filename,
+				Start:    diagnosticPos{Line: rng.Start.Line, Column: rng.Start.Column, Byte: rng.Start.Byte},
+				End:      diagnosticPos{Line: rng.End.Line, Column: rng.End.Column, Byte: rng.End.Byte},
+			}
+			if f, ok := files[rng.Filename]; ok {
+				entry.Source = sourceLines(f.Bytes, rng)
+			}
+		}
+		entries[i] = entry
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Diagnostics []diagnosticJSON `json:"diagnostics"`
+	}{Diagnostics: entries})
+}
+
+// diagnosticSeverityString returns the name of the given severity as used by WriteDiagnosticsJSON.
+func diagnosticSeverityString(severity hcl.DiagnosticSeverity) string {
+	switch severity {
+	case hcl.DiagError:
+		return "error"
+	case hcl.DiagWarning:
+		return "warning"
+	default:
+		return "invalid"
+	}
+}
+
+// sourceLines returns the text of the whole lines of src that the given range spans, without the trailing newline.
+// If the range's offsets do not lie within src, sourceLines returns the empty string.
+func sourceLines(src []byte, rng hcl.Range) string {
+	start, end := rng.Start.Byte, rng.End.Byte
+	if start < 0 || end < start || end > len(src) {
+		return ""
+	}
+	for start > 0 && src[start-1] != '\n' {
+		start--
+	}
+	for end < len(src) && src[end] != '\n' {
+		end++
+	}
+	return strings.TrimSuffix(string(src[start:end]), "\r")
+}
+
+// NewSortedDiagnosticWriter creates a new SortedDiagnosticWriter for use with diagnostics generated by the program.
+func (p *Program) NewSortedDiagnosticWriter(w io.Writer, width uint, color bool) *SortedDiagnosticWriter {
+	return &SortedDiagnosticWriter{w: p.NewDiagnosticWriter(w, width, color)}
+}
+
+// SortedDiagnosticWriter is an hcl.DiagnosticWriter that buffers diagnostics until Flush is called. Flush writes the
+// buffered diagnostics ordered by file, line, and column, so the output does not depend on the order in which the
+// diagnostics were generated. Diagnostics without a subject are written last.
+type SortedDiagnosticWriter struct {
+	w           hcl.DiagnosticWriter
+	diagnostics hcl.Diagnostics
+}
+
+// WriteDiagnostic buffers a single diagnostic.
+func (w *SortedDiagnosticWriter) WriteDiagnostic(diag *hcl.Diagnostic) error {
+	w.diagnostics = append(w.diagnostics, diag)
+	return nil
+}
+
+// WriteDiagnostics buffers the given diagnostics.
+func (w *SortedDiagnosticWriter) WriteDiagnostics(diags hcl.Diagnostics) error {
+	w.diagnostics = append(w.diagnostics, diags...)
+	return nil
+}
+
+// Flush sorts and writes the buffered diagnostics.
+func (w *SortedDiagnosticWriter) Flush() error {
+	diags := w.diagnostics
+	w.diagnostics = nil
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		si, sj := diags[i].Subject, diags[j].Subject
+		switch {
+		case si == nil || sj == nil:
+			return si != nil && sj == nil
+		case si.Filename != sj.Filename:
+			return si.Filename < sj.Filename
+		case si.Start.Line != sj.Start.Line:
+			return si.Start.Line < sj.Start.Line
+		default:
+			return si.Start.Column < sj.Start.Column
+		}
+	})
+	return w.w.WriteDiagnostics(diags)
+}
+
 // BindExpression binds an HCL2 expression in the top-level context of the program.
 func (p *Program) BindExpression(node hclsyntax.Node) (model.Expression, hcl.Diagnostics) {
-	return p.binder.bindExpression(node)
+	return p.BindExpressionWithScope(node, nil)
+}
+
+// BindExpressionWithScope binds an HCL2 expression in the top-level context of the program with the definitions in
+// the given scope also in scope. Definitions in the given scope shadow top-level definitions of the same name. If the
+// scope is nil, this is equivalent to BindExpression.
+func (p *Program) BindExpressionWithScope(node hclsyntax.Node, scope *model.Scope) (model.Expression, hcl.Diagnostics) {
+	return p.binder.bindExpression(node, scope)
+}
+
+// ExpressionAssignableTo returns true if the type of the given expression, which is typically the result of
+// BindExpression, can be converted to the type t under the model's conversion rules. Unsafe conversions, such as
+// those from a string to a number, count as assignable. If the expression's type is not assignable, the returned
+// diagnostics describe why.
+func (p *Program) ExpressionAssignableTo(expr model.Expression, t model.Type) (bool, hcl.Diagnostics) {
+	if t.ConversionFrom(expr.Type()).Exists() {
+		return true, nil
+	}
+	rng := expr.SyntaxNode().Range()
+	return false, hcl.Diagnostics{
+		errorf(rng, "cannot assign expression of type %v to location of type %v", expr.Type(), t),
+	}
+}
+
+// NodeByName returns the node with the given lexical name, if any. Because config variables, locals, resources, and
+// outputs share a single namespace, the first matching node in declaration order is returned.
+func (p *Program) NodeByName(name string) (Node, bool) {
+	for _, n := range p.Nodes {
+		if n.Name() == name {
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// FindNodesByType returns the nodes whose types are equal to t, in declaration order. If assignable is true, the nodes
+// whose types are assignable to t are returned instead; for example, a node of type string is assignable to a
+// location of type union(string, number) but is not equal to it. Nodes without types are never returned.
+func (p *Program) FindNodesByType(t model.Type, assignable bool) []Node {
+	var nodes []Node
+	for _, n := range p.Nodes {
+		typ := n.Type()
+		if typ == nil {
+			continue
+		}
+		if assignable && t.AssignableFrom(typ) || !assignable && t.Equals(typ) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// NodeTypeString returns the canonical string form of the given node's type, as printed by the type's String method.
+// Serializers and manifest generators should use it so that node types are represented consistently. If the node has
+// no type, NodeTypeString returns the empty string.
+func (p *Program) NodeTypeString(n Node) string {
+	typ := n.Type()
+	if typ == nil {
+		return ""
+	}
+	return typ.String()
+}
+
+// References returns the source ranges of every reference to the given node in the program, including references
+// inside template interpolations, function calls, and components. The ranges are returned in source order.
+func (p *Program) References(n Node) []hcl.Range {
+	var ranges []hcl.Range
+	collect := func(x model.Expression) (model.Expression, hcl.Diagnostics) {
+		if traversal, ok := x.(*model.ScopeTraversalExpression); ok && len(traversal.Parts) > 0 {
+			if ref, ok := traversal.Parts[0].(Node); ok && ref == n {
+				ranges = append(ranges, traversal.SyntaxNode().Range())
+			}
+		}
+		return x, nil
+	}
+	for _, node := range p.allNodes() {
+		diags := node.VisitExpressions(collect, model.IdentityVisitor)
+		contract.Assert(len(diags) == 0)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Filename != ranges[j].Filename {
+			return ranges[i].Filename < ranges[j].Filename
+		}
+		return ranges[i].Start.Byte < ranges[j].Start.Byte
+	})
+	return ranges
+}
+
+// RenameNode renames the given top-level node to newName and rewrites every reference to the node in the program to
+// use the new name, in the bound program, in its syntax, and in its source text, so that BindExpression resolves the
+// new name and Clone and WriteSource reflect the rename. Source ranges that were reported before the rename are not
+// updated. The logical names of resources and outputs are preserved, so renaming a node does not rename the
+// corresponding cloud resource or stack output. If newName is not a valid identifier or is already declared, the node
+// is left unchanged and an error diagnostic is returned.
+func (p *Program) RenameNode(n Node, newName string) hcl.Diagnostics {
+	oldName := n.Name()
+	if newName == oldName {
+		return nil
+	}
+
+	subject := n.SyntaxNode().Range()
+	if !p.hasNode(n) {
+		return hcl.Diagnostics{errorf(subject, "cannot rename %q: it is not a top-level node of the program", oldName)}
+	}
+	if !isValidNodeName(newName) {
+		return hcl.Diagnostics{errorf(subject, "cannot rename %q: %q is not a valid identifier", oldName, newName)}
+	}
+	if existing, ok := p.binder.root.BindReference(newName); ok {
+		if _, isNode := existing.(Node); isNode {
+			return hcl.Diagnostics{duplicateDeclaration(newName, existing, n)}
+		}
+		return hcl.Diagnostics{errorf(subject, "cannot rename %q: %q is reserved", oldName, newName)}
+	}
+
+	var edits []sourceEdit
+	renameLabel := func(block *hclsyntax.Block) {
+		block.Labels[0] = newName
+		edits = append(edits, sourceEdit{rng: block.LabelRanges[0], text: newName})
+	}
+	switch n := n.(type) {
+	case *LocalVariable:
+		n.syntax.Name = newName
+		n.Definition.Name = newName
+		edits = append(edits, sourceEdit{rng: n.syntax.NameRange, text: newName})
+	case *ConfigVariable:
+		renameLabel(n.syntax)
+		n.Definition.Labels[0] = newName
+	case *Resource:
+		if n.logicalName == "" {
+			n.logicalName = oldName
+		}
+		renameLabel(n.syntax)
+		n.Definition.Labels[0] = newName
+	case *OutputVariable:
+		if n.logicalName == "" {
+			n.logicalName = oldName
+		}
+		renameLabel(n.syntax)
+		n.Definition.Labels[0] = newName
+	case *ComponentInstance:
+		renameLabel(n.syntax)
+		if n.Definition != nil {
+			n.Definition.Labels[0] = newName
+		}
+	default:
+		return hcl.Diagnostics{errorf(subject, "cannot rename %q: unsupported node type %T", oldName, n)}
+	}
+
+	// Rebind the name in the program's scope.
+	if def, ok := p.binder.root.BindReference(oldName); ok && def == n {
+		p.binder.root.Undefine(oldName)
+	}
+	p.binder.root.Define(newName, n)
+
+	rename := func(x model.Expression) (model.Expression, hcl.Diagnostics) {
+		traversal, ok := x.(*model.ScopeTraversalExpression)
+		if !ok || len(traversal.Parts) == 0 {
+			return x, nil
+		}
+		if ref, ok := traversal.Parts[0].(Node); !ok || ref != n {
+			return x, nil
+		}
+
+		root := traversal.Traversal[0].(hcl.TraverseRoot)
+		root.Name = newName
+		traversal.Traversal = append(hcl.Traversal{root}, traversal.Traversal[1:]...)
+		traversal.RootName = newName
+		if traversal.Tokens != nil {
+			traversal.Tokens.Root.Raw.Bytes = []byte(newName)
+		}
+		if traversal.Syntax != nil {
+			traversal.Syntax.Traversal = append(hcl.Traversal{root}, traversal.Syntax.Traversal[1:]...)
+		}
+		edits = append(edits, sourceEdit{rng: root.SrcRange, text: newName})
+		return x, nil
+	}
+	for _, node := range p.allNodes() {
+		diags := node.VisitExpressions(rename, model.IdentityVisitor)
+		contract.Assert(len(diags) == 0)
+	}
+
+	p.editSource(edits)
+	return nil
+}
+
+// hasNode returns true if n is one of the program's top-level nodes.
+func (p *Program) hasNode(n Node) bool {
+	for _, node := range p.Nodes {
+		if node == n {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceEdit replaces the source text in a range with new text. A quoted string in the range is replaced by the new
+// text in quotes.
+type sourceEdit struct {
+	rng  hcl.Range
+	text string
+}
+
+// editSource applies the given edits to the source text of the program's files. The edited files replace the
+// program's files rather than being modified in place, as the files may be shared with other programs.
+func (p *Program) editSource(edits []sourceEdit) {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].rng.Start.Byte > edits[j].rng.Start.Byte
+	})
+
+	files := make([]*syntax.File, len(p.files))
+	for i, f := range p.files {
+		files[i] = f
+
+		var src []byte
+		var applied []sourceEdit
+		for _, edit := range edits {
+			start, end := p.editedOffset(f.Name, edit.rng.Start.Byte), p.editedOffset(f.Name, edit.rng.End.Byte)
+			if edit.rng.Filename != f.Name || end > len(f.Bytes) {
+				continue
+			}
+			if src == nil {
+				src = append([]byte(nil), f.Bytes...)
+			}
+
+			text := edit.text
+			if old := src[start:end]; len(old) > 0 && old[0] == '"' {
+				text = strconv.Quote(text)
+			}
+			edited := make([]byte, 0, len(src)+len(text))
+			edited = append(edited, src[:start]...)
+			edited = append(edited, text...)
+			src = append(edited, src[end:]...)
+			applied = append(applied, sourceEdit{rng: edit.rng, text: text})
+		}
+		p.sourceEdits = append(p.sourceEdits, applied...)
+		if src != nil {
+			files[i] = &syntax.File{Name: f.Name, Body: f.Body, Bytes: src, Tokens: f.Tokens}
+		}
+	}
+	p.files = files
+}
+
+// editedOffset translates a byte offset in the original source text of the named file into the corresponding offset
+// in the file's current source text, accounting for the edits that have already been applied.
+func (p *Program) editedOffset(filename string, offset int) int {
+	edited := offset
+	for _, edit := range p.sourceEdits {
+		if edit.rng.Filename == filename && edit.rng.End.Byte <= offset {
+			edited += len(edit.text) - (edit.rng.End.Byte - edit.rng.Start.Byte)
+		}
+	}
+	return edited
+}
+
+// EachExpression calls fn with every expression in the bodies of the program's nodes, in declaration order. The
+// expressions in each node are passed in pre-order, i.e. each expression before its operands. If fn returns true,
+// the traversal stops immediately: fn is not called again, and no later node is visited.
+func (p *Program) EachExpression(fn func(x model.Expression) (stop bool)) {
+	stopped := false
+	pre := func(x model.Expression) (model.Expression, hcl.Diagnostics) {
+		if stopped {
+			return x, nil
+		}
+		if fn(x) {
+			stopped = true
+			return x, hcl.Diagnostics{StopVisiting}
+		}
+		return x, nil
+	}
+	for _, n := range p.Nodes {
+		// The post-order visitor is required for VisitExpressions to descend into each expression's operands.
+		for _, d := range n.VisitExpressions(pre, model.IdentityVisitor) {
+			contract.Assertf(d == StopVisiting, "unexpected diagnostic while visiting expressions: %v", d)
+		}
+		if stopped {
+			return
+		}
+	}
+}
+
+// UnusedNodes returns the config variables and locals declared by the program that no other node depends on, in
+// declaration order. Dependencies from component inputs and from outputs count as uses. Resources, outputs, and
+// component instances have effects even if nothing refers to them, so they are never reported.
+func (p *Program) UnusedNodes() []Node {
+	used := map[Node]bool{}
+	for _, n := range p.allNodes() {
+		for _, d := range n.getDependencies() {
+			if d != n {
+				used[d] = true
+			}
+		}
+	}
+
+	var unused []Node
+	for _, n := range p.Nodes {
+		switch n.Kind() {
+		case NodeKindConfig, NodeKindLocal:
+			if !used[n] {
+				unused = append(unused, n)
+			}
+		}
+	}
+	return unused
+}
+
+// ConfigNodes returns the config variables declared by the program, in declaration order. Config variables declared
+// inside components are not included.
+func (p *Program) ConfigNodes() []*ConfigVariable {
+	var config []*ConfigVariable
+	for _, n := range p.Nodes {
+		if cv, ok := n.(*ConfigVariable); ok {
+			config = append(config, cv)
+		}
+	}
+	return config
+}
+
+// Outputs returns the output variables declared by the program, in declaration order. Outputs declared inside
+// components are not included.
+func (p *Program) Outputs() []*OutputVariable {
+	var outputs []*OutputVariable
+	for _, n := range p.Nodes {
+		if ov, ok := n.(*OutputVariable); ok {
+			outputs = append(outputs, ov)
+		}
+	}
+	return outputs
+}
+
+// ValidateOutputs checks that the values of the program's outputs can be exported, so that problems that would
+// otherwise surface at deploy time are reported up front. It returns an error for each reference to an undefined
+// variable in an output's value, a warning for each output whose value has an unknown type, and a warning for each
+// output whose value is secret. Outputs declared inside components are not checked.
+func (p *Program) ValidateOutputs() hcl.Diagnostics {
+	var diagnostics hcl.Diagnostics
+	for _, ov := range p.Outputs() {
+		if ov.Value == nil {
+			continue
+		}
+
+		var outputDiags hcl.Diagnostics
+		secret := false
+		visit := func(x model.Expression) (model.Expression, hcl.Diagnostics) {
+			switch x := x.(type) {
+			case *model.ScopeTraversalExpression:
+				if len(x.Parts) > 0 && x.Parts[0] == model.DynamicType {
+					rng := x.SyntaxNode().Range()
+					outputDiags = append(outputDiags, undefinedOutputReference(ov.Name(), x.RootName, rng))
+				}
+			case *model.FunctionCallExpression:
+				if x.Name == "secret" {
+					secret = true
+				}
+			}
+			return x, nil
+		}
+		_, diags := model.VisitExpression(ov.Value, visit, model.IdentityVisitor)
+		contract.Assert(len(diags) == 0)
+
+		valueRange := ov.Value.SyntaxNode().Range()
+		if !outputDiags.HasErrors() && model.ResolveOutputs(ov.Value.Type()) == model.DynamicType {
+			outputDiags = append(outputDiags, unknownOutputType(ov.Name(), valueRange))
+		}
+		if secret {
+			outputDiags = append(outputDiags, secretOutput(ov.Name(), valueRange))
+		}
+		diagnostics = append(diagnostics, outputDiags...)
+	}
+	return diagnostics
+}
+
+// NodeCounts returns the number of nodes of each kind in the program. Only kinds with at least one node are included.
+// Nodes declared inside components are not counted; each instantiation of a component counts as one node of kind
+// NodeKindComponent.
+func (p *Program) NodeCounts() map[NodeKind]int {
+	counts := map[NodeKind]int{}
+	for _, n := range p.Nodes {
+		counts[n.Kind()]++
+	}
+	return counts
+}
+
+// ResourceCount returns the number of resources declared by the program. Resources declared inside components are not
+// counted.
+func (p *Program) ResourceCount() int {
+	count := 0
+	for _, n := range p.Nodes {
+		if n.Kind() == NodeKindResource {
+			count++
+		}
+	}
+	return count
+}
+
+// TopologicalNodes returns the nodes in the program in dependency order: every node appears after all of the nodes it
+// depends on. Nodes that do not depend on one another are kept in declaration order. If the program contains
+// circular references, each cycle is reported as a diagnostic that names the nodes that participate in it, and the
+// cycle is broken at the node that closes it.
+func (p *Program) TopologicalNodes() ([]Node, hcl.Diagnostics) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	state := map[Node]int{}
+	nodes := make([]Node, 0, len(p.Nodes))
+	var path []Node
+	var diagnostics hcl.Diagnostics
+
+	var visit func(n Node)
+	visit = func(n Node) {
+		switch state[n] {
+		case visited:
+			return
+		case visiting:
+			for i := len(path) - 1; i >= 0; i-- {
+				if path[i] == n {
+					diagnostics = append(diagnostics, circularReference(path[i:]))
+					break
+				}
+			}
+			return
+		}
+
+		state[n] = visiting
+		path = append(path, n)
+		for _, d := range n.getDependencies() {
+			visit(d)
+		}
+		path = path[:len(path)-1]
+		state[n] = visited
+
+		nodes = append(nodes, n)
+	}
+	for _, n := range p.Nodes {
+		visit(n)
+	}
+
+	return nodes, diagnostics
+}
+
+// StopVisiting is a sentinel diagnostic that a NodeVisitor may include in its result to end a call to Program.Visit.
+// The sentinel itself is never included in the diagnostics returned by Visit.
+var StopVisiting = &hcl.Diagnostic{Summary: "stop visiting"}
+
+// A NodeVisitor is a function that visits a single node in a program.
+type NodeVisitor func(n Node) hcl.Diagnostics
+
+// Visit walks the nodes in the program, calling pre before and post after each node. If every node in the program
+// has been bound, the nodes are visited in topological order as returned by TopologicalNodes; otherwise they are
+// visited in declaration order. Either callback may be nil. Diagnostics returned by the callbacks are accumulated and
+// do not interrupt the walk. If a callback's diagnostics include StopVisiting, the walk ends immediately: when pre
+// stops the walk, post is not called for that node.
+func (p *Program) Visit(pre, post NodeVisitor) hcl.Diagnostics {
+	nodes := p.Nodes
+	if p.isBound() {
+		// Any circular references were already reported when the program was bound.
+		nodes, _ = p.TopologicalNodes()
+	}
+
+	var diagnostics hcl.Diagnostics
+	call := func(visitor NodeVisitor, n Node) bool {
+		if visitor == nil {
+			return true
+		}
+		stop := false
+		for _, d := range visitor(n) {
+			if d == StopVisiting {
+				stop = true
+				continue
+			}
+			diagnostics = append(diagnostics, d)
+		}
+		return !stop
+	}
+	for _, n := range nodes {
+		if !call(pre, n) || !call(post, n) {
+			break
+		}
+	}
+	return diagnostics
+}
+
+// IsBound returns true if binding the program completed successfully: every node in the program has been bound, and
+// binding reported no errors. Callers may check it before operations such as Packages that panic on a program whose
+// binding failed.
+func (p *Program) IsBound() bool {
+	return p.isBound() && !p.diagnostics.HasErrors()
+}
+
+// isBound returns true if every node in the program has been bound, whether or not binding it reported errors.
+func (p *Program) isBound() bool {
+	for _, n := range p.Nodes {
+		if !n.isBound() {
+			return false
+		}
+	}
+	return true
+}
+
+// graphNode is the serialized form of a node in a program's dependency graph.
+type graphNode struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Kind         string   `json:"kind"`
+	Dependencies []string `json:"dependencies"`
+}
+
+// MarshalGraph writes a JSON description of the program's dependency graph to w. Each node is described by its name,
+// type, kind, and the names of the nodes it depends on. Nodes and dependencies are sorted by name so that the output
+// is deterministic.
+func (p *Program) MarshalGraph(w io.Writer) error {
+	nodes := make([]graphNode, len(p.Nodes))
+	for i, n := range p.Nodes {
+		deps := make([]string, 0, len(n.getDependencies()))
+		for _, d := range n.getDependencies() {
+			deps = append(deps, d.Name())
+		}
+		sort.Strings(deps)
+
+		nodes[i] = graphNode{
+			Name:         n.Name(),
+			Type:         p.NodeTypeString(n),
+			Kind:         string(n.Kind()),
+			Dependencies: deps,
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Nodes []graphNode `json:"nodes"`
+	}{Nodes: nodes})
+}
+
+// Equal returns true if p and other are structurally equivalent: they have nodes with the same names, and nodes with
+// the same name have the same kind and type and depend on nodes with the same names. The order of the nodes, their
+// source positions, and the identity of the underlying objects are ignored, so Equal may compare a program with the
+// result of binding it again after a round trip through another representation.
+func (p *Program) Equal(other *Program) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	if len(p.Nodes) != len(other.Nodes) {
+		return false
+	}
+
+	nodes := make(map[string]Node, len(other.Nodes))
+	for _, n := range other.Nodes {
+		nodes[n.Name()] = n
+	}
+	for _, n := range p.Nodes {
+		o, ok := nodes[n.Name()]
+		if !ok || n.Kind() != o.Kind() || !sameType(n.Type(), o.Type()) {
+			return false
+		}
+		if !sameDependencyNames(n.getDependencies(), o.getDependencies()) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameType returns true if the two types are equal. The type of a node that failed to bind may be nil, and is only
+// the same as another nil type.
+func sameType(a, b model.Type) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equals(b)
+}
+
+// sameDependencyNames returns true if the two lists of dependencies name the same set of nodes.
+func sameDependencyNames(a, b []Node) bool {
+	names := func(deps []Node) codegen.StringSet {
+		set := codegen.NewStringSet()
+		for _, d := range deps {
+			set.Add(d.Name())
+		}
+		return set
+	}
+	x, y := names(a), names(b)
+	return x.Contains(y) && y.Contains(x)
 }
 
-// Packages returns the list of package referenced used by this program.
+// WriteDependencyGraphDOT writes the dependency graph of the program's nodes to w in the Graphviz DOT language. Each
+// node is labeled with its name and kind, and each dependency is drawn as an edge from a node to the node it depends
+// on. Cycles are written as they are, but the nodes that participate in one are colored red. Nodes are written in
+// declaration order and each node's edges are sorted by name, so the output is deterministic.
+func (p *Program) WriteDependencyGraphDOT(w io.Writer) error {
+	cyclic := p.cyclicNodes()
+
+	var b strings.Builder
+	b.WriteString("digraph program {\n")
+	for _, n := range p.Nodes {
+		label := fmt.Sprintf("%s\n%s", n.Name(), n.Kind())
+		if cyclic[n] {
+			fmt.Fprintf(&b, "\t%q [label=%q, color=red, fontcolor=red];\n", n.Name(), label)
+		} else {
+			fmt.Fprintf(&b, "\t%q [label=%q];\n", n.Name(), label)
+		}
+	}
+	for _, n := range p.Nodes {
+		deps := make([]string, 0, len(n.getDependencies()))
+		for _, d := range n.getDependencies() {
+			deps = append(deps, d.Name())
+		}
+		sort.Strings(deps)
+		for _, d := range deps {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", n.Name(), d)
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cyclicNodes returns the nodes in the program that participate in a cycle, i.e. the nodes that belong to a strongly
+// connected component of the dependency graph with more than one node, or that depend on themselves.
+func (p *Program) cyclicNodes() map[Node]bool {
+	index, lowlink := map[Node]int{}, map[Node]int{}
+	onStack := map[Node]bool{}
+	var stack []Node
+	cyclic := map[Node]bool{}
+
+	// This is Tarjan's strongly connected components algorithm.
+	var connect func(n Node)
+	connect = func(n Node) {
+		index[n], lowlink[n] = len(index), len(index)
+		stack = append(stack, n)
+		onStack[n] = true
+
+		for _, d := range n.getDependencies() {
+			if d == n {
+				cyclic[n] = true
+			}
+			if _, ok := index[d]; !ok {
+				connect(d)
+				if lowlink[d] < lowlink[n] {
+					lowlink[n] = lowlink[d]
+				}
+			} else if onStack[d] && index[d] < lowlink[n] {
+				lowlink[n] = index[d]
+			}
+		}
+
+		if lowlink[n] == index[n] {
+			var component []Node
+			for {
+				m := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[m] = false
+				component = append(component, m)
+				if m == n {
+					break
+				}
+			}
+			if len(component) > 1 {
+				for _, m := range component {
+					cyclic[m] = true
+				}
+			}
+		}
+	}
+	for _, n := range p.Nodes {
+		if _, ok := index[n]; !ok {
+			connect(n)
+		}
+	}
+	return cyclic
+}
+
+// DependencyPath returns the shortest chain of dependencies through which from depends on to, starting with from and
+// ending with to, or nil if from does not depend on to, directly or indirectly. Each node in the chain depends on the
+// next. Given the same node twice, DependencyPath returns just that node. It is useful for explaining why one node is
+// ordered after another.
+func (p *Program) DependencyPath(from, to Node) []Node {
+	if from == nil || to == nil {
+		return nil
+	}
+
+	// Search the dependency graph breadth-first, remembering how each node was reached.
+	previous := map[Node]Node{from: nil}
+	queue := []Node{from}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n == to {
+			var path []Node
+			for ; n != nil; n = previous[n] {
+				path = append([]Node{n}, path...)
+			}
+			return path
+		}
+		for _, d := range n.getDependencies() {
+			if _, ok := previous[d]; !ok {
+				previous[d] = n
+				queue = append(queue, d)
+			}
+		}
+	}
+	return nil
+}
+
+// Packages returns the list of package referenced used by this program. It is safe to call concurrently.
 func (p *Program) Packages() []*schema.Package {
+	defs, diags := p.PackagesWithDiagnostics()
+	if diags.HasErrors() {
+		panic(fmt.Errorf("loading package definition: %w", diags))
+	}
+	return defs
+}
+
+// PackagesWithDiagnostics returns the list of packages referenced by this program that could be loaded. Each package
+// that fails to load is reported as a diagnostic that names the package and the node that referenced it. It is safe
+// to call concurrently.
+func (p *Program) PackagesWithDiagnostics() ([]*schema.Package, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
 	refs := p.PackageReferences()
-	defs := make([]*schema.Package, len(refs))
-	for i, ref := range refs {
+	defs := make([]*schema.Package, 0, len(refs))
+	for _, ref := range refs {
+		ref, err := p.resolvePackageReference(ref)
+		if err != nil {
+			diags = append(diags, packageLoadError(ref.Name(), err, p.packageReferrers[ref.Name()]))
+			continue
+		}
 		def, err := ref.Definition()
 		if err != nil {
-			panic(fmt.Errorf("loading package definition: %w", err))
+			diags = append(diags, packageLoadError(ref.Name(), err, p.packageReferrers[ref.Name()]))
+			continue
 		}
-		defs[i] = def
+		defs = append(defs, def)
 	}
-	return defs
+	return defs, diags
 }
 
-// PackageReferences returns the list of package referenced used by this program.
+// PackageReferences returns the list of package referenced used by this program, sorted by name. The references are
+// finalized when the program is bound, so it is safe to call concurrently and each call returns the same references.
 func (p *Program) PackageReferences() []schema.PackageReference {
-	keys := make([]string, 0, len(p.binder.referencedPackages))
-	for k := range p.binder.referencedPackages {
-		keys = append(keys, k)
+	values := make([]schema.PackageReference, 0, len(p.packages))
+	for _, k := range p.ReferencedPackageNames() {
+		values = append(values, p.packages[k])
+	}
+	return values
+}
+
+// ReferencedPackageCount returns the number of distinct packages referenced by this program. No package definitions
+// are loaded, so it is cheap enough to use for progress reporting. It is safe to call concurrently.
+func (p *Program) ReferencedPackageCount() int {
+	return len(p.packages)
+}
+
+// ReferencedPackageNames returns the sorted names of the packages referenced by this program. No package definitions
+// are loaded, so it is cheap enough to use for progress reporting. It is safe to call concurrently.
+func (p *Program) ReferencedPackageNames() []string {
+	names := make([]string, 0, len(p.packages))
+	for name := range p.packages {
+		names = append(names, name)
 	}
-	sort.Strings(keys)
+	sort.Strings(names)
+	return names
+}
 
-	values := make([]schema.PackageReference, 0, len(p.binder.referencedPackages))
-	for _, k := range keys {
-		values = append(values, p.binder.referencedPackages[k])
+// PackageVersions returns a map from the name of each package referenced by this program to its version. Versions are
+// read from the package references themselves, so no package definitions are loaded. If a package's version is
+// unknown, its version is the empty string. It is safe to call concurrently.
+func (p *Program) PackageVersions() map[string]string {
+	versions := make(map[string]string, len(p.packages))
+	for name, ref := range p.packages {
+		version := ""
+		if v := ref.Version(); v != nil {
+			version = v.String()
+		}
+		versions[name] = version
 	}
-	return values
+	return versions
+}
+
+// ReferencedTokens returns the sorted schema tokens of the resources, functions, and types referenced by this
+// program. Resources are referenced by resource nodes and functions by calls to invoke. Object, enum, and resource
+// types are referenced through the properties of the resources, functions, and object types that use them,
+// transitively. The returned diagnostics describe any invoke tokens that are malformed; such tokens are skipped.
+func (p *Program) ReferencedTokens() ([]string, hcl.Diagnostics) {
+	tokens := codegen.NewStringSet()
+
+	seen := map[schema.Type]bool{}
+	var addType func(t schema.Type)
+	addProperties := func(properties []*schema.Property) {
+		for _, prop := range properties {
+			addType(prop.Type)
+		}
+	}
+	addType = func(t schema.Type) {
+		if t == nil || seen[t] {
+			return
+		}
+		seen[t] = true
+
+		switch t := t.(type) {
+		case *schema.ObjectType:
+			tokens.Add(t.Token)
+			addProperties(t.Properties)
+		case *schema.EnumType:
+			tokens.Add(t.Token)
+		case *schema.ResourceType:
+			tokens.Add(t.Token)
+			if t.Resource != nil {
+				addProperties(t.Resource.InputProperties)
+				addProperties(t.Resource.Properties)
+			}
+		case *schema.TokenType:
+			tokens.Add(t.Token)
+			addType(t.UnderlyingType)
+		case *schema.ArrayType:
+			addType(t.ElementType)
+		case *schema.MapType:
+			addType(t.ElementType)
+		case *schema.InputType:
+			addType(t.ElementType)
+		case *schema.OptionalType:
+			addType(t.ElementType)
+		case *schema.UnionType:
+			for _, e := range t.ElementTypes {
+				addType(e)
+			}
+		}
+	}
+	addFunction := func(fn *schema.Function) {
+		tokens.Add(fn.Token)
+		if fn.Inputs != nil {
+			addProperties(fn.Inputs.Properties)
+		}
+		if fn.Outputs != nil {
+			addProperties(fn.Outputs.Properties)
+		}
+	}
+
+	var diagnostics hcl.Diagnostics
+	for _, n := range p.allNodes() {
+		if r, ok := n.(*Resource); ok {
+			if r.Schema == nil {
+				tokens.Add(r.Token)
+			} else {
+				tokens.Add(r.Schema.Token)
+				addProperties(r.Schema.InputProperties)
+				addProperties(r.Schema.Properties)
+			}
+		}
+
+		diags := n.VisitExpressions(func(x model.Expression) (model.Expression, hcl.Diagnostics) {
+			call, ok := x.(*model.FunctionCallExpression)
+			if !ok || call.Name != Invoke || len(call.Args) == 0 {
+				return x, nil
+			}
+			template, ok := call.Args[0].(*model.TemplateExpression)
+			if !ok || len(template.Parts) != 1 {
+				return x, nil
+			}
+			lit, ok := template.Parts[0].(*model.LiteralValueExpression)
+			if !ok || lit.Value.Type() != cty.String {
+				return x, nil
+			}
+
+			token := lit.Value.AsString()
+			pkg, _, _, diags := DecomposeToken(token, lit.SyntaxNode().Range())
+			if diags.HasErrors() {
+				return x, diags
+			}
+			if pkgSchema, ok := p.binder.options.packageCache.getPackageSchema(pkg); ok {
+				if fn, _, ok, err := pkgSchema.LookupFunction(token); err == nil && ok {
+					addFunction(fn)
+					return x, nil
+				}
+			}
+			tokens.Add(token)
+			return x, nil
+		}, model.IdentityVisitor)
+		diagnostics = append(diagnostics, diags...)
+	}
+
+	return tokens.SortedValues(), diagnostics
 }
 
 // PackageSnapshots returns the list of packages schemas used by this program. If a referenced package is partial,
 // its returned value is a snapshot that contains only the package members referenced by the program. Otherwise, its
 // returned value is the full package definition.
+//
+// The snapshots are computed by the first successful call and cached thereafter. Each call returns a fresh slice, so
+// callers may modify the result without affecting other callers. It is safe to call concurrently.
 func (p *Program) PackageSnapshots() ([]*schema.Package, error) {
-	keys := make([]string, 0, len(p.binder.referencedPackages))
-	for k := range p.binder.referencedPackages {
-		keys = append(keys, k)
+	p.snapshotsLock.Lock()
+	defer p.snapshotsLock.Unlock()
+
+	if p.snapshots == nil {
+		snapshots, err := p.packageSnapshots()
+		if err != nil {
+			return nil, err
+		}
+		p.snapshots = snapshots
 	}
-	sort.Strings(keys)
 
-	values := make([]*schema.Package, 0, len(p.binder.referencedPackages))
-	for _, k := range keys {
-		ref := p.binder.referencedPackages[k]
+	result := make([]*schema.Package, len(p.snapshots))
+	copy(result, p.snapshots)
+	return result, nil
+}
+
+func (p *Program) packageSnapshots() ([]*schema.Package, error) {
+	// A partial package from the program's package loader only snapshots the members that the program referenced, as
+	// it was bound against them. One that is freshly loaded hasn't been bound against, so look those members up first.
+	var tokens []string
+	if p.loader != nil {
+		// Malformed invoke tokens were reported when the program was bound, and are skipped here.
+		tokens, _ = p.ReferencedTokens()
+	}
+
+	refs := p.PackageReferences()
+	values := make([]*schema.Package, 0, len(refs))
+	for _, ref := range refs {
+		ref, err := p.resolvePackageReference(ref)
+		if err != nil {
+			return nil, fmt.Errorf("loading package '%v': %w", ref.Name(), err)
+		}
 
 		var pkg *schema.Package
-		var err error
 		if partial, ok := ref.(*schema.PartialPackage); ok {
-			pkg, err = partial.Snapshot()
+			if err = loadPackageMembers(partial, tokens); err == nil {
+				pkg, err = partial.Snapshot()
+			}
 		} else {
 			pkg, err = ref.Definition()
 		}
@@ -159,3 +1359,43 @@ func (p *Program) PackageSnapshots() ([]*schema.Package, error) {
 	}
 	return values, nil
 }
+
+// resolvePackageReference returns the reference to the given bound package through the program's package loader, if
+// it has one, or else the bound reference itself.
+func (p *Program) resolvePackageReference(ref schema.PackageReference) (schema.PackageReference, error) {
+	if p.loader == nil {
+		return ref, nil
+	}
+	resolved, err := schema.LoadPackageReference(p.loader, ref.Name(), ref.Version())
+	if err != nil {
+		return ref, err
+	}
+	return resolved, nil
+}
+
+// loadPackageMembers looks up each of the given tokens that belongs to pkg, so that they are included in its snapshot.
+func loadPackageMembers(pkg *schema.PartialPackage, tokens []string) error {
+	for _, token := range tokens {
+		if !strings.HasPrefix(token, pkg.Name()+":") {
+			continue
+		}
+		_, isResource, err := pkg.Resources().Get(token)
+		if err != nil {
+			return err
+		}
+		if isResource {
+			continue
+		}
+		_, isFunction, err := pkg.Functions().Get(token)
+		if err != nil {
+			return err
+		}
+		if isFunction {
+			continue
+		}
+		if _, _, err = pkg.Types().Get(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,2141 @@
+package pcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/hcl2/model"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/hcl2/syntax"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/testing/utils"
+)
+
+// bindProgramText parses and binds the given PCL source as a single-file program.
+func bindProgramText(t *testing.T, source string) (*Program, hcl.Diagnostics) {
+	parser := syntax.NewParser()
+	err := parser.ParseFile(strings.NewReader(source), "main.pp")
+	require.NoError(t, err)
+	require.False(t, parser.Diagnostics.HasErrors(), "failed to parse program: %v", parser.Diagnostics)
+
+	program, diags, err := BindProgram(parser.Files, PluginHost(utils.NewHost(testdataPath)))
+	require.NoError(t, err)
+	return program, diags
+}
+
+// bindProgramWithComponents writes the given component files to a temporary directory and binds the given PCL source
+// as a single-file program in that directory. The keys of files are slash-separated paths relative to the directory.
+func bindProgramWithComponents(t *testing.T, source string, files map[string]string) (*Program, hcl.Diagnostics) {
+	dir := t.TempDir()
+	for path, contents := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	}
+
+	parser := syntax.NewParser()
+	err := parser.ParseFile(strings.NewReader(source), "main.pp")
+	require.NoError(t, err)
+	require.False(t, parser.Diagnostics.HasErrors(), "failed to parse program: %v", parser.Diagnostics)
+
+	program, diags, err := BindProgram(parser.Files, PluginHost(utils.NewHost(testdataPath)), DirPath(dir))
+	require.NoError(t, err)
+	return program, diags
+}
+
+// newTestLocal creates an unbound local variable declared on the given line of main.pp.
+func newTestLocal(name string, line int) *LocalVariable {
+	rng := hcl.Range{
+		Filename: "main.pp",
+		Start:    hcl.Pos{Line: line, Column: 1},
+		End:      hcl.Pos{Line: line, Column: len(name) + 1},
+	}
+	return &LocalVariable{
+		syntax:     &hclsyntax.Attribute{Name: name, SrcRange: rng, NameRange: rng},
+		Definition: &model.Attribute{Name: name},
+	}
+}
+
+func nodeNames(nodes []Node) []string {
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = n.Name()
+	}
+	return names
+}
+
+func TestTopologicalNodes(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config prefix string {
+	default = "x"
+}
+
+output result {
+	value = c
+}
+
+c = "${b}-c"
+b = "${a}-b"
+a = "${prefix}-a"
+d = "d"
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	nodes, diags := program.TopologicalNodes()
+	assert.Empty(t, diags)
+	assert.Equal(t, []string{"prefix", "a", "b", "c", "result", "d"}, nodeNames(nodes))
+}
+
+func TestTopologicalNodesCycle(t *testing.T) {
+	t.Parallel()
+
+	// The binder cannot bind circular references, so construct the program by hand.
+	a, b, c := newTestLocal("a", 1), newTestLocal("b", 2), newTestLocal("c", 3)
+	a.setDependencies([]Node{b})
+	b.setDependencies([]Node{a})
+	program := &Program{Nodes: []Node{a, b, c}}
+
+	nodes, diags := program.TopologicalNodes()
+	assert.Equal(t, []string{"b", "a", "c"}, nodeNames(nodes))
+	require.Len(t, diags, 1)
+	assert.Equal(t, hcl.DiagError, diags[0].Severity)
+	assert.Equal(t, "circular reference: a -> b -> a", diags[0].Summary)
+	assert.Contains(t, diags[0].Detail, "a is declared at main.pp:1")
+	assert.Contains(t, diags[0].Detail, "b is declared at main.pp:2")
+}
+
+func TestDependencyPath(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config prefix string {
+	default = "x"
+}
+
+output result {
+	value = "${c}${a}"
+}
+
+c = "${b}-c"
+b = "${a}-b"
+a = "${prefix}-a"
+d = "d"
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	node := func(name string) Node {
+		n, ok := program.NodeByName(name)
+		require.True(t, ok, name)
+		return n
+	}
+
+	// A direct dependency.
+	assert.Equal(t, []string{"a", "prefix"}, nodeNames(program.DependencyPath(node("a"), node("prefix"))))
+	// A transitive dependency.
+	assert.Equal(t, []string{"c", "b", "a", "prefix"}, nodeNames(program.DependencyPath(node("c"), node("prefix"))))
+	// The shortest chain is preferred.
+	assert.Equal(t, []string{"result", "a", "prefix"},
+		nodeNames(program.DependencyPath(node("result"), node("prefix"))))
+	assert.Equal(t, []string{"d"}, nodeNames(program.DependencyPath(node("d"), node("d"))))
+
+	// Dependencies only go one way, and unrelated nodes aren't connected at all.
+	assert.Nil(t, program.DependencyPath(node("prefix"), node("a")))
+	assert.Nil(t, program.DependencyPath(node("d"), node("prefix")))
+}
+
+func TestNodeByName(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config prefix string {
+	default = "x"
+}
+
+name = "${prefix}-pet"
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = name
+}
+
+output petId {
+	value = pet.id
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	cases := []struct {
+		name     string
+		expected Node
+	}{
+		{name: "prefix", expected: &ConfigVariable{}},
+		{name: "name", expected: &LocalVariable{}},
+		{name: "pet", expected: &Resource{}},
+		{name: "petId", expected: &OutputVariable{}},
+	}
+	for _, c := range cases {
+		n, ok := program.NodeByName(c.name)
+		if assert.True(t, ok, "node %q not found", c.name) {
+			assert.Equal(t, c.name, n.Name())
+			assert.IsType(t, c.expected, n)
+		}
+	}
+
+	n, ok := program.NodeByName("missing")
+	assert.False(t, ok)
+	assert.Nil(t, n)
+}
+
+func TestMarshalGraph(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config prefix string {
+	default = "x"
+}
+
+b = "${a}-b"
+a = "${prefix}-a"
+
+output result string {
+	value = "${a}${b}"
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	var buf bytes.Buffer
+	require.NoError(t, program.MarshalGraph(&buf))
+	assert.JSONEq(t, `{
+  "nodes": [
+    {"name": "a", "type": "string", "kind": "local", "dependencies": ["prefix"]},
+    {"name": "b", "type": "string", "kind": "local", "dependencies": ["a"]},
+    {"name": "prefix", "type": "string", "kind": "config", "dependencies": []},
+    {"name": "result", "type": "string", "kind": "output", "dependencies": ["a", "b"]}
+  ]
+}`, buf.String())
+
+	// The output is deterministic.
+	var again bytes.Buffer
+	require.NoError(t, program.MarshalGraph(&again))
+	assert.Equal(t, buf.String(), again.String())
+}
+
+func TestProgramEqual(t *testing.T) {
+	t.Parallel()
+
+	const source = `
+config prefix string {
+	default = "x"
+}
+
+a = "${prefix}-a"
+b = "${a}-b"
+
+output result string {
+	value = "${a}${b}"
+}
+`
+	program, diags := bindProgramText(t, source)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	// Node order, source positions, and formatting don't matter.
+	reordered, diags := bindProgramText(t, `output result string {
+  value = "${a}${b}"
+}
+b = "${a}-b"
+
+
+a = "${prefix}-a"
+config prefix string { default = "y" }
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+	assert.True(t, program.Equal(reordered))
+	assert.True(t, reordered.Equal(program))
+	assert.True(t, program.Equal(program))
+	assert.False(t, program.Equal(nil))
+
+	// A node that failed to bind may have no type, which is only equal to no type.
+	untyped := func() *Program {
+		p, diags := bindProgramText(t, source)
+		require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+		prefix, ok := p.NodeByName("prefix")
+		require.True(t, ok)
+		prefix.(*ConfigVariable).typ = nil
+		return p
+	}
+	assert.False(t, program.Equal(untyped()))
+	assert.False(t, untyped().Equal(program))
+	assert.True(t, untyped().Equal(untyped()))
+
+	cases := map[string]string{
+		"extra node": source + "c = 1\n",
+		"missing node": `
+config prefix string {
+	default = "x"
+}
+
+a = "${prefix}-a"
+
+output result string {
+	value = "${a}"
+}
+`,
+		"different type": strings.Replace(source, `config prefix string {
+	default = "x"
+}`, `config prefix int {
+	default = 1
+}`, 1),
+		"different kind": strings.Replace(source, `config prefix string {
+	default = "x"
+}`, `prefix = "x"`, 1),
+		"different edge": strings.Replace(source, `b = "${a}-b"`, `b = "${prefix}-b"`, 1),
+	}
+	for name, text := range cases {
+		text := text
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			other, diags := bindProgramText(t, text)
+			require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+			assert.False(t, program.Equal(other))
+			assert.False(t, other.Equal(program))
+		})
+	}
+}
+
+func TestEachExpression(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+a = 1
+b = a + 2
+
+output result number {
+	value = b * 3
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	// Every expression in every node is passed, in the same order that the nodes' visitors see them.
+	var expected []model.Expression
+	for _, n := range program.Nodes {
+		n.VisitExpressions(func(x model.Expression) (model.Expression, hcl.Diagnostics) {
+			expected = append(expected, x)
+			return x, nil
+		}, model.IdentityVisitor)
+	}
+	var actual []model.Expression
+	var literals []string
+	program.EachExpression(func(x model.Expression) bool {
+		actual = append(actual, x)
+		if lit, ok := x.(*model.LiteralValueExpression); ok {
+			literals = append(literals, lit.Value.AsBigFloat().String())
+		}
+		return false
+	})
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, []string{"1", "2", "3"}, literals)
+
+	// Returning true stops the traversal.
+	calls := 0
+	program.EachExpression(func(x model.Expression) bool {
+		calls++
+		_, isBinaryOp := x.(*model.BinaryOpExpression)
+		return isBinaryOp
+	})
+	assert.Equal(t, 2, calls)
+
+	// fn is never called again once it stops the traversal, even for the remaining operands of the same node.
+	var seen []model.Expression
+	stopped := false
+	program.EachExpression(func(x model.Expression) bool {
+		assert.False(t, stopped, "fn called after it stopped the traversal")
+		seen = append(seen, x)
+		_, stopped = x.(*model.ScopeTraversalExpression)
+		return stopped
+	})
+	require.Len(t, seen, 3)
+	assert.IsType(t, &model.BinaryOpExpression{}, seen[1])
+	assert.IsType(t, &model.ScopeTraversalExpression{}, seen[2])
+}
+
+func TestIsBound(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+a = 1
+b = a + 1
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+	assert.True(t, program.IsBound())
+
+	// Every node is visited when a program with errors is bound, but binding didn't complete successfully.
+	program, diags = bindProgramText(t, `
+a = 1
+b = c + 1
+`)
+	require.True(t, diags.HasErrors())
+	assert.False(t, program.IsBound())
+
+	// A program whose nodes haven't been bound isn't bound either.
+	assert.False(t, (&Program{Nodes: []Node{newTestLocal("a", 1)}}).IsBound())
+}
+
+func TestWriteDiagnosticsJSON(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `config prefix string {
+	default = "app"
+}
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = prefix
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	rng := func(filename string, start, end hcl.Pos) *hcl.Range {
+		return &hcl.Range{Filename: filename, Start: start, End: end}
+	}
+	diags = hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "unused config",
+			Detail:   `config "prefix" is never used`,
+			Subject:  rng("main.pp", hcl.Pos{Line: 1, Column: 8, Byte: 7}, hcl.Pos{Line: 1, Column: 14, Byte: 13}),
+		},
+		{
+			Severity: hcl.DiagWarning,
+			Summary:  "deprecated resource",
+			Subject:  rng("main.pp", hcl.Pos{Line: 5, Column: 1, Byte: 43}, hcl.Pos{Line: 7, Column: 2, Byte: 111}),
+		},
+		{
+			Severity: hcl.DiagError,
+			Summary:  "reference to config",
+			Detail:   `the value of "prefix" is not known`,
+			Subject:  rng("main.pp", hcl.Pos{Line: 6, Column: 11, Byte: 103}, hcl.Pos{Line: 6, Column: 17, Byte: 109}),
+		},
+		{
+			Severity: hcl.DiagError,
+			Summary:  "error loading package 'aws'",
+			Detail:   "plugin not found",
+		},
+		{
+			Severity: hcl.DiagWarning,
+			Summary:  "unknown file",
+			Subject:  rng("other.pp", hcl.Pos{Line: 1, Column: 1, Byte: 0}, hcl.Pos{Line: 1, Column: 5, Byte: 4}),
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, program.WriteDiagnosticsJSON(&buf, diags))
+
+	goldenPath := filepath.Join("testdata", "diagnostics.json")
+	if os.Getenv("PULUMI_ACCEPT") != "" {
+		require.NoError(t, ioutil.WriteFile(goldenPath, buf.Bytes(), 0600))
+	}
+	expected, err := ioutil.ReadFile(goldenPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), buf.String())
+
+	// No diagnostics are written as an empty array.
+	buf.Reset()
+	require.NoError(t, program.WriteDiagnosticsJSON(&buf, nil))
+	assert.Equal(t, "{\n  \"diagnostics\": []\n}\n", buf.String())
+}
+
+func TestBindExpressionWithScope(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config prefix string {
+	default = "x"
+}
+
+name = "${prefix}-name"
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	scope := model.NewRootScope(syntax.None)
+	scope.Define("self", &model.Variable{Name: "self", VariableType: model.StringType})
+	scope.Define("name", &model.Variable{Name: "name", VariableType: model.NumberType})
+
+	parse := func(text string) hclsyntax.Expression {
+		expr, diags := hclsyntax.ParseExpression([]byte(text), "expr.pp", hcl.InitialPos)
+		require.False(t, diags.HasErrors(), "failed to parse expression: %v", diags)
+		return expr
+	}
+
+	// Names from the scope are visible alongside top-level names.
+	expr, diags := program.BindExpressionWithScope(parse(`"${prefix}-${self}"`), scope)
+	assert.False(t, diags.HasErrors(), "failed to bind expression: %v", diags)
+	assert.Equal(t, model.StringType, expr.Type())
+
+	// Names from the scope shadow top-level names.
+	expr, diags = program.BindExpressionWithScope(parse(`name`), scope)
+	assert.False(t, diags.HasErrors(), "failed to bind expression: %v", diags)
+	assert.Equal(t, model.NumberType, expr.Type())
+
+	// The scope is not visible to BindExpression.
+	_, diags = program.BindExpression(parse(`self`))
+	assert.True(t, diags.HasErrors())
+}
+
+func TestPackageSnapshotsCached(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+resource pet "random:index/randomPet:RandomPet" {}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	first, err := program.PackageSnapshots()
+	require.NoError(t, err)
+	require.Len(t, first, 1)
+	pkg := first[0]
+	assert.Equal(t, "random", pkg.Name)
+
+	// Modifying the result must not affect later calls.
+	first[0] = nil
+
+	second, err := program.PackageSnapshots()
+	require.NoError(t, err)
+	require.Len(t, second, 1)
+	assert.Same(t, pkg, second[0])
+}
+
+// TestPackageReferencesConcurrent reads a program's packages from several goroutines. Run it with -race to detect
+// unsynchronized access.
+func TestPackageReferencesConcurrent(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+resource pet "random:index/randomPet:RandomPet" {}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	refs := make([][]schema.PackageReference, workers)
+	packages := make([][]*schema.Package, workers)
+	snapshots := make([][]*schema.Package, workers)
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			refs[i] = program.PackageReferences()
+			packages[i] = program.Packages()
+			snapshots[i], errs[i] = program.PackageSnapshots()
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < workers; i++ {
+		require.NoError(t, errs[i])
+		require.Len(t, refs[i], 1)
+		assert.Equal(t, "random", refs[i][0].Name())
+		assert.Equal(t, refs[0], refs[i])
+		assert.Equal(t, packages[0], packages[i])
+		require.Len(t, snapshots[i], 1)
+		assert.Same(t, snapshots[0][0], snapshots[i][0])
+	}
+}
+
+// stubPackageLoader serves the packages whose schemas are in testdata without loading any plugins, and records the
+// packages that it is asked for.
+type stubPackageLoader struct {
+	partial bool
+
+	m     sync.Mutex
+	loads []string
+}
+
+func (l *stubPackageLoader) LoadPackage(pkg string, version *semver.Version) (*schema.Package, error) {
+	ref, err := l.LoadPackageReference(pkg, version)
+	if err != nil {
+		return nil, err
+	}
+	return ref.Definition()
+}
+
+func (l *stubPackageLoader) LoadPackageReference(pkg string, version *semver.Version) (schema.PackageReference, error) {
+	l.m.Lock()
+	l.loads = append(l.loads, pkg)
+	l.m.Unlock()
+
+	if pkg != "random" {
+		return nil, errors.New("package " + pkg + " is not available")
+	}
+	b, err := ioutil.ReadFile(filepath.Join(testdataPath, pkg+".json"))
+	if err != nil {
+		return nil, err
+	}
+	if l.partial {
+		var spec schema.PartialPackageSpec
+		if err := json.Unmarshal(b, &spec); err != nil {
+			return nil, err
+		}
+		return schema.ImportPartialSpec(spec, nil)
+	}
+	var spec schema.PackageSpec
+	if err := json.Unmarshal(b, &spec); err != nil {
+		return nil, err
+	}
+	pkgDef, err := schema.ImportSpec(spec, nil)
+	if err != nil {
+		return nil, err
+	}
+	return pkgDef.Reference(), nil
+}
+
+func TestWithPackageLoader(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+resource pet "random:index/randomPet:RandomPet" {}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+	bound := program.Packages()
+	require.Len(t, bound, 1)
+
+	// Packages are resolved through the stub loader, and only when they are asked for.
+	loader := &stubPackageLoader{}
+	stubbed := program.WithPackageLoader(loader)
+	assert.Empty(t, loader.loads)
+	assert.Equal(t, program.Nodes, stubbed.Nodes)
+
+	packages := stubbed.Packages()
+	require.Len(t, packages, 1)
+	assert.Equal(t, "random", packages[0].Name)
+	assert.NotSame(t, bound[0], packages[0])
+	assert.Equal(t, []string{"random"}, loader.loads)
+
+	snapshots, err := stubbed.PackageSnapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	_, ok := snapshots[0].GetResource("random:index/randomPet:RandomPet")
+	assert.True(t, ok)
+
+	// The original program still uses the packages that it was bound with.
+	assert.Same(t, bound[0], program.Packages()[0])
+
+	// A partial package served by the loader snapshots the members that the program references.
+	stubbed = program.WithPackageLoader(&stubPackageLoader{partial: true})
+	snapshots, err = stubbed.PackageSnapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	_, ok = snapshots[0].GetResource("random:index/randomPet:RandomPet")
+	assert.True(t, ok)
+	_, ok = snapshots[0].GetResource("random:index/randomPassword:RandomPassword")
+	assert.False(t, ok)
+
+	// A package that the loader doesn't serve is not loaded from anywhere else.
+	program, diags = bindProgramText(t, `
+resource bucket "aws:s3/bucket:Bucket" {}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+	_, diags = program.WithPackageLoader(&stubPackageLoader{}).PackagesWithDiagnostics()
+	require.Len(t, diags, 1)
+	assert.Contains(t, diags[0].Error(), "package aws is not available")
+	_, err = program.WithPackageLoader(&stubPackageLoader{}).PackageSnapshots()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "package aws is not available")
+}
+
+// brokenPackageReference is a package reference whose definition cannot be loaded.
+type brokenPackageReference struct {
+	schema.PackageReference
+
+	name string
+}
+
+func (b brokenPackageReference) Name() string {
+	return b.name
+}
+
+func (b brokenPackageReference) Definition() (*schema.Package, error) {
+	return nil, errors.New("schema is corrupt")
+}
+
+func TestPackagesWithDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+resource pet "random:index/randomPet:RandomPet" {}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	pet, ok := program.NodeByName("pet")
+	require.True(t, ok)
+	program.packages["broken"] = brokenPackageReference{name: "broken"}
+	program.packageReferrers["broken"] = packageReferrer{node: pet}
+
+	packages, diags := program.PackagesWithDiagnostics()
+	require.Len(t, packages, 1)
+	assert.Equal(t, "random", packages[0].Name)
+
+	require.Len(t, diags, 1)
+	assert.Equal(t, hcl.DiagError, diags[0].Severity)
+	assert.Equal(t, "error loading package 'broken' referenced by 'pet': schema is corrupt", diags[0].Summary)
+	assert.Equal(t, pet.SyntaxNode().Range(), *diags[0].Subject)
+
+	assert.Panics(t, func() { program.Packages() })
+}
+
+func TestPackagesWithDiagnosticsRange(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Resource", func(t *testing.T) {
+		t.Parallel()
+
+		program, diags := bindProgramText(t, `
+resource pet "random:index/randomPet:RandomPet" {}
+`)
+		require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+		program.packages["random"] = brokenPackageReference{name: "random"}
+
+		_, diags = program.PackagesWithDiagnostics()
+		require.Len(t, diags, 1)
+		subject := diags[0].Subject
+		require.NotNil(t, subject)
+		assert.Equal(t, "main.pp", subject.Filename)
+		// The parser drops the source's leading newline, so the resource is on line 1.
+		assert.Equal(t, hcl.Pos{Line: 1, Column: 14, Byte: 14}, subject.Start)
+		assert.Equal(t, hcl.Pos{Line: 1, Column: 48, Byte: 48}, subject.End)
+	})
+
+	t.Run("Invoke", func(t *testing.T) {
+		t.Parallel()
+
+		program, diags := bindProgramText(t, `
+zones = invoke("aws:index/getAvailabilityZones:getAvailabilityZones", {})
+`)
+		require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+		program.packages["aws"] = brokenPackageReference{name: "aws"}
+
+		_, diags = program.PackagesWithDiagnostics()
+		require.Len(t, diags, 1)
+		assert.Equal(t, "error loading package 'aws' referenced by 'zones': schema is corrupt", diags[0].Summary)
+		subject := diags[0].Subject
+		require.NotNil(t, subject)
+		assert.Equal(t, "main.pp", subject.Filename)
+		assert.Equal(t, 1, subject.Start.Line)
+		assert.Equal(t, 16, subject.Start.Column)
+		assert.Equal(t, 1, subject.End.Line)
+		assert.Equal(t, 69, subject.End.Column)
+	})
+}
+
+// versionlessPackageReference is a package reference that does not know its version.
+type versionlessPackageReference struct {
+	schema.PackageReference
+}
+
+func (versionlessPackageReference) Version() *semver.Version {
+	return nil
+}
+
+func TestPackageVersions(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+resource pet "random:index/randomPet:RandomPet" {}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	program.packages["unversioned"] = versionlessPackageReference{}
+
+	assert.Equal(t, map[string]string{
+		"random":      "4.2.0",
+		"unversioned": "",
+	}, program.PackageVersions())
+}
+
+func TestReferences(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config prefix string {
+	default = "x"
+}
+
+b = join("-", [a, "${prefix}${a}"])
+a = "${prefix}-a"
+
+output result {
+	value = "${a}"
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	positions := func(name string) []hcl.Pos {
+		n, ok := program.NodeByName(name)
+		require.True(t, ok)
+
+		var positions []hcl.Pos
+		for _, rng := range program.References(n) {
+			assert.Equal(t, "main.pp", rng.Filename)
+			positions = append(positions, hcl.Pos{Line: rng.Start.Line, Column: rng.Start.Column})
+		}
+		return positions
+	}
+
+	// The parser drops the source's leading newline, so b is declared on line 5.
+	assert.Equal(t, []hcl.Pos{{Line: 5, Column: 16}, {Line: 5, Column: 31}, {Line: 9, Column: 13}}, positions("a"))
+	assert.Equal(t, []hcl.Pos{{Line: 5, Column: 22}, {Line: 6, Column: 8}}, positions("prefix"))
+	assert.Empty(t, positions("result"))
+}
+
+func TestRenameNode(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+a = 1
+b = a + 1
+
+output c {
+	value = a
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	a, ok := program.NodeByName("a")
+	require.True(t, ok)
+	c, ok := program.NodeByName("c")
+	require.True(t, ok)
+
+	// Renaming a node to the name of another node is rejected.
+	diags = program.RenameNode(a, "b")
+	require.True(t, diags.HasErrors())
+	assert.Equal(t, "a", a.Name())
+
+	diags = program.RenameNode(a, "x")
+	require.False(t, diags.HasErrors(), "failed to rename node: %v", diags)
+	assert.Equal(t, "x", a.Name())
+
+	_, ok = program.NodeByName("a")
+	assert.False(t, ok)
+	x, ok := program.NodeByName("x")
+	require.True(t, ok)
+	assert.Equal(t, a, x)
+
+	var roots []string
+	program.EachExpression(func(e model.Expression) bool {
+		if traversal, ok := e.(*model.ScopeTraversalExpression); ok {
+			roots = append(roots, traversal.RootName)
+		}
+		return false
+	})
+	assert.Equal(t, []string{"x", "x"}, roots)
+	assert.Len(t, program.References(a), 2)
+
+	b, ok := program.NodeByName("b")
+	require.True(t, ok)
+	assert.Equal(t, "x = 1", strings.TrimSpace(fmt.Sprintf("%v", a.(*LocalVariable).Definition)))
+	assert.Equal(t, "b = x + 1", strings.TrimSpace(fmt.Sprintf("%v", b.(*LocalVariable).Definition)))
+
+	// Renaming an output preserves its logical name.
+	diags = program.RenameNode(c, "d")
+	require.False(t, diags.HasErrors(), "failed to rename node: %v", diags)
+	assert.Equal(t, "d", c.Name())
+	assert.Equal(t, "c", c.(*OutputVariable).LogicalName())
+}
+
+func TestRenameNodeRebind(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config "prefix" "string" {
+	default = "x"
+}
+
+a = "${prefix}-a"
+
+output c {
+	value = a
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	a, ok := program.NodeByName("a")
+	require.True(t, ok)
+	prefix, ok := program.NodeByName("prefix")
+	require.True(t, ok)
+	require.False(t, program.RenameNode(a, "renamed").HasErrors())
+	require.False(t, program.RenameNode(prefix, "namePrefix").HasErrors())
+
+	parse := func(text string) hclsyntax.Expression {
+		expr, diags := hclsyntax.ParseExpression([]byte(text), "expr.pp", hcl.InitialPos)
+		require.False(t, diags.HasErrors(), "failed to parse expression: %v", diags)
+		return expr
+	}
+
+	// The new name resolves to the renamed node, and the old name no longer resolves.
+	expr, diags := program.BindExpression(parse(`renamed`))
+	require.False(t, diags.HasErrors(), "failed to bind expression: %v", diags)
+	assert.Equal(t, a, expr.(*model.ScopeTraversalExpression).Parts[0])
+	_, diags = program.BindExpression(parse(`a`))
+	assert.True(t, diags.HasErrors())
+
+	// The source reflects the rename, so the program can be written out and bound again.
+	var buf bytes.Buffer
+	require.NoError(t, program.WriteSource(&buf))
+	assert.Equal(t, `// main.pp
+
+config "namePrefix" "string" {
+	default = "x"
+}
+
+renamed = "${namePrefix}-a"
+
+output c {
+	value = renamed
+}
+`, buf.String())
+
+	clone, diags := program.Clone()
+	require.False(t, diags.HasErrors(), "failed to clone program: %v", diags)
+	renamed, ok := clone.NodeByName("renamed")
+	require.True(t, ok)
+	assert.Len(t, clone.References(renamed), 1)
+	_, ok = clone.NodeByName("a")
+	assert.False(t, ok)
+}
+
+func TestRenameNodeCollisions(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+a = 1
+b = a + 1
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	a, ok := program.NodeByName("a")
+	require.True(t, ok)
+
+	for _, name := range []string{"b", "null", "not-an-identifier", ""} {
+		diags := program.RenameNode(a, name)
+		assert.True(t, diags.HasErrors(), "renaming to %q", name)
+	}
+	assert.Equal(t, "a", a.Name())
+
+	// A node that was renamed away frees its old name, and a node that was renamed to a name claims it.
+	b, ok := program.NodeByName("b")
+	require.True(t, ok)
+	require.False(t, program.RenameNode(a, "x").HasErrors())
+	assert.False(t, program.RenameNode(b, "a").HasErrors())
+	assert.True(t, program.RenameNode(b, "x").HasErrors())
+}
+
+func TestValidateOutputs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Valid", func(t *testing.T) {
+		t.Parallel()
+
+		program, diags := bindProgramText(t, `
+a = "a"
+
+output result {
+	value = "${a}-b"
+}
+`)
+		require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+		assert.Empty(t, program.ValidateOutputs())
+	})
+
+	t.Run("MissingNode", func(t *testing.T) {
+		t.Parallel()
+
+		program, _ := bindProgramText(t, `
+output result {
+	value = missing
+}
+`)
+		diags := program.ValidateOutputs()
+		require.Len(t, diags, 1)
+		assert.Equal(t, hcl.DiagError, diags[0].Severity)
+		assert.Equal(t, "output 'result' references undefined variable 'missing'", diags[0].Summary)
+		// The parser drops the source's leading newline, so the output's value is on line 2.
+		assert.Equal(t, 2, diags[0].Subject.Start.Line)
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		t.Parallel()
+
+		program, _ := bindProgramText(t, `
+output result {
+	value = "${missing}-b"
+}
+
+output password {
+	value = join("-", [secret("hunter2"), "b"])
+}
+`)
+		diags := program.ValidateOutputs()
+		require.Len(t, diags, 2)
+		assert.Equal(t, "output 'result' references undefined variable 'missing'", diags[0].Summary)
+		assert.Equal(t, hcl.DiagWarning, diags[1].Severity)
+		assert.Contains(t, diags[1].Summary, "output 'password' is secret")
+	})
+
+	t.Run("Secret", func(t *testing.T) {
+		t.Parallel()
+
+		program, diags := bindProgramText(t, `
+output password {
+	value = secret("hunter2")
+}
+`)
+		require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+		diags = program.ValidateOutputs()
+		require.Len(t, diags, 1)
+		assert.Equal(t, hcl.DiagWarning, diags[0].Severity)
+		assert.Contains(t, diags[0].Summary, "output 'password' is secret")
+	})
+}
+
+func TestSortedDiagnosticWriter(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+a = "a"
+b = "b"
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	at := func(filename string, line, column int) hcl.Range {
+		pos := hcl.Pos{Line: line, Column: column}
+		return hcl.Range{Filename: filename, Start: pos, End: pos}
+	}
+
+	var buf bytes.Buffer
+	w := program.NewSortedDiagnosticWriter(&buf, 0, false)
+	require.NoError(t, w.WriteDiagnostics(hcl.Diagnostics{
+		errorf(at("main.pp", 3, 5), "third"),
+		diagf(hcl.DiagWarning, at("main.pp", 2, 5), "second"),
+		{Severity: hcl.DiagError, Summary: "last"},
+	}))
+	require.NoError(t, w.WriteDiagnostic(errorf(at("main.pp", 2, 1), "first")))
+	assert.Empty(t, buf.String())
+
+	require.NoError(t, w.Flush())
+	output := buf.String()
+
+	var indices []int
+	for _, summary := range []string{"first", "second", "third", "last"} {
+		i := strings.Index(output, summary)
+		require.NotEqual(t, -1, i, "missing %q in output:\n%s", summary, output)
+		indices = append(indices, i)
+	}
+	assert.IsIncreasing(t, indices)
+
+	// Flushing again writes nothing.
+	buf.Reset()
+	require.NoError(t, w.Flush())
+	assert.Empty(t, buf.String())
+}
+
+func TestSourceFiles(t *testing.T) {
+	t.Parallel()
+
+	parser := syntax.NewParser()
+	require.NoError(t, parser.ParseFile(strings.NewReader(`a = "a"`), "a.pp"))
+	require.NoError(t, parser.ParseFile(strings.NewReader(`b = a`), "b.pp"))
+	require.False(t, parser.Diagnostics.HasErrors(), "failed to parse program: %v", parser.Diagnostics)
+
+	program, diags, err := BindProgram(parser.Files, PluginHost(utils.NewHost(testdataPath)))
+	require.NoError(t, err)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	files := program.SourceFiles()
+	require.Len(t, files, 2)
+	assert.Same(t, parser.Files[0], files[0])
+	assert.Same(t, parser.Files[1], files[1])
+
+	// Modifying the result must not affect the program.
+	files[0] = nil
+	assert.Same(t, parser.Files[0], program.SourceFiles()[0])
+}
+
+func TestWriteSource(t *testing.T) {
+	t.Parallel()
+
+	parser := syntax.NewParser()
+	require.NoError(t, parser.ParseFile(strings.NewReader("b = a\n"), "b.pp"))
+	require.NoError(t, parser.ParseFile(strings.NewReader(`a = "a"`), "a.pp"))
+	require.False(t, parser.Diagnostics.HasErrors(), "failed to parse program: %v", parser.Diagnostics)
+
+	program, diags, err := BindProgram(parser.Files, PluginHost(utils.NewHost(testdataPath)))
+	require.NoError(t, err)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	var buf bytes.Buffer
+	require.NoError(t, program.WriteSource(&buf))
+	source := buf.String()
+	assert.Equal(t, "// a.pp\na = \"a\"\n\n// b.pp\nb = a\n", source)
+
+	// The output parses and binds to a program with the same nodes, and writing that program reproduces it.
+	reparsed, diags := bindProgramText(t, source)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+	assert.Equal(t, nodeNames(program.Nodes), nodeNames(reparsed.Nodes))
+
+	var again bytes.Buffer
+	require.NoError(t, reparsed.WriteSource(&again))
+	assert.Equal(t, "// main.pp\n"+source, again.String())
+}
+
+func TestDuplicateNodeNames(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+pet = "local"
+
+resource pet "random:index/randomPet:RandomPet" {}
+`)
+	require.True(t, diags.HasErrors())
+	require.Len(t, diags, 1)
+
+	diag := diags[0]
+	assert.Equal(t, hcl.DiagError, diag.Severity)
+	assert.Equal(t, `"pet" already declared`, diag.Summary)
+	require.NotNil(t, diag.Context)
+	require.NotNil(t, diag.Subject)
+	// The parser drops the source's leading newline, so the declarations are on lines 1 and 3.
+	assert.Equal(t, 1, diag.Context.Start.Line)
+	assert.Equal(t, 3, diag.Subject.Start.Line)
+	assert.Contains(t, diag.Detail, diag.Context.String())
+	assert.Contains(t, diag.Detail, diag.Subject.String())
+
+	// Only the first declaration is part of the program.
+	pet, ok := program.NodeByName("pet")
+	require.True(t, ok)
+	assert.IsType(t, &LocalVariable{}, pet)
+	assert.Len(t, program.Nodes, 1)
+}
+
+func TestVisit(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+b = "${a}-b"
+a = "a"
+c = "c"
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	var events []string
+	diags = program.Visit(func(n Node) hcl.Diagnostics {
+		events = append(events, "pre "+n.Name())
+		return hcl.Diagnostics{{Severity: hcl.DiagWarning, Summary: n.Name()}}
+	}, func(n Node) hcl.Diagnostics {
+		events = append(events, "post "+n.Name())
+		return nil
+	})
+	assert.Equal(t, []string{"pre a", "post a", "pre b", "post b", "pre c", "post c"}, events)
+	require.Len(t, diags, 3)
+	assert.Equal(t, "a", diags[0].Summary)
+
+	// Returning StopVisiting from pre ends the walk without calling post for that node.
+	events = nil
+	diags = program.Visit(func(n Node) hcl.Diagnostics {
+		events = append(events, "pre "+n.Name())
+		if n.Name() == "b" {
+			return hcl.Diagnostics{{Severity: hcl.DiagError, Summary: "stopped"}, StopVisiting}
+		}
+		return nil
+	}, func(n Node) hcl.Diagnostics {
+		events = append(events, "post "+n.Name())
+		return nil
+	})
+	assert.Equal(t, []string{"pre a", "post a", "pre b"}, events)
+	require.Len(t, diags, 1)
+	assert.Equal(t, "stopped", diags[0].Summary)
+}
+
+func TestVisitUnbound(t *testing.T) {
+	t.Parallel()
+
+	// Unbound programs are visited in declaration order.
+	a, b := newTestLocal("a", 1), newTestLocal("b", 2)
+	a.setDependencies([]Node{b})
+	program := &Program{Nodes: []Node{a, b}}
+
+	var names []string
+	diags := program.Visit(nil, func(n Node) hcl.Diagnostics {
+		names = append(names, n.Name())
+		return nil
+	})
+	assert.Empty(t, diags)
+	assert.Equal(t, []string{"a", "b"}, names)
+}
+
+func TestReferencedTokens(t *testing.T) {
+	t.Parallel()
+
+	// Root references Res1 through a property, which references Obj1, which references Res2, which references Obj2.
+	program, diags := bindProgramText(t, `
+resource rt "synthetic:resourceProperties:Root" {}
+resource pet "random:index/randomPet:RandomPet" {}
+zones = invoke("aws:index:getAvailabilityZones", {})
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	tokens, diags := program.ReferencedTokens()
+	assert.Empty(t, diags)
+
+	// The function's filters argument references an object type.
+	assert.Equal(t, []string{
+		"aws:index/getAvailabilityZones:getAvailabilityZones",
+		"aws:index/getAvailabilityZonesFilter:getAvailabilityZonesFilter",
+		"random:index/randomPet:RandomPet",
+		"synthetic:resourceProperties:Obj1",
+		"synthetic:resourceProperties:Obj2",
+		"synthetic:resourceProperties:Res1",
+		"synthetic:resourceProperties:Res2",
+		"synthetic:resourceProperties:Root",
+	}, tokens)
+}
+
+func TestReferencedTokensNested(t *testing.T) {
+	t.Parallel()
+
+	// Invokes nested inside other expressions are found.
+	program, diags := bindProgramText(t, `
+zones = [invoke("aws:index:getAvailabilityZones", {})]
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	tokens, diags := program.ReferencedTokens()
+	assert.Empty(t, diags)
+	assert.Equal(t, []string{
+		"aws:index/getAvailabilityZones:getAvailabilityZones",
+		"aws:index/getAvailabilityZonesFilter:getAvailabilityZonesFilter",
+	}, tokens)
+}
+
+const petSetComponent = `
+config name string {}
+
+config length int {
+	default = 2
+}
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = name
+	length = length
+}
+
+output petName {
+	value = pet.id
+}
+`
+
+func TestComponents(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramWithComponents(t, `
+config prefix string {
+	default = "app"
+}
+
+component pets "./petSet" {
+	name = "${prefix}-pets"
+}
+
+output result {
+	value = pets.petName
+}
+`, map[string]string{"petSet/main.pp": petSetComponent})
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	// The nodes inside the component are not top-level nodes.
+	assert.Equal(t, []string{"prefix", "pets", "result"}, nodeNames(program.Nodes))
+
+	components := program.Components()
+	require.Len(t, components, 1)
+	component := components[0]
+	assert.Equal(t, "petSet", component.Name())
+	assert.True(t, filepath.IsAbs(component.Dir))
+	require.NotNil(t, component.Program)
+	assert.Equal(t, component.Program.Nodes, component.Nodes)
+	assert.Equal(t, []string{"name", "length", "pet", "petName"}, nodeNames(component.Nodes))
+	assert.Equal(t, model.StringType, component.InputTypes["name"])
+	assert.Equal(t, model.IntType, component.InputTypes["length"])
+	assert.Contains(t, component.OutputTypes, "petName")
+	require.Len(t, component.Children, 1)
+
+	// The resource inside the component depends only on the component's own nodes.
+	assert.Equal(t, []string{"name", "length"}, nodeNames(component.Children[0].getDependencies()))
+
+	// Dependencies cross the component boundary through the instance: the instance depends on the program nodes that
+	// its inputs refer to, and program nodes that refer to its outputs depend on the instance.
+	instance, ok := program.Nodes[1].(*ComponentInstance)
+	require.True(t, ok)
+	assert.Same(t, component, instance.Component)
+	assert.Equal(t, "./petSet", instance.Source())
+	assert.Equal(t, []string{"prefix"}, nodeNames(instance.getDependencies()))
+	assert.Equal(t, []string{"pets"}, nodeNames(program.Nodes[2].getDependencies()))
+	outputs, ok := instance.Type().(*model.ObjectType)
+	require.True(t, ok, "unexpected instance type %v", instance.Type())
+	assert.Contains(t, outputs.Properties, "petName")
+
+	prefix, _ := program.NodeByName("prefix")
+	assert.Len(t, program.References(prefix), 1)
+}
+
+func TestComponentInstances(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramWithComponents(t, `
+component cats "./petSet" {
+	name = "cats"
+}
+
+component dogs "petSet" {
+	name = "dogs"
+	length = 3
+}
+`, map[string]string{"petSet/main.pp": petSetComponent})
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	// Both instances share a single definition.
+	require.Len(t, program.Components(), 1)
+
+	instances := program.ComponentInstances()
+	require.Len(t, instances, 2)
+
+	cats, dogs := instances[0], instances[1]
+	assert.Equal(t, "cats", cats.Name())
+	assert.Equal(t, "dogs", dogs.Name())
+	assert.Equal(t, "./petSet", cats.Source())
+	assert.Equal(t, "petSet", dogs.Source())
+	for _, instance := range instances {
+		assert.Same(t, program.Components()[0], instance.Component)
+	}
+
+	literal := func(t *testing.T, expr model.Expression) cty.Value {
+		lit, ok := expr.(*model.LiteralValueExpression)
+		if !ok {
+			template, isTemplate := expr.(*model.TemplateExpression)
+			require.True(t, isTemplate, "unexpected expression %T", expr)
+			require.Len(t, template.Parts, 1)
+			lit, ok = template.Parts[0].(*model.LiteralValueExpression)
+			require.True(t, ok, "unexpected template part %T", template.Parts[0])
+		}
+		return lit.Value
+	}
+
+	catInputs := cats.InputValues()
+	require.Len(t, catInputs, 1)
+	assert.Equal(t, cty.StringVal("cats"), literal(t, catInputs["name"]))
+
+	dogInputs := dogs.InputValues()
+	require.Len(t, dogInputs, 2)
+	assert.Equal(t, cty.StringVal("dogs"), literal(t, dogInputs["name"]))
+	assert.True(t, cty.NumberIntVal(3).Equals(literal(t, dogInputs["length"])).True())
+}
+
+func TestNestedComponents(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramWithComponents(t, `
+component farm "./farm" {
+	name = "farm"
+}
+
+component pets "./pets" {
+	name = "pets"
+}
+`, map[string]string{
+		"farm/main.pp": `
+config name string {}
+
+component pets "../pets" {
+	name = name
+}
+
+output petName {
+	value = pets.petName
+}
+`,
+		"pets/main.pp": petSetComponent,
+	})
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	components := program.Components()
+	require.Len(t, components, 2)
+	farm, pets := components[0], components[1]
+	assert.Equal(t, "farm", farm.Name())
+	assert.Equal(t, "pets", pets.Name())
+
+	// The nested instance resolves its source relative to its own component and shares the definition loaded for the
+	// top-level instance.
+	require.Len(t, farm.Program.Components(), 1)
+	assert.Same(t, pets, farm.Program.Components()[0])
+
+	// Instances inside components follow the top-level instances.
+	instances := program.ComponentInstances()
+	require.Len(t, instances, 3)
+	assert.Same(t, farm, instances[0].Component)
+	assert.Same(t, pets, instances[1].Component)
+	assert.Same(t, pets, instances[2].Component)
+	assert.Equal(t, "../pets", instances[2].Source())
+}
+
+func TestComponentInstanceErrors(t *testing.T) {
+	t.Parallel()
+
+	_, diags := bindProgramWithComponents(t, `
+component unnamed "./needsName" {}
+component extra "./needsName" {
+	name = "x"
+	size = 3
+}
+component oneLabel {}
+component empty "./empty" {}
+`, map[string]string{
+		"needsName/main.pp": "config name string {}\n",
+		"empty/README.md":   "no PCL here\n",
+	})
+	var summaries []string
+	for _, d := range diags {
+		summaries = append(summaries, d.Summary)
+	}
+	assert.ElementsMatch(t, []string{
+		"missing required attribute 'name'",
+		"unsupported attribute 'size'",
+		"components must have exactly two labels",
+		"component './empty' does not contain any .pp files",
+	}, summaries)
+
+	_, diags = bindProgramWithComponents(t, `
+component missing "./unknown" {}
+`, nil)
+	require.Len(t, diags, 1)
+	assert.True(t, strings.HasPrefix(diags[0].Summary, "failed to load component './unknown': "), diags[0].Summary)
+}
+
+func TestDuplicateComponentNames(t *testing.T) {
+	t.Parallel()
+
+	_, diags := bindProgramWithComponents(t, `
+pets = "pets"
+
+component pets "./petSet" {
+	name = "pets"
+}
+
+component cats "./petSet" {
+	name = "cats"
+}
+
+component cats "./petSet" {
+	name = "more cats"
+}
+`, map[string]string{"petSet/main.pp": petSetComponent})
+	var summaries []string
+	for _, d := range diags {
+		summaries = append(summaries, d.Summary)
+	}
+	assert.Equal(t, []string{`"pets" already declared`, `"cats" already declared`}, summaries)
+
+	// Duplicate names inside a component are reported when the component is loaded.
+	_, diags = bindProgramWithComponents(t, `
+component pets "./petSet" {}
+`, map[string]string{"petSet/main.pp": "config pet string {\n\tdefault = \"a\"\n}\n\npet = \"b\"\n"})
+	require.Len(t, diags, 1)
+	assert.Equal(t, `"pet" already declared`, diags[0].Summary)
+}
+
+func TestRecursiveComponents(t *testing.T) {
+	t.Parallel()
+
+	// A component cannot instantiate itself, either directly or through a nested component.
+	_, diags := bindProgramWithComponents(t, `
+component self "./self" {}
+component outer "./outer" {}
+`, map[string]string{
+		"self/main.pp":  `component again "." {}`,
+		"outer/main.pp": `component inner "../inner" {}`,
+		"inner/main.pp": `component outer "../outer" {}`,
+	})
+	var summaries []string
+	for _, d := range diags {
+		summaries = append(summaries, d.Summary)
+	}
+	assert.Equal(t, []string{
+		"component '.' cannot instantiate itself",
+		"component '../outer' cannot instantiate itself",
+	}, summaries)
+}
+
+func TestDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+a = "a"
+b = missing
+`)
+	require.Len(t, diags, 1)
+	assert.Equal(t, hcl.DiagError, diags[0].Severity)
+	assert.Equal(t, diags, program.Diagnostics())
+
+	// Appending to the result does not affect the program.
+	result := program.Diagnostics()
+	_ = append(result[:0], &hcl.Diagnostic{Summary: "other"})
+	assert.Equal(t, diags, program.Diagnostics())
+}
+
+func TestNodeKind(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramWithComponents(t, `
+config prefix string {
+	default = "app"
+}
+
+petPrefix = "${prefix}-pet"
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = petPrefix
+}
+
+component pets "./petSet" {
+	name = "pets"
+}
+
+output result {
+	value = pet.id
+}
+`, map[string]string{"petSet/main.pp": petSetComponent})
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	expected := map[string]NodeKind{
+		"prefix":    NodeKindConfig,
+		"petPrefix": NodeKindLocal,
+		"pet":       NodeKindResource,
+		"pets":      NodeKindComponent,
+		"result":    NodeKindOutput,
+	}
+	require.Len(t, program.Nodes, len(expected))
+	for _, n := range program.Nodes {
+		assert.Equal(t, expected[n.Name()], n.Kind(), "kind of %v", n.Name())
+	}
+}
+
+func TestNodeCounts(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramWithComponents(t, `
+config prefix string {
+	default = "app"
+}
+
+config length int {
+	default = 2
+}
+
+petPrefix = "${prefix}-pet"
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = petPrefix
+	length = length
+}
+
+resource otherPet "random:index/randomPet:RandomPet" {
+	prefix = petPrefix
+}
+
+component pets "./petSet" {
+	name = "pets"
+}
+
+output result {
+	value = pet.id
+}
+`, map[string]string{"petSet/main.pp": petSetComponent})
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	// The nodes inside the component are not counted.
+	assert.Equal(t, map[NodeKind]int{
+		NodeKindConfig:    2,
+		NodeKindLocal:     1,
+		NodeKindResource:  2,
+		NodeKindComponent: 1,
+		NodeKindOutput:    1,
+	}, program.NodeCounts())
+	assert.Equal(t, 2, program.ResourceCount())
+
+	empty, diags := bindProgramText(t, "")
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+	assert.Empty(t, empty.NodeCounts())
+	assert.Zero(t, empty.ResourceCount())
+}
+
+func TestUnusedNodes(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramWithComponents(t, `
+config prefix string {
+	default = "app"
+}
+
+config unusedConfig string {
+	default = "unused"
+}
+
+config componentPrefix string {
+	default = "component"
+}
+
+name = "${prefix}-pet"
+length = 2
+unusedLocal = "${name}-unused"
+alsoUnused = 42
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = name
+}
+
+resource unusedPet "random:index/randomPet:RandomPet" {}
+
+component pets "./petSet" {
+	name = "${componentPrefix}-pets"
+}
+
+output result {
+	value = length
+}
+`, map[string]string{"petSet/main.pp": petSetComponent})
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	// Locals used only by unused locals still count as used; resources, outputs, and component instances are never
+	// reported.
+	assert.Equal(t, []string{"unusedConfig", "unusedLocal", "alsoUnused"}, nodeNames(program.UnusedNodes()))
+}
+
+func TestConfigNodes(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config apiKey string {}
+
+name = "app"
+
+config region string {
+	default = "us-west-2"
+}
+
+config replicas int {
+	default = 3
+}
+
+config tags "list(string)" {}
+
+output result {
+	value = name
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	config := program.ConfigNodes()
+	require.Len(t, config, 4)
+
+	var names []string
+	for _, c := range config {
+		names = append(names, c.Name())
+	}
+	assert.Equal(t, []string{"apiKey", "region", "replicas", "tags"}, names)
+
+	apiKey := config[0]
+	assert.Equal(t, model.StringType, apiKey.Type())
+	assert.True(t, apiKey.Required())
+	assert.Nil(t, apiKey.Default())
+
+	region := config[1]
+	assert.Equal(t, model.StringType, region.Type())
+	assert.False(t, region.Required())
+	require.NotNil(t, region.Default())
+	assert.Equal(t, model.StringType, region.Default().Type())
+
+	replicas := config[2]
+	assert.Equal(t, model.IntType, replicas.Type())
+	assert.False(t, replicas.Required())
+	literal, ok := replicas.Default().(*model.LiteralValueExpression)
+	require.True(t, ok, "expected a literal default, got %T", replicas.Default())
+	assert.Equal(t, "3", literal.Value.AsBigFloat().String())
+
+	tags := config[3]
+	assert.Equal(t, model.NewListType(model.StringType), tags.Type())
+	assert.True(t, tags.Required())
+	assert.Nil(t, tags.Default())
+}
+
+func TestNodeTypeString(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config name string {}
+
+config sizes "list(int)" {}
+
+config labels "map(string)" {}
+
+config settings "object({enabled=bool, count=number})" {}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	expected := map[string]string{
+		"name":     "string",
+		"sizes":    "list(int)",
+		"labels":   "map(string)",
+		"settings": "object({count = number, enabled = bool})",
+	}
+	for name, typ := range expected {
+		n, ok := program.NodeByName(name)
+		require.True(t, ok, "node %q not found", name)
+		assert.Equal(t, typ, program.NodeTypeString(n), "node %q", name)
+	}
+
+	// Optional types are unions with none.
+	optional := &ConfigVariable{typ: model.NewOptionalType(model.StringType)}
+	assert.Equal(t, "union(none, string)", program.NodeTypeString(optional))
+
+	union := &ConfigVariable{typ: model.NewUnionType(model.StringType, model.NewListType(model.IntType))}
+	assert.Equal(t, "union(list(int), string)", program.NodeTypeString(union))
+}
+
+func TestReferencedPackageNames(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+resource rt "synthetic:resourceProperties:Root" {}
+resource pet "random:index/randomPet:RandomPet" {}
+resource otherPet "random:index/randomPet:RandomPet" {}
+zones = invoke("aws:index:getAvailabilityZones", {})
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	// Each package is counted once, no matter how many times it is referenced.
+	assert.Equal(t, 3, program.ReferencedPackageCount())
+	assert.Equal(t, []string{"aws", "random", "synthetic"}, program.ReferencedPackageNames())
+
+	// Neither method touches the package references, so even references that can't be loaded are counted.
+	program.packages["broken"] = brokenPackageReference{name: "broken"}
+	assert.Equal(t, 4, program.ReferencedPackageCount())
+	assert.Equal(t, []string{"aws", "broken", "random", "synthetic"}, program.ReferencedPackageNames())
+}
+
+func TestOutputs(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramWithComponents(t, `
+config prefix string {
+	default = "app"
+}
+
+output greeting string {
+	value = "hello"
+}
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = prefix
+}
+
+output petId {
+	value = pet.id
+}
+
+component pets "./petSet" {
+	name = "pets"
+}
+
+output count {
+	value = 3
+}
+`, map[string]string{"petSet/main.pp": petSetComponent})
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	outputs := program.Outputs()
+	require.Len(t, outputs, 3)
+
+	var names []string
+	for _, o := range outputs {
+		names = append(names, o.Name())
+	}
+	assert.Equal(t, []string{"greeting", "petId", "count"}, names)
+
+	greeting := outputs[0]
+	assert.Equal(t, model.StringType, greeting.Type())
+	require.NotNil(t, greeting.Value)
+
+	// An output that refers to a resource property has an eventual value that refers to the resource.
+	petID := outputs[1]
+	assert.Equal(t, model.DynamicType, petID.Type())
+	assert.IsType(t, &model.OutputType{}, petID.Value.Type())
+	traversal, ok := petID.Value.(*model.ScopeTraversalExpression)
+	require.True(t, ok, "expected a traversal, got %T", petID.Value)
+	pet, ok := program.NodeByName("pet")
+	require.True(t, ok)
+	assert.Equal(t, pet, traversal.Parts[0])
+
+	count := outputs[2]
+	literal, ok := count.Value.(*model.LiteralValueExpression)
+	require.True(t, ok, "expected a literal, got %T", count.Value)
+	assert.Equal(t, "3", literal.Value.AsBigFloat().String())
+}
+
+func TestNodeValue(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config prefix string {
+	default = "app"
+}
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = prefix
+}
+
+label = "${prefix}-${length([1, 2, 3])}"
+
+output petId {
+	value = pet.id
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	node, ok := program.NodeByName("label")
+	require.True(t, ok)
+	local, ok := node.(*LocalVariable)
+	require.True(t, ok, "expected a local, got %T", node)
+	template, ok := local.Value().(*model.TemplateExpression)
+	require.True(t, ok, "expected a template, got %T", local.Value())
+	require.Len(t, template.Parts, 3)
+	assert.IsType(t, &model.FunctionCallExpression{}, template.Parts[2])
+	assert.Equal(t, local.Definition.Value, local.Value())
+
+	// An output's value refers to the resource whose output it exports.
+	node, ok = program.NodeByName("petId")
+	require.True(t, ok)
+	output, ok := node.(*OutputVariable)
+	require.True(t, ok, "expected an output, got %T", node)
+	traversal, ok := output.Value.(*model.ScopeTraversalExpression)
+	require.True(t, ok, "expected a traversal, got %T", output.Value)
+	pet, ok := program.NodeByName("pet")
+	require.True(t, ok)
+	assert.Equal(t, pet, traversal.Parts[0])
+}
+
+func TestDeprecationWarnings(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+resource old "legacy:index:OldBucket" {
+	name = "old"
+}
+
+resource bucket "legacy:index:Bucket" {
+	acl = "private"
+}
+
+oldLookup = invoke("legacy:index:getOldBucket", {})
+
+lookup = invoke("legacy:index:getBucket", {
+	region = "us-west-2"
+})
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+	require.NotNil(t, program)
+
+	// Each warning is reported at the reference to the deprecated member, given as its line and column. The parser
+	// drops the source's leading newline, so the first resource is on line 1. Members are named by the tokens that
+	// the program uses, not by their canonical tokens.
+	warnings := map[string][2]int{}
+	for _, diag := range diags {
+		require.Equal(t, hcl.DiagWarning, diag.Severity, diag.Summary)
+		require.NotNil(t, diag.Subject, diag.Summary)
+		warnings[diag.Summary] = [2]int{diag.Subject.Start.Line, diag.Subject.Start.Column}
+	}
+	assert.Equal(t, map[string][2]int{
+		"resource type 'legacy:index:OldBucket' is deprecated: OldBucket has been replaced by Bucket.":     {1, 14},
+		"property 'acl' is deprecated: Use the policy property instead.":                                   {6, 2},
+		"function 'legacy:index:getOldBucket' is deprecated: getOldBucket has been replaced by getBucket.": {9, 20},
+		"property 'region' is deprecated: Buckets are looked up in every region.":                          {12, 2},
+	}, warnings)
+}
+
+func TestWriteDependencyGraphDOT(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config prefix string {
+	default = "x"
+}
+
+a = "${prefix}-a"
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = a
+}
+
+output result string {
+	value = "${a}${pet.id}"
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	var buf bytes.Buffer
+	require.NoError(t, program.WriteDependencyGraphDOT(&buf))
+	assert.Equal(t, `digraph program {
+	"prefix" [label="prefix\nconfig"];
+	"a" [label="a\nlocal"];
+	"pet" [label="pet\nresource"];
+	"result" [label="result\noutput"];
+	"a" -> "prefix";
+	"pet" -> "a";
+	"result" -> "a";
+	"result" -> "pet";
+}
+`, buf.String())
+}
+
+func TestWriteDependencyGraphDOTCycle(t *testing.T) {
+	t.Parallel()
+
+	// The binder cannot bind circular references, so construct the program by hand.
+	a, b, c, d := newTestLocal("a", 1), newTestLocal("b", 2), newTestLocal("c", 3), newTestLocal("d", 4)
+	a.setDependencies([]Node{b})
+	b.setDependencies([]Node{a})
+	c.setDependencies([]Node{a})
+	d.setDependencies([]Node{d})
+	program := &Program{Nodes: []Node{a, b, c, d}}
+
+	var buf bytes.Buffer
+	require.NoError(t, program.WriteDependencyGraphDOT(&buf))
+	assert.Equal(t, `digraph program {
+	"a" [label="a\nlocal", color=red, fontcolor=red];
+	"b" [label="b\nlocal", color=red, fontcolor=red];
+	"c" [label="c\nlocal"];
+	"d" [label="d\nlocal", color=red, fontcolor=red];
+	"a" -> "b";
+	"b" -> "a";
+	"c" -> "a";
+	"d" -> "d";
+}
+`, buf.String())
+}
+
+func TestResourceOptions(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+resource provider "pulumi:providers:random" {}
+
+resource parentPet "random:index/randomPet:RandomPet" {}
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = "app"
+
+	options {
+		provider = provider
+		parent = parentPet
+		dependsOn = [provider, parentPet]
+		protect = true
+		ignoreChanges = [prefix]
+	}
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	// traversalRoot returns the node that the given expression refers to.
+	traversalRoot := func(x model.Expression) Node {
+		traversal, ok := x.(*model.ScopeTraversalExpression)
+		require.True(t, ok, "expected a traversal, got %T", x)
+		n, ok := traversal.Parts[0].(Node)
+		require.True(t, ok, "expected a node, got %T", traversal.Parts[0])
+		return n
+	}
+
+	n, ok := program.NodeByName("pet")
+	require.True(t, ok)
+	pet, ok := n.(*Resource)
+	require.True(t, ok)
+	options := pet.ResourceOptions()
+
+	assert.Equal(t, "provider", traversalRoot(options.Provider).Name())
+	assert.Equal(t, "parentPet", traversalRoot(options.Parent).Name())
+
+	dependsOn, ok := options.DependsOn.(*model.TupleConsExpression)
+	require.True(t, ok, "expected a tuple, got %T", options.DependsOn)
+	var dependencies []Node
+	for _, x := range dependsOn.Expressions {
+		dependencies = append(dependencies, traversalRoot(x))
+	}
+	assert.Equal(t, []string{"provider", "parentPet"}, nodeNames(dependencies))
+
+	protect, ok := options.Protect.(*model.LiteralValueExpression)
+	require.True(t, ok, "expected a literal, got %T", options.Protect)
+	assert.True(t, protect.Value.True())
+
+	ignoreChanges, ok := options.IgnoreChanges.(*model.TupleConsExpression)
+	require.True(t, ok, "expected a tuple, got %T", options.IgnoreChanges)
+	assert.Len(t, ignoreChanges.Expressions, 1)
+
+	assert.Nil(t, options.Range)
+
+	// A resource without an options block has empty options.
+	n, ok = program.NodeByName("parentPet")
+	require.True(t, ok)
+	parentOptions := n.(*Resource).ResourceOptions()
+	require.NotNil(t, parentOptions)
+	assert.Nil(t, parentOptions.Provider)
+	assert.Nil(t, parentOptions.DependsOn)
+}
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = "app"
+}
+
+name = pet.id
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	clone, diags := program.Clone()
+	require.False(t, diags.HasErrors(), "failed to clone program: %v", diags)
+	assert.Equal(t, nodeNames(program.Nodes), nodeNames(clone.Nodes))
+	assert.Equal(t, program.ReferencedPackageNames(), clone.ReferencedPackageNames())
+	for i := range program.Nodes {
+		assert.NotSame(t, program.Nodes[i], clone.Nodes[i])
+	}
+
+	// Modify the clone's nodes. The original's must be unchanged.
+	n, ok := clone.NodeByName("name")
+	require.True(t, ok)
+	n.(*LocalVariable).Definition.Value = &model.LiteralValueExpression{Value: cty.StringVal("fixed")}
+	n, ok = clone.NodeByName("pet")
+	require.True(t, ok)
+	n.(*Resource).Inputs = nil
+	clone.Nodes = clone.Nodes[:1]
+
+	require.Len(t, program.Nodes, 2)
+	n, ok = program.NodeByName("name")
+	require.True(t, ok)
+	assert.IsType(t, &model.ScopeTraversalExpression{}, n.(*LocalVariable).Definition.Value)
+	n, ok = program.NodeByName("pet")
+	require.True(t, ok)
+	assert.Len(t, n.(*Resource).Inputs, 1)
+}
+
+func TestExpressionAssignableTo(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config prefix string {}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	scope := model.NewRootScope(syntax.None)
+	scope.Define("count", &model.Variable{Name: "count", VariableType: model.IntType})
+
+	tests := []struct {
+		name       string
+		expr       string
+		typ        model.Type
+		assignable bool
+	}{
+		{name: "Exact", expr: `prefix`, typ: model.StringType, assignable: true},
+		{name: "IntToNumber", expr: `count`, typ: model.NumberType, assignable: true},
+		{name: "ListToString", expr: `[prefix]`, typ: model.StringType},
+		{name: "StringToList", expr: `prefix`, typ: model.NewListType(model.NumberType)},
+	}
+	for _, tt := range tests {
+		//nolint:paralleltest // the subtests share the program's binder
+		t.Run(tt.name, func(t *testing.T) {
+			x, diags := hclsyntax.ParseExpression([]byte(tt.expr), "expr.pp", hcl.InitialPos)
+			require.False(t, diags.HasErrors(), "failed to parse expression: %v", diags)
+			expr, diags := program.BindExpressionWithScope(x, scope)
+			require.False(t, diags.HasErrors(), "failed to bind expression: %v", diags)
+
+			assignable, diags := program.ExpressionAssignableTo(expr, tt.typ)
+			assert.Equal(t, tt.assignable, assignable)
+			if tt.assignable {
+				assert.Empty(t, diags)
+			} else if assert.Len(t, diags, 1) {
+				assert.Equal(t, hcl.DiagError, diags[0].Severity)
+				assert.Equal(t, "expr.pp", diags[0].Subject.Filename)
+			}
+		})
+	}
+}
+
+func TestFindNodesByType(t *testing.T) {
+	t.Parallel()
+
+	program, diags := bindProgramText(t, `
+config prefix string {}
+config count int {}
+config ratio number {}
+
+name = "${prefix}-app"
+
+resource pet "random:index/randomPet:RandomPet" {
+	prefix = name
+}
+`)
+	require.False(t, diags.HasErrors(), "failed to bind program: %v", diags)
+
+	pet, ok := program.NodeByName("pet")
+	require.True(t, ok)
+	stringOrNumber := model.NewUnionType(model.StringType, model.NumberType)
+
+	tests := []struct {
+		name       string
+		typ        model.Type
+		assignable bool
+		expected   []string
+	}{
+		{name: "String", typ: model.StringType, expected: []string{"prefix", "name"}},
+		{name: "Number", typ: model.NumberType, expected: []string{"ratio"}},
+		{name: "Resource", typ: pet.Type(), expected: []string{"pet"}},
+		{name: "Union", typ: stringOrNumber, expected: []string{}},
+		{name: "AssignableToUnion", typ: stringOrNumber, assignable: true, expected: []string{"prefix", "ratio", "name"}},
+		{
+			name:       "AssignableToDynamic",
+			typ:        model.DynamicType,
+			assignable: true,
+			expected:   []string{"prefix", "count", "ratio", "name", "pet"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, nodeNames(program.FindNodesByType(tt.typ, tt.assignable)))
+		})
+	}
+}
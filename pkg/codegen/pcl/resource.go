@@ -86,6 +86,11 @@ func (r *Resource) Type() model.Type {
 	return r.VariableType
 }
 
+// Kind returns the kind of the resource.
+func (*Resource) Kind() NodeKind {
+	return NodeKindResource
+}
+
 func (r *Resource) VisitExpressions(pre, post model.ExpressionVisitor) hcl.Diagnostics {
 	return model.VisitExpressions(r.Definition, pre, post)
 }
@@ -109,6 +114,15 @@ func (r *Resource) LogicalName() string {
 	return r.Name()
 }
 
+// ResourceOptions returns the resource's bound options. If the resource has no options block, the result is empty
+// rather than nil, so its fields can be checked directly.
+func (r *Resource) ResourceOptions() *ResourceOptions {
+	if r.Options == nil {
+		return &ResourceOptions{}
+	}
+	return r.Options
+}
+
 // DecomposeToken attempts to decompose the resource's type token into its package, module, and type. If decomposition
 // fails, a description of the failure is returned in the diagnostics.
 func (r *Resource) DecomposeToken() (string, string, string, hcl.Diagnostics) {
@@ -21,6 +21,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/pulumi/pulumi/pkg/v3/codegen"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/hcl2/model"
 )
@@ -34,6 +35,12 @@ func titleCase(s string) string {
 	return string([]rune{unicode.ToUpper(c)}) + s[sz:]
 }
 
+// isValidNodeName returns true if the given string can name a node. HCL identifiers may contain dashes, but a reference
+// to a name that contains a dash would be parsed as a subtraction.
+func isValidNodeName(s string) bool {
+	return hclsyntax.ValidIdentifier(s) && !strings.Contains(s, "-")
+}
+
 func SourceOrderNodes(nodes []Node) []Node {
 	sort.Slice(nodes, func(i, j int) bool {
 		return model.SourceOrderLess(nodes[i].SyntaxNode().Range(), nodes[j].SyntaxNode().Range())
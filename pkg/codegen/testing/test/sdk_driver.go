@@ -287,6 +287,26 @@ var PulumiPulumiSDKTests = []*SDKTest{
 		Description: "Generate a resource that outputs [][][]Foo",
 		Skip:        allLanguages.Except("go/any"),
 	},
+	{
+		Directory:   "go-env-flexible-list",
+		Description: "Generate a provider whose list default accepts comma- or space-separated environment variables",
+		Skip:        allLanguages.Except("go/any"),
+	},
+	{
+		Directory:   "go-env-default-docs",
+		Description: "Generate a provider whose default is read from several environment variables",
+		Skip:        allLanguages.Except("go/any"),
+	},
+	{
+		Directory:   "go-env-percentage",
+		Description: "Generate a provider whose number default is read from an environment variable as a percentage",
+		Skip:        allLanguages.Except("go/any"),
+	},
+	{
+		Directory:   "go-env-base64",
+		Description: "Generate a provider whose string default is read from a base64-encoded environment variable",
+		Skip:        allLanguages.Except("go/any"),
+	},
 }
 
 var genSDKOnly bool
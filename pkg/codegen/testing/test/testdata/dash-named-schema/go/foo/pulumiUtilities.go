@@ -17,12 +17,20 @@ import (
 
 type envParser func(v string) interface{}
 
+// parseEnvBool parses v with strconv.ParseBool. It also accepts the words "yes", "y", "on", "enable", and "enabled"
+// for true and "no", "n", "off", "disable", and "disabled" for false, ignoring case. It returns nil if v is none of
+// these, so that the default value applies.
 func parseEnvBool(v string) interface{} {
-	b, err := strconv.ParseBool(v)
-	if err != nil {
-		return nil
+	if b, err := strconv.ParseBool(v); err == nil {
+		return b
+	}
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "yes", "y", "on", "enable", "enabled":
+		return true
+	case "no", "n", "off", "disable", "disabled":
+		return false
 	}
-	return b
+	return nil
 }
 
 func parseEnvInt(v string) interface{} {
@@ -41,34 +49,46 @@ func parseEnvFloat(v string) interface{} {
 	return f
 }
 
+// parseEnvStringArray splits v on ";". Surrounding whitespace is trimmed from each element and empty elements are
+// dropped, so "a; b;" yields ["a", "b"], and a value with no elements yields an empty array.
 func parseEnvStringArray(v string) interface{} {
-	var result pulumi.StringArray
+	result := pulumi.StringArray{}
 	for _, item := range strings.Split(v, ";") {
-		result = append(result, pulumi.String(item))
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, pulumi.String(item))
+		}
 	}
 	return result
 }
 
+// getEnvOrDefault returns the value of the first of vars that is set to a non-empty string, parsed by parser unless
+// it is nil. If none of vars is set, or the parser cannot parse the value, def is returned.
 func getEnvOrDefault(def interface{}, parser envParser, vars ...string) interface{} {
 	for _, v := range vars {
 		if value := os.Getenv(v); value != "" {
-			if parser != nil {
-				return parser(value)
+			if parser == nil {
+				return value
+			}
+			if result := parser(value); result != nil {
+				return result
 			}
-			return value
+			return def
 		}
 	}
 	return def
 }
 
+// pkgVersionRegexp matches the import path of this package. It is compiled once
+// rather than by each call to PkgVersion.
+var pkgVersionRegexp = regexp.MustCompile("^.*/pulumi-foo-bar/sdk(/v\\d+)?")
+
 // PkgVersion uses reflection to determine the version of the current package.
 // If a version cannot be determined, v1 will be assumed. The second return
 // value is always nil.
 func PkgVersion() (semver.Version, error) {
 	type sentinal struct{}
 	pkgPath := reflect.TypeOf(sentinal{}).PkgPath()
-	re := regexp.MustCompile("^.*/pulumi-foo-bar/sdk(/v\\d+)?")
-	if match := re.FindStringSubmatch(pkgPath); match != nil {
+	if match := pkgVersionRegexp.FindStringSubmatch(pkgPath); match != nil {
 		vStr := match[1]
 		if len(vStr) == 0 { // If the version capture group was empty, default to v1.
 			return semver.Version{Major: 1}, nil
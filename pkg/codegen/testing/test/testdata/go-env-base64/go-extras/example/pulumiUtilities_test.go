@@ -0,0 +1,57 @@
+// Copyright 2016-2022, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package example
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnvBase64(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		value    string
+		expected interface{}
+	}{
+		{"c2VjcmV0", "secret"},
+		{" c2VjcmV0\n", "secret"},
+		{"", ""},
+
+		// Anything else leaves the default in place.
+		{"c2VjcmV0!", nil},
+		{"c2VjcmV", nil},
+		{"not base64", nil},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.value, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, parseEnvBase64(c.value))
+		})
+	}
+}
+
+//nolint:paralleltest // sets environment variables
+func TestProviderPrivateKeyDefault(t *testing.T) {
+	t.Setenv("EXAMPLE_PRIVATE_KEY", "c2VjcmV0")
+	assert.Equal(t, "secret", getEnvOrDefault("", parseEnvBase64, "EXAMPLE_PRIVATE_KEY").(string))
+
+	// A value that isn't valid base64 leaves the default in place.
+	t.Setenv("EXAMPLE_PRIVATE_KEY", "not base64")
+	assert.Equal(t, "fallback", getEnvOrDefault("fallback", parseEnvBase64, "EXAMPLE_PRIVATE_KEY").(string))
+}
@@ -0,0 +1,133 @@
+// Code generated by test DO NOT EDIT.
+// *** WARNING: Do not edit by hand unless you're certain you know what you are doing! ***
+
+package example
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type envParser func(v string) interface{}
+
+// parseEnvBool parses v with strconv.ParseBool. It also accepts the words "yes", "y", "on", "enable", and "enabled"
+// for true and "no", "n", "off", "disable", and "disabled" for false, ignoring case. It returns nil if v is none of
+// these, so that the default value applies.
+func parseEnvBool(v string) interface{} {
+	if b, err := strconv.ParseBool(v); err == nil {
+		return b
+	}
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "yes", "y", "on", "enable", "enabled":
+		return true
+	case "no", "n", "off", "disable", "disabled":
+		return false
+	}
+	return nil
+}
+
+func parseEnvInt(v string) interface{} {
+	i, err := strconv.ParseInt(v, 0, 0)
+	if err != nil {
+		return nil
+	}
+	return int(i)
+}
+
+func parseEnvFloat(v string) interface{} {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// parseEnvStringArray splits v on ";". Surrounding whitespace is trimmed from each element and empty elements are
+// dropped, so "a; b;" yields ["a", "b"], and a value with no elements yields an empty array.
+func parseEnvStringArray(v string) interface{} {
+	result := pulumi.StringArray{}
+	for _, item := range strings.Split(v, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, pulumi.String(item))
+		}
+	}
+	return result
+}
+
+// getEnvOrDefault returns the value of the first of vars that is set to a non-empty string, parsed by parser unless
+// it is nil. If none of vars is set, or the parser cannot parse the value, def is returned.
+func getEnvOrDefault(def interface{}, parser envParser, vars ...string) interface{} {
+	for _, v := range vars {
+		if value := os.Getenv(v); value != "" {
+			if debugEnvDefaults() {
+				logEnvDefault(v, vars)
+			}
+			if parser == nil {
+				return value
+			}
+			if result := parser(value); result != nil {
+				return result
+			}
+			return def
+		}
+	}
+	return def
+}
+
+// pkgVersionRegexp matches the import path of this package. It is compiled once
+// rather than by each call to PkgVersion.
+var pkgVersionRegexp = regexp.MustCompile("^.*/pulumi-example/sdk(/v\\d+)?")
+
+// PkgVersion uses reflection to determine the version of the current package.
+// If a version cannot be determined, v1 will be assumed. The second return
+// value is always nil.
+func PkgVersion() (semver.Version, error) {
+	type sentinal struct{}
+	pkgPath := reflect.TypeOf(sentinal{}).PkgPath()
+	if match := pkgVersionRegexp.FindStringSubmatch(pkgPath); match != nil {
+		vStr := match[1]
+		if len(vStr) == 0 { // If the version capture group was empty, default to v1.
+			return semver.Version{Major: 1}, nil
+		}
+		return semver.MustParse(fmt.Sprintf("%s.0.0", vStr[2:])), nil
+	}
+	return semver.Version{Major: 1}, nil
+}
+
+// isZero is a null safe check for if a value is it's types zero value.
+func isZero(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	return reflect.ValueOf(v).IsZero()
+}
+
+// debugEnvDefaults returns true if PULUMI_DEBUG_ENV is set to a true value, in which case the environment variable
+// that supplies each default value is logged.
+func debugEnvDefaults() bool {
+	return parseEnvBool(os.Getenv("PULUMI_DEBUG_ENV")) == true
+}
+
+// logEnvDefault logs that the environment variable name supplied a default value for which vars were candidates.
+func logEnvDefault(name string, vars []string) {
+	log.Printf("using environment variable %s for a default value (candidates: %s)", name, strings.Join(vars, ", "))
+}
+
+// parseEnvBase64 decodes v from standard base64, ignoring surrounding whitespace, and returns the decoded bytes as a
+// string. It returns nil if v is not valid base64, so that the default value applies.
+func parseEnvBase64(v string) interface{} {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
@@ -0,0 +1,67 @@
+// Copyright 2016-2022, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package example
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestParseEnvStringArrayFlexible(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		value    string
+		expected pulumi.StringArray
+	}{
+		// Commas, whitespace, and any mix of the two all delimit the same list.
+		{"a,b,c", pulumi.StringArray{pulumi.String("a"), pulumi.String("b"), pulumi.String("c")}},
+		{"a b c", pulumi.StringArray{pulumi.String("a"), pulumi.String("b"), pulumi.String("c")}},
+		{"a, b,\tc", pulumi.StringArray{pulumi.String("a"), pulumi.String("b"), pulumi.String("c")}},
+		{"a\nb\n\nc\n", pulumi.StringArray{pulumi.String("a"), pulumi.String("b"), pulumi.String("c")}},
+		{" ,a,, b , ", pulumi.StringArray{pulumi.String("a"), pulumi.String("b")}},
+
+		// Unlike parseEnvStringArray, semicolons are not delimiters.
+		{"a;b", pulumi.StringArray{pulumi.String("a;b")}},
+
+		// Values with no elements yield an empty, non-nil array.
+		{"", pulumi.StringArray{}},
+		{", ,\t", pulumi.StringArray{}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.value, func(t *testing.T) {
+			t.Parallel()
+
+			actual, ok := parseEnvStringArrayFlexible(c.value).(pulumi.StringArray)
+			assert.True(t, ok)
+			assert.NotNil(t, actual)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+//nolint:paralleltest // sets environment variables
+func TestProviderHostsDefault(t *testing.T) {
+	// The provider's hosts default to the same list however EXAMPLE_HOSTS is delimited.
+	for _, value := range []string{"a.example.com,b.example.com", "a.example.com b.example.com"} {
+		t.Setenv("EXAMPLE_HOSTS", value)
+		hosts := getEnvOrDefault(pulumi.StringArray{}, parseEnvStringArrayFlexible, "EXAMPLE_HOSTS")
+		assert.Equal(t, pulumi.StringArray{pulumi.String("a.example.com"), pulumi.String("b.example.com")}, hosts)
+	}
+}
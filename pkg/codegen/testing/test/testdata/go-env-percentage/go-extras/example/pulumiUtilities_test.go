@@ -0,0 +1,67 @@
+// Copyright 2016-2022, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package example
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnvPercentage(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		value    string
+		expected interface{}
+	}{
+		// Fractions and percentages of the same amount are the same.
+		{"0.5", 0.5},
+		{"50%", 0.5},
+		{" 50 % ", 0.5},
+		{"0", 0.0},
+		{"100%", 1.0},
+		{"2.5e-1", 0.25},
+
+		// Anything else leaves the default in place.
+		{"", nil},
+		{"abc%", nil},
+		{"%", nil},
+		{"150%", nil},
+		{"1.5", nil},
+		{"-10%", nil},
+		{"NaN", nil},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.value, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, parseEnvPercentage(c.value))
+		})
+	}
+}
+
+//nolint:paralleltest // sets environment variables
+func TestProviderSampleRateDefault(t *testing.T) {
+	t.Setenv("EXAMPLE_SAMPLE_RATE", "25%")
+	assert.Equal(t, 0.25, getEnvOrDefault(0.0, parseEnvPercentage, "EXAMPLE_SAMPLE_RATE").(float64))
+
+	// A value that isn't a valid percentage leaves the default in place.
+	for _, value := range []string{"abc%", "150%"} {
+		t.Setenv("EXAMPLE_SAMPLE_RATE", value)
+		assert.Equal(t, 0.1, getEnvOrDefault(0.1, parseEnvPercentage, "EXAMPLE_SAMPLE_RATE").(float64))
+	}
+}
@@ -0,0 +1,3 @@
+// Package example exports types, functions, subpackages for provisioning example resources.
+//
+package example
@@ -12,6 +12,7 @@ import (
 
 // A test for namespaces (mod 1)
 type Typ struct {
+	// If unset, defaults to the value of the PULUMI_EXAMPLE_MOD1_DEFAULT environment variable.
 	Val *string `pulumi:"val"`
 }
 
@@ -41,6 +42,7 @@ type TypInput interface {
 
 // A test for namespaces (mod 1)
 type TypArgs struct {
+	// If unset, defaults to the value of the PULUMI_EXAMPLE_MOD1_DEFAULT environment variable.
 	Val pulumi.StringPtrInput `pulumi:"val"`
 }
 
@@ -15,8 +15,12 @@ import (
 // BETA FEATURE - Options to configure the Helm Release resource.
 type HelmReleaseSettings struct {
 	// The backend storage driver for Helm. Values are: configmap, secret, memory, sql.
+	//
+	// If unset, defaults to the value of the PULUMI_K8S_HELM_DRIVER environment variable.
 	Driver *string `pulumi:"driver"`
 	// The path to the helm plugins directory.
+	//
+	// If unset, defaults to the value of the PULUMI_K8S_HELM_PLUGINS_PATH environment variable.
 	PluginsPath *string `pulumi:"pluginsPath"`
 	// to test required args
 	RequiredArg string `pulumi:"requiredArg"`
@@ -53,8 +57,12 @@ type HelmReleaseSettingsInput interface {
 // BETA FEATURE - Options to configure the Helm Release resource.
 type HelmReleaseSettingsArgs struct {
 	// The backend storage driver for Helm. Values are: configmap, secret, memory, sql.
+	//
+	// If unset, defaults to the value of the PULUMI_K8S_HELM_DRIVER environment variable.
 	Driver pulumi.StringPtrInput `pulumi:"driver"`
 	// The path to the helm plugins directory.
+	//
+	// If unset, defaults to the value of the PULUMI_K8S_HELM_PLUGINS_PATH environment variable.
 	PluginsPath pulumi.StringPtrInput `pulumi:"pluginsPath"`
 	// to test required args
 	RequiredArg pulumi.StringInput `pulumi:"requiredArg"`
@@ -224,8 +232,12 @@ func (o HelmReleaseSettingsPtrOutput) RequiredArg() pulumi.StringPtrOutput {
 // Options for tuning the Kubernetes client used by a Provider.
 type KubeClientSettings struct {
 	// Maximum burst for throttle. Default value is 10.
+	//
+	// If unset, defaults to the value of the PULUMI_K8S_CLIENT_BURST environment variable.
 	Burst *int `pulumi:"burst"`
 	// Maximum queries per second (QPS) to the API server from this client. Default value is 5.
+	//
+	// If unset, defaults to the value of the PULUMI_K8S_CLIENT_QPS environment variable.
 	Qps     *float64            `pulumi:"qps"`
 	RecTest *KubeClientSettings `pulumi:"recTest"`
 }
@@ -263,8 +275,12 @@ type KubeClientSettingsInput interface {
 // Options for tuning the Kubernetes client used by a Provider.
 type KubeClientSettingsArgs struct {
 	// Maximum burst for throttle. Default value is 10.
+	//
+	// If unset, defaults to the value of the PULUMI_K8S_CLIENT_BURST environment variable.
 	Burst pulumi.IntPtrInput `pulumi:"burst"`
 	// Maximum queries per second (QPS) to the API server from this client. Default value is 5.
+	//
+	// If unset, defaults to the value of the PULUMI_K8S_CLIENT_QPS environment variable.
 	Qps     pulumi.Float64PtrInput     `pulumi:"qps"`
 	RecTest KubeClientSettingsPtrInput `pulumi:"recTest"`
 }
@@ -437,6 +453,8 @@ type LayeredType struct {
 	// Test how plain types interact
 	PlainOther *HelmReleaseSettings `pulumi:"plainOther"`
 	// The question already answered
+	//
+	// If unset, defaults to the value of the PULUMI_THE_QUESTION environment variable.
 	Question  *string      `pulumi:"question"`
 	Recursive *LayeredType `pulumi:"recursive"`
 	// To ask and answer
@@ -488,6 +506,8 @@ type LayeredTypeArgs struct {
 	// Test how plain types interact
 	PlainOther *HelmReleaseSettingsArgs `pulumi:"plainOther"`
 	// The question already answered
+	//
+	// If unset, defaults to the value of the PULUMI_THE_QUESTION environment variable.
 	Question  pulumi.StringPtrInput `pulumi:"question"`
 	Recursive LayeredTypePtrInput   `pulumi:"recursive"`
 	// To ask and answer
@@ -0,0 +1,57 @@
+// Copyright 2016-2022, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//nolint:paralleltest // sets environment variables and changes the output of the standard logger
+func TestGetEnvOrDefaultPrecedence(t *testing.T) {
+	t.Setenv("SECRET_CODE", "first")
+	t.Setenv("MY_SUPER_SECRET_CODE", "second")
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	// The first of the variables that is set wins, quietly.
+	t.Setenv("PULUMI_DEBUG_ENV", "")
+	assert.Equal(t, "first", getEnvOrDefault("", nil, "SECRET_CODE", "MY_SUPER_SECRET_CODE"))
+	assert.Empty(t, logged.String())
+
+	// Under PULUMI_DEBUG_ENV, the variable that supplied the value is reported. The variable is read when a default
+	// is looked up, so it can be set after the package is initialized.
+	t.Setenv("PULUMI_DEBUG_ENV", "true")
+	assert.Equal(t, "first", getEnvOrDefault("", nil, "SECRET_CODE", "MY_SUPER_SECRET_CODE"))
+	assert.Contains(t, logged.String(),
+		"using environment variable SECRET_CODE for a default value (candidates: SECRET_CODE, MY_SUPER_SECRET_CODE)")
+}
+
+//nolint:paralleltest // sets environment variables
+func TestGetEnvOrDefaultAllocations(t *testing.T) {
+	t.Setenv("PULUMI_DEBUG_ENV", "true")
+
+	// Looking up a default that no variable supplies does not allocate, even under PULUMI_DEBUG_ENV.
+	allocs := testing.AllocsPerRun(100, func() {
+		getEnvOrDefault("", nil, "CONFIGSTATION_UNSET_A", "CONFIGSTATION_UNSET_B")
+	})
+	assert.Zero(t, allocs)
+}
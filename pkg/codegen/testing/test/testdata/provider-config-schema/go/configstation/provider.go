@@ -34,12 +34,16 @@ func NewProvider(ctx *pulumi.Context,
 
 type providerArgs struct {
 	// this is a relaxed string enum which can also be set via env var
+	//
+	// If unset, defaults to the value of the FAVE_COLOR environment variable.
 	FavoriteColor *string `pulumi:"favoriteColor"`
 }
 
 // The set of arguments for constructing a Provider resource.
 type ProviderArgs struct {
 	// this is a relaxed string enum which can also be set via env var
+	//
+	// If unset, defaults to the value of the FAVE_COLOR environment variable.
 	FavoriteColor pulumi.StringPtrInput
 }
 
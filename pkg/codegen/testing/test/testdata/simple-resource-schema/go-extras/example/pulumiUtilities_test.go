@@ -0,0 +1,111 @@
+// Copyright 2016-2022, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package example
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestParseEnvBool(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		value    string
+		expected interface{}
+	}{
+		// Forms accepted by strconv.ParseBool.
+		{"true", true},
+		{"1", true},
+		{"T", true},
+		{"false", false},
+		{"0", false},
+		{"F", false},
+
+		// Words for true and false, in any case.
+		{"yes", true},
+		{"Y", true},
+		{"ON", true},
+		{"enable", true},
+		{"Enabled", true},
+		{" yes ", true},
+		{"no", false},
+		{"N", false},
+		{"Off", false},
+		{"disable", false},
+		{"DISABLED", false},
+
+		// Anything else leaves the default in place.
+		{"", nil},
+		{"maybe", nil},
+		{"yess", nil},
+		{"2", nil},
+		{"on off", nil},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.value, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, parseEnvBool(c.value))
+		})
+	}
+}
+
+//nolint:paralleltest // sets environment variables
+func TestGetEnvOrDefault(t *testing.T) {
+	// A value that the parser cannot parse leaves the default in place rather than failing the type assertion.
+	t.Setenv("EXAMPLE_FLAG", "maybe")
+	assert.Equal(t, true, getEnvOrDefault(true, parseEnvBool, "EXAMPLE_FLAG").(bool))
+	t.Setenv("EXAMPLE_COUNT", "many")
+	assert.Equal(t, 3, getEnvOrDefault(3, parseEnvInt, "EXAMPLE_COUNT").(int))
+
+	// A value that the parser can parse is used.
+	t.Setenv("EXAMPLE_FLAG", "off")
+	assert.Equal(t, false, getEnvOrDefault(true, parseEnvBool, "EXAMPLE_FLAG").(bool))
+}
+
+func TestParseEnvStringArray(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		value    string
+		expected pulumi.StringArray
+	}{
+		{"a;b;c", pulumi.StringArray{pulumi.String("a"), pulumi.String("b"), pulumi.String("c")}},
+		{"a; b ;\tc", pulumi.StringArray{pulumi.String("a"), pulumi.String("b"), pulumi.String("c")}},
+		{"a;;b;", pulumi.StringArray{pulumi.String("a"), pulumi.String("b")}},
+		{"a b", pulumi.StringArray{pulumi.String("a b")}},
+
+		// Values with no elements yield an empty, non-nil array.
+		{"", pulumi.StringArray{}},
+		{";", pulumi.StringArray{}},
+		{" ; ;  ", pulumi.StringArray{}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.value, func(t *testing.T) {
+			t.Parallel()
+
+			actual, ok := parseEnvStringArray(c.value).(pulumi.StringArray)
+			assert.True(t, ok)
+			assert.NotNil(t, actual)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
@@ -15,18 +15,26 @@
 package gitutil
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/util/fsutil"
 	git "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
 )
 
@@ -232,10 +240,167 @@ func getMatchedGroupsFromRegex(regex *regexp.Regexp, remoteURL string) map[strin
 	return groups
 }
 
+// HTTPOptions configures the HTTP client that a Git operation uses to reach a remote repository over HTTP or HTTPS.
+// The options apply only to the operations they are passed to. The zero value uses go-git's default client.
+type HTTPOptions struct {
+	// Proxy is the proxy to send requests through. If nil, the proxy given by the HTTPS_PROXY, HTTP_PROXY, and
+	// NO_PROXY environment variables, if any, is used.
+	Proxy *url.URL
+	// RootCAs is the pool of root certificate authorities that HTTPS servers are verified against. If nil, the
+	// system's certificate authorities are used.
+	RootCAs *x509.CertPool
+	// Token, if set, authenticates the requests sent over HTTPS to TokenHost, so that private repositories can be
+	// retrieved. It is sent as the password of a basic credential to github.com and as a bearer token to other hosts.
+	// It is never sent over plain HTTP, to other hosts, or after a redirect to another host, nor with requests that
+	// already carry credentials, e.g. from the user info of the repository URL.
+	Token string
+	// TokenHost is the host, without a port, that Token is sent to.
+	TokenHost string
+}
+
+// auth returns the credentials to pass to go-git for an operation on the repository at the given URL with the
+// options, which carry the operation's HTTP client to httpTransport. It returns nil for the zero value and for
+// repositories not reached over HTTP or HTTPS, which leaves the operation to go-git's defaults.
+func (o HTTPOptions) auth(rawurl string) transport.AuthMethod {
+	if o == (HTTPOptions{}) {
+		return nil
+	}
+	if u, err := url.Parse(rawurl); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+
+	installHTTPTransport()
+	a := &httpClientAuth{client: newHTTPClient(o.Proxy, o.RootCAs), token: o.Token, tokenHost: o.TokenHost}
+	a.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !a.sendsTokenTo(req) {
+			req.Header.Del("Authorization")
+		}
+		return nil
+	}
+	return a
+}
+
+// LoadCABundle returns a pool of root certificate authorities, for HTTPOptions.RootCAs, that holds the system's
+// certificate authorities and those in the PEM file at the given path. This lets Git operations work behind proxies
+// that inspect TLS traffic.
+func LoadCABundle(path string) (*x509.CertPool, error) {
+	bundle, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading CA bundle %v", path)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(bundle) {
+		return nil, errors.Errorf("no PEM certificates found in CA bundle %v", path)
+	}
+	return roots, nil
+}
+
+// installHTTPTransportOnce guards the installation of httpTransport.
+var installHTTPTransportOnce sync.Once
+
+// installHTTPTransport installs httpTransport as go-git's transport for HTTP and HTTPS, in front of the transports that
+// were installed before.
+func installHTTPTransport() {
+	installHTTPTransportOnce.Do(func() {
+		for _, scheme := range []string{"http", "https"} {
+			client.InstallProtocol(scheme, &httpTransport{fallback: client.Protocols[scheme]})
+		}
+	})
+}
+
+// httpTransport is the go-git transport for HTTP and HTTPS. go-git looks up one transport per protocol for the whole
+// process, so the HTTP client of an operation given HTTPOptions reaches httpTransport as the operation's credentials,
+// in an *httpClientAuth. Operations without HTTPOptions are passed on unchanged to the transport that go-git would
+// otherwise have used.
+type httpTransport struct {
+	fallback transport.Transport
+}
+
+func (t *httpTransport) NewUploadPackSession(ep *transport.Endpoint,
+	auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	if a, ok := auth.(*httpClientAuth); ok {
+		return githttp.NewClient(a.client).NewUploadPackSession(ep, a)
+	}
+	return t.fallback.NewUploadPackSession(ep, auth)
+}
+
+func (t *httpTransport) NewReceivePackSession(ep *transport.Endpoint,
+	auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	if a, ok := auth.(*httpClientAuth); ok {
+		return githttp.NewClient(a.client).NewReceivePackSession(ep, a)
+	}
+	return t.fallback.NewReceivePackSession(ep, auth)
+}
+
+// httpClientAuth carries the HTTP client of a Git operation given HTTPOptions to httpTransport, and authenticates the
+// operation's requests with the token from the options.
+type httpClientAuth struct {
+	client    *http.Client
+	token     string
+	tokenHost string
+}
+
+func (a *httpClientAuth) Name() string {
+	return "http-client"
+}
+
+func (a *httpClientAuth) String() string {
+	return a.Name()
+}
+
+// SetAuth adds the token to the request if it may be sent there. Credentials in the repository URL are sent by the
+// HTTP client instead.
+func (a *httpClientAuth) SetAuth(r *http.Request) {
+	if !a.sendsTokenTo(r) || r.URL.User != nil || r.Header.Get("Authorization") != "" {
+		return
+	}
+
+	if a.tokenHost == GitHubHostName {
+		// GitHub accepts a token as the password of a basic credential with any username.
+		r.SetBasicAuth("x-access-token", a.token)
+	} else {
+		r.Header.Set("Authorization", "Bearer "+a.token)
+	}
+}
+
+// sendsTokenTo returns true if the token may be sent with the given request, i.e. if the request goes to the token's
+// host over HTTPS.
+func (a *httpClientAuth) sendsTokenTo(r *http.Request) bool {
+	return a.token != "" && r.URL.Scheme == "https" && strings.EqualFold(r.URL.Hostname(), a.tokenHost)
+}
+
+// newHTTPClient returns an HTTP client that sends its requests through the given proxy, or the proxy configured by
+// the environment if proxy is nil, and that trusts the given root certificate authorities, or the system's if roots
+// is nil.
+func newHTTPClient(proxy *url.URL, roots *x509.CertPool) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if proxy != nil {
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	if roots != nil {
+		t.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+	return &http.Client{Transport: t}
+}
+
 // GitCloneAndCheckoutCommit clones the Git repository and checkouts the specified commit.
 func GitCloneAndCheckoutCommit(url string, commit plumbing.Hash, path string) error {
+	return GitCloneAndCheckoutCommitWithOptions(url, commit, path, HTTPOptions{})
+}
+
+// GitCloneAndCheckoutCommitWithOptions is like GitCloneAndCheckoutCommit, but reaches the repository with the given
+// HTTP options.
+func GitCloneAndCheckoutCommitWithOptions(url string, commit plumbing.Hash, path string, opts HTTPOptions) error {
 	repo, err := git.PlainClone(path, false, &git.CloneOptions{
-		URL: url,
+		URL:  url,
+		Auth: opts.auth(url),
 	})
 	if err != nil {
 		return err
@@ -254,6 +419,14 @@ func GitCloneAndCheckoutCommit(url string, commit plumbing.Hash, path string) er
 
 // GitCloneOrPull clones or updates the specified referenceName (branch or tag) of a Git repository.
 func GitCloneOrPull(url string, referenceName plumbing.ReferenceName, path string, shallow bool) error {
+	return GitCloneOrPullWithOptions(url, referenceName, path, shallow, HTTPOptions{})
+}
+
+// GitCloneOrPullWithOptions is like GitCloneOrPull, but reaches the repository with the given HTTP options.
+func GitCloneOrPullWithOptions(url string, referenceName plumbing.ReferenceName, path string, shallow bool,
+	opts HTTPOptions) error {
+	auth := opts.auth(url)
+
 	// For shallow clones, use a depth of 1.
 	depth := 0
 	if shallow {
@@ -267,6 +440,7 @@ func GitCloneOrPull(url string, referenceName plumbing.ReferenceName, path strin
 		SingleBranch:  true,
 		Depth:         depth,
 		Tags:          git.NoTags,
+		Auth:          auth,
 	})
 	if cloneErr != nil {
 		// If the repo already exists, open it and pull.
@@ -285,6 +459,7 @@ func GitCloneOrPull(url string, referenceName plumbing.ReferenceName, path strin
 				ReferenceName: referenceName,
 				SingleBranch:  true,
 				Force:         true,
+				Auth:          auth,
 			}); err != nil && err != git.NoErrAlreadyUpToDate {
 				return err
 			}
@@ -392,6 +567,13 @@ var gitSHARegex = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
 // The sub directory path always uses "/" as the separator.
 func GetGitReferenceNameOrHashAndSubDirectory(url string, urlPath string) (
 	plumbing.ReferenceName, plumbing.Hash, string, error) {
+	return GetGitReferenceNameOrHashAndSubDirectoryWithOptions(url, urlPath, HTTPOptions{})
+}
+
+// GetGitReferenceNameOrHashAndSubDirectoryWithOptions is like GetGitReferenceNameOrHashAndSubDirectory, but reaches
+// the repository, if its references need to be listed, with the given HTTP options.
+func GetGitReferenceNameOrHashAndSubDirectoryWithOptions(url string, urlPath string, opts HTTPOptions) (
+	plumbing.ReferenceName, plumbing.Hash, string, error) {
 
 	// If path is empty, use HEAD.
 	if urlPath == "" {
@@ -421,7 +603,7 @@ func GetGitReferenceNameOrHashAndSubDirectory(url string, urlPath string) (
 			// Otherwise, try matching based on the repo's refs.
 
 			// Get the list of refs sorted by length.
-			refs, err := GitListBranchesAndTags(url)
+			refs, err := GitListBranchesAndTagsWithOptions(url, opts)
 			if err != nil {
 				return "", plumbing.ZeroHash, "", err
 			}
@@ -450,6 +632,12 @@ func GetGitReferenceNameOrHashAndSubDirectory(url string, urlPath string) (
 // GitListBranchesAndTags fetches a remote Git repository's branch and tag references
 // (including HEAD), sorted by the length of the short name descending.
 func GitListBranchesAndTags(url string) ([]plumbing.ReferenceName, error) {
+	return GitListBranchesAndTagsWithOptions(url, HTTPOptions{})
+}
+
+// GitListBranchesAndTagsWithOptions is like GitListBranchesAndTags, but reaches the repository with the given HTTP
+// options.
+func GitListBranchesAndTagsWithOptions(url string, opts HTTPOptions) ([]plumbing.ReferenceName, error) {
 	// We're only listing the references, so just use in-memory storage.
 	repo, err := git.Init(memory.NewStorage(), nil)
 	if err != nil {
@@ -464,7 +652,7 @@ func GitListBranchesAndTags(url string) ([]plumbing.ReferenceName, error) {
 		return nil, err
 	}
 
-	refs, err := remote.List(&git.ListOptions{})
+	refs, err := remote.List(&git.ListOptions{Auth: opts.auth(url)})
 	if err != nil {
 		return nil, err
 	}
@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 
 	"github.com/pkg/errors"
@@ -145,6 +146,8 @@ func (proj *Project) Save(path string) error {
 }
 
 type PolicyPackProject struct {
+	// Name is an optional name for the policy pack. If omitted, the name is determined by the policy pack's code.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
 	// Runtime is a required runtime that executes code.
 	Runtime ProjectRuntimeInfo `json:"runtime" yaml:"runtime"`
 	// Version specifies the version of the policy pack. If set, it will override the
@@ -162,12 +165,72 @@ type PolicyPackProject struct {
 	Website *string `json:"website,omitempty" yaml:"website,omitempty"`
 	// License is the optional license governing this project's usage.
 	License *string `json:"license,omitempty" yaml:"license,omitempty"`
+
+	// Template is an optional template manifest, if this policy pack is a template.
+	Template *PolicyPackTemplateManifest `json:"template,omitempty" yaml:"template,omitempty"`
+}
+
+// PolicyPackTemplateManifest is a Policy Pack template manifest.
+type PolicyPackTemplateManifest struct {
+	// Framework lists glob patterns, such as `package.json` or `*.csproj`, that match the template's framework and
+	// configuration files. `pulumi policy new --upgrade` updates these files in Policy Packs that were created from an
+	// earlier version of the template, and leaves the Policy Packs' other files, such as their policies, alone.
+	// Patterns match a file's slash-separated path relative to the template or its name.
+	Framework []string `json:"framework,omitempty" yaml:"framework,omitempty"`
+	// Hooks are optional commands to run in a new Policy Pack's directory after it has been created from the template
+	// and its dependencies have been installed, such as generating a lockfile or running a formatter. Each hook is a
+	// program followed by its arguments, which are split like a shell's words: quotes group words and backslashes
+	// escape characters, but no other shell syntax is interpreted.
+	Hooks []string `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	// Packs are the names of the subdirectories of a template that bundles several related Policy Packs, each of which
+	// is itself a Policy Pack template. `pulumi policy new --all` creates a Policy Pack from each of them.
+	Packs []string `json:"packs,omitempty" yaml:"packs,omitempty"`
+	// Parameters are values that `pulumi policy new` asks for when a Policy Pack is created from the template. Each
+	// replaces the ${name} placeholders in the template's files.
+	Parameters []PolicyPackTemplateParameter `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+// PolicyPackTemplateParameter is a value that a Policy Pack template asks for when a Policy Pack is created from it.
+type PolicyPackTemplateParameter struct {
+	// Name is the name of the parameter, which is used in the template's ${name} placeholders.
+	Name string `json:"name" yaml:"name"`
+	// Prompt is an optional message to prompt for the parameter with; if not specified, the name is used.
+	Prompt string `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+	// Default is an optional default value for the parameter.
+	Default string `json:"default,omitempty" yaml:"default,omitempty"`
+	// Required may be set to true to indicate that the parameter must be given a non-empty value.
+	Required bool `json:"required,omitempty" yaml:"required,omitempty"`
 }
 
 func (proj *PolicyPackProject) Validate() error {
 	if proj.Runtime.Name() == "" {
 		return errors.New("project is missing a 'runtime' attribute")
 	}
+	if proj.Template != nil {
+		for _, pack := range proj.Template.Packs {
+			if pack == "" || pack == "." || pack == ".." || filepath.Base(pack) != pack {
+				return errors.Errorf("invalid pack '%s': packs must name subdirectories of the template", pack)
+			}
+		}
+		names := make(map[string]bool, len(proj.Template.Parameters))
+		for _, param := range proj.Template.Parameters {
+			if param.Name == "" {
+				return errors.New("template parameters must have a 'name'")
+			}
+			if err := ValidateTemplateVariableName(param.Name); err != nil {
+				return err
+			}
+			if names[param.Name] {
+				return errors.Errorf("duplicate template parameter '%s'", param.Name)
+			}
+			names[param.Name] = true
+		}
+		for _, pattern := range proj.Template.Framework {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				return errors.Errorf("invalid framework pattern '%s'", pattern)
+			}
+		}
+	}
 
 	return nil
 }
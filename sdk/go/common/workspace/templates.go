@@ -15,21 +15,33 @@
 package workspace
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/texttheater/golang-levenshtein/levenshtein"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/archive"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/util/contract"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/util/gitutil"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/logging"
 )
 
 const (
@@ -46,6 +58,18 @@ const (
 	// pulumiLocalPolicyTemplatePathEnvVar is a path to the folder where policy templates are stored.
 	// It is used in sandboxed environments where the classic template folder may not be writable.
 	pulumiLocalPolicyTemplatePathEnvVar = "PULUMI_POLICY_TEMPLATE_PATH"
+
+	// pulumiTemplateRetriesEnvVar is the number of times to retry retrieving templates after a transient failure.
+	pulumiTemplateRetriesEnvVar = "PULUMI_TEMPLATE_RETRIES"
+	// defaultTemplateRetries is the number of retries used if pulumiTemplateRetriesEnvVar is not set.
+	defaultTemplateRetries = 3
+	// templateRetryDelay is the delay before the first retry; the delay doubles with each subsequent retry.
+	templateRetryDelay = 500 * time.Millisecond
+
+	// templateChecksumsSuffix names the file that records the checksums of the files in a policy template cache as
+	// they were when the templates were downloaded. The file is written next to the template directory rather than
+	// inside it, so that it does not show up as an untracked file in the templates' git checkout.
+	templateChecksumsSuffix = ".checksums.json"
 )
 
 // These are variables instead of constants in order that they can be set using the `-X`
@@ -202,9 +226,16 @@ type Template struct {
 
 // PolicyPackTemplate represents a Policy Pack template.
 type PolicyPackTemplate struct {
-	Dir         string // The directory containing PulumiPolicy.yaml.
-	Name        string // The name of the template.
-	Description string // Description of the template.
+	Dir         string   // The directory containing PulumiPolicy.yaml.
+	Name        string   // The name of the template.
+	Description string   // Description of the template.
+	Runtime     string   // The runtime of the template.
+	Source      string   // Where the template was found, if it is not a built-in template.
+	Hooks       []string // Commands to run after a Policy Pack has been created from the template.
+	Packs       []string // The subdirectories that hold the Policy Packs of a template that bundles several.
+	Framework   []string // Patterns matching the framework files that `pulumi policy new --upgrade` updates.
+
+	Parameters []PolicyPackTemplateParameter // Values to ask for when a Policy Pack is created from the template.
 }
 
 // cleanupLegacyTemplateDir deletes an existing ~/.pulumi/templates directory if it isn't a git repository.
@@ -258,20 +289,52 @@ func isTemplateFileOrDirectory(templateNamePathOrURL string) bool {
 	return err == nil
 }
 
-// RetrieveTemplates retrieves a "template repository" based on the specified name, path, or URL.
+// isTemplateArchive returns true if templateNamePathOrURL is the path of a local .tar.gz or .tgz file.
+func isTemplateArchive(templateNamePathOrURL string) bool {
+	if templateArchiveName(templateNamePathOrURL) == "" {
+		return false
+	}
+	info, err := os.Stat(templateNamePathOrURL)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// templateArchiveName returns the base name of the given .tar.gz or .tgz path without its extension, or "" if the
+// path does not have one of those extensions.
+func templateArchiveName(path string) string {
+	base := filepath.Base(path)
+	for _, ext := range []string{".tar.gz", ".tgz"} {
+		if len(base) > len(ext) && strings.EqualFold(base[len(base)-len(ext):], ext) {
+			return base[:len(base)-len(ext)]
+		}
+	}
+	return ""
+}
+
+// RetrieveTemplates retrieves a "template repository" based on the specified name, path, or URL. A path to a local
+// .tar.gz or .tgz file is extracted to a temporary directory, which is removed by Delete.
 func RetrieveTemplates(templateNamePathOrURL string, offline bool,
 	templateKind TemplateKind) (TemplateRepository, error) {
+	return RetrieveTemplatesWithOptions(templateNamePathOrURL, offline, templateKind, gitutil.HTTPOptions{})
+}
+
+// RetrieveTemplatesWithOptions is like RetrieveTemplates, but downloads templates from a Git repository with the
+// given HTTP options.
+func RetrieveTemplatesWithOptions(templateNamePathOrURL string, offline bool, templateKind TemplateKind,
+	opts gitutil.HTTPOptions) (TemplateRepository, error) {
 	if IsTemplateURL(templateNamePathOrURL) {
-		return retrieveURLTemplates(templateNamePathOrURL, offline, templateKind)
+		return retrieveURLTemplates(templateNamePathOrURL, offline, opts)
+	}
+	if isTemplateArchive(templateNamePathOrURL) {
+		return retrieveArchiveTemplates(templateNamePathOrURL)
 	}
 	if isTemplateFileOrDirectory(templateNamePathOrURL) {
 		return retrieveFileTemplates(templateNamePathOrURL)
 	}
-	return retrievePulumiTemplates(templateNamePathOrURL, offline, templateKind)
+	return retrievePulumiTemplates(templateNamePathOrURL, offline, templateKind, opts)
 }
 
 // retrieveURLTemplates retrieves the "template repository" at the specified URL.
-func retrieveURLTemplates(rawurl string, offline bool, templateKind TemplateKind) (TemplateRepository, error) {
+func retrieveURLTemplates(rawurl string, offline bool, opts gitutil.HTTPOptions) (TemplateRepository, error) {
 	if offline {
 		return TemplateRepository{}, errors.Errorf("cannot use %s offline", rawurl)
 	}
@@ -285,7 +348,16 @@ func retrieveURLTemplates(rawurl string, offline bool, templateKind TemplateKind
 	}
 
 	var fullPath string
-	if fullPath, err = RetrieveGitFolder(rawurl, temp); err != nil {
+	err = retryTemplateRetrieval(rawurl, templateRetries(), templateRetryDelay, func() error {
+		var err error
+		if fullPath, err = RetrieveGitFolderWithOptions(rawurl, temp, opts); err != nil {
+			// Start the next attempt from an empty directory.
+			contract.IgnoreError(os.RemoveAll(temp))
+			contract.IgnoreError(os.MkdirAll(temp, 0700))
+		}
+		return err
+	})
+	if err != nil {
 		return TemplateRepository{}, fmt.Errorf("Failed to retrieve git folder: %w", err)
 	}
 
@@ -296,6 +368,43 @@ func retrieveURLTemplates(rawurl string, offline bool, templateKind TemplateKind
 	}, nil
 }
 
+// retrieveArchiveTemplates extracts the .tar.gz or .tgz file at the specified path into a temporary "template
+// repository". The templates are extracted into a directory named after the archive, so that a template at the root of
+// the archive is named after it. If the archive contains nothing but a single directory, that directory is used.
+func retrieveArchiveTemplates(path string) (TemplateRepository, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return TemplateRepository{}, err
+	}
+	defer contract.IgnoreClose(f)
+
+	temp, err := ioutil.TempDir("", "pulumi-template-")
+	if err != nil {
+		return TemplateRepository{}, err
+	}
+	repo := TemplateRepository{
+		Root:         temp,
+		SubDirectory: filepath.Join(temp, templateArchiveName(path)),
+		ShouldDelete: true,
+	}
+
+	if err := archive.ExtractTGZ(f, repo.SubDirectory); err != nil {
+		contract.IgnoreError(repo.Delete())
+		return TemplateRepository{}, fmt.Errorf("extracting template archive %s: %w", path, err)
+	}
+
+	infos, err := ioutil.ReadDir(repo.SubDirectory)
+	if err != nil {
+		contract.IgnoreError(repo.Delete())
+		return TemplateRepository{}, err
+	}
+	if len(infos) == 1 && infos[0].IsDir() {
+		repo.SubDirectory = filepath.Join(repo.SubDirectory, infos[0].Name())
+	}
+
+	return repo, nil
+}
+
 // retrieveFileTemplates points to the "template repository" at the specified location in the file system.
 func retrieveFileTemplates(path string) (TemplateRepository, error) {
 	return TemplateRepository{
@@ -307,13 +416,18 @@ func retrieveFileTemplates(path string) (TemplateRepository, error) {
 
 // retrievePulumiTemplates retrieves the "template repository" for Pulumi templates.
 // Instead of retrieving to a temporary directory, the Pulumi templates are managed from
-// ~/.pulumi/templates.
-func retrievePulumiTemplates(templateName string, offline bool, templateKind TemplateKind) (TemplateRepository, error) {
+// the templates directory under PULUMI_HOME (~/.pulumi by default); see GetTemplateDir.
+// When offline, the templates already in that directory are used as they are.
+func retrievePulumiTemplates(templateName string, offline bool, templateKind TemplateKind,
+	opts gitutil.HTTPOptions) (TemplateRepository, error) {
 	templateName = strings.ToLower(templateName)
 
-	// Cleanup the template directory.
-	if err := cleanupLegacyTemplateDir(templateKind); err != nil {
-		return TemplateRepository{}, err
+	// Cleanup the template directory. This is skipped when offline, as the templates couldn't be
+	// downloaded again and removing them would leave nothing to use.
+	if !offline {
+		if err := cleanupLegacyTemplateDir(templateKind); err != nil {
+			return TemplateRepository{}, err
+		}
 	}
 
 	// Get the template directory.
@@ -335,10 +449,19 @@ func retrievePulumiTemplates(templateName string, offline bool, templateKind Tem
 			repo = pulumiPolicyTemplateGitRepository
 			branch = plumbing.NewBranchReferenceName(pulumiPolicyTemplateBranch)
 		}
-		err := gitutil.GitCloneOrPull(repo, branch, templateDir, false /*shallow*/)
+		err := retryTemplateRetrieval(repo, templateRetries(), templateRetryDelay, func() error {
+			return gitutil.GitCloneOrPullWithOptions(repo, branch, templateDir, false /*shallow*/, opts)
+		})
 		if err != nil {
 			return TemplateRepository{}, fmt.Errorf("cloning templates repo: %w", err)
 		}
+
+		// Only policy templates are verified when used offline, so only their checksums are recorded.
+		if templateKind == TemplateKindPolicyPack {
+			if err := writeTemplateChecksums(templateDir); err != nil {
+				return TemplateRepository{}, fmt.Errorf("recording template checksums: %w", err)
+			}
+		}
 	}
 
 	subDir := templateDir
@@ -362,14 +485,215 @@ func retrievePulumiTemplates(templateName string, offline bool, templateKind Tem
 	}, nil
 }
 
+// templateChecksumsPath returns the path of the file that records the checksums of the template directory at dir.
+func templateChecksumsPath(dir string) string {
+	return filepath.Clean(dir) + templateChecksumsSuffix
+}
+
+// templateChecksums is the contents of the file at templateChecksumsPath.
+type templateChecksums struct {
+	// Files maps the slash-separated path of each file, relative to the template directory, to its SHA-256 checksum.
+	Files map[string]string `json:"files"`
+}
+
+// computeTemplateChecksums returns the checksums of the files under dir, keyed by their slash-separated paths relative
+// to root. The .git directory is skipped.
+func computeTemplateChecksums(root, dir string) (map[string]string, error) {
+	checksums := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == GitDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		checksums[filepath.ToSlash(rel)] = hex.EncodeToString(sum[:])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+// writeTemplateChecksums records the checksums of the files in the template directory at dir.
+func writeTemplateChecksums(dir string) error {
+	checksums, err := computeTemplateChecksums(dir, dir)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(templateChecksums{Files: checksums}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(templateChecksumsPath(dir), b, 0600)
+}
+
+// TemplateChecksumError is returned by VerifyChecksums when cached templates no longer match the checksums recorded
+// when they were downloaded. Paths are slash-separated and relative to the template directory.
+type TemplateChecksumError struct {
+	Dir      string   // The template directory.
+	Modified []string // Files whose contents have changed.
+	Added    []string // Files that were not present when the templates were downloaded.
+	Removed  []string // Files that have been removed.
+}
+
+func (e *TemplateChecksumError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cached templates in %s have changed since they were downloaded", e.Dir)
+	for _, group := range []struct {
+		label string
+		paths []string
+	}{{"modified", e.Modified}, {"added", e.Added}, {"removed", e.Removed}} {
+		for _, path := range group.paths {
+			fmt.Fprintf(&b, "\n    %s: %s", group.label, path)
+		}
+	}
+	return b.String()
+}
+
+// VerifyChecksums checks the templates in the repository's sub directory against the checksums recorded when the
+// templates were downloaded, returning a *TemplateChecksumError if any files were modified, added, or removed since.
+// Repositories without recorded checksums, such as templates retrieved from a URL or a local path, or a template
+// cache populated by an older version of Pulumi, are not verified.
+func (repo TemplateRepository) VerifyChecksums() error {
+	b, err := ioutil.ReadFile(templateChecksumsPath(repo.Root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			logging.V(5).Infof("no template checksums recorded in %s; skipping verification", repo.Root)
+			return nil
+		}
+		return err
+	}
+	var recorded templateChecksums
+	if err := json.Unmarshal(b, &recorded); err != nil {
+		return fmt.Errorf("reading template checksums: %w", err)
+	}
+
+	actual, err := computeTemplateChecksums(repo.Root, repo.SubDirectory)
+	if err != nil {
+		return err
+	}
+
+	// Only the files under the sub directory are verified.
+	prefix, err := filepath.Rel(repo.Root, repo.SubDirectory)
+	if err != nil {
+		return err
+	}
+	prefix = filepath.ToSlash(prefix)
+
+	checksumErr := &TemplateChecksumError{Dir: repo.Root}
+	for path, sum := range recorded.Files {
+		if prefix != "." && path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+		if actualSum, ok := actual[path]; !ok {
+			checksumErr.Removed = append(checksumErr.Removed, path)
+		} else if actualSum != sum {
+			checksumErr.Modified = append(checksumErr.Modified, path)
+		}
+	}
+	for path := range actual {
+		if _, ok := recorded.Files[path]; !ok {
+			checksumErr.Added = append(checksumErr.Added, path)
+		}
+	}
+	if len(checksumErr.Modified) == 0 && len(checksumErr.Added) == 0 && len(checksumErr.Removed) == 0 {
+		return nil
+	}
+
+	sort.Strings(checksumErr.Modified)
+	sort.Strings(checksumErr.Added)
+	sort.Strings(checksumErr.Removed)
+	return checksumErr
+}
+
+// templateRetries returns the number of times to retry retrieving templates after a transient failure, as set by
+// PULUMI_TEMPLATE_RETRIES.
+func templateRetries() int {
+	if v := os.Getenv(pulumiTemplateRetriesEnvVar); v != "" {
+		if retries, err := strconv.Atoi(v); err == nil && retries >= 0 {
+			return retries
+		}
+		logging.Warningf("ignoring invalid value for %s: %q", pulumiTemplateRetriesEnvVar, v)
+	}
+	return defaultTemplateRetries
+}
+
+// retryTemplateRetrieval calls retrieve, which retrieves templates from url, until it succeeds, fails with an error
+// that is not transient, or has been retried the given number of times. The delay between attempts starts at delay
+// and doubles after each retry.
+func retryTemplateRetrieval(url string, retries int, delay time.Duration, retrieve func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := retrieve()
+		if err == nil || attempt > retries || !isTransientTemplateError(err) {
+			return err
+		}
+
+		logging.V(1).Infof("retrieving templates from %s failed (attempt %d of %d), retrying in %v: %v",
+			url, attempt, retries+1, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isTransientTemplateError returns true if err is a failure to retrieve templates that may succeed if retried: a
+// network error, such as a timeout, or a server error. Missing repositories and authentication failures are not
+// transient.
+func isTransientTemplateError(err error) bool {
+	switch {
+	case errors.Is(err, transport.ErrRepositoryNotFound),
+		errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrInvalidAuthMethod):
+		return false
+	}
+
+	// go-git reports HTTP status codes and failed requests as unexpected errors that do not support unwrapping.
+	var unexpected *plumbing.UnexpectedError
+	if errors.As(err, &unexpected) {
+		err = unexpected.Err
+	}
+
+	var status *githttp.Err
+	if errors.As(err, &status) {
+		code := status.StatusCode()
+		return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
 // RetrieveGitFolder downloads the repo to path and returns the full path on disk.
 func RetrieveGitFolder(rawurl string, path string) (string, error) {
+	return RetrieveGitFolderWithOptions(rawurl, path, gitutil.HTTPOptions{})
+}
+
+// RetrieveGitFolderWithOptions is like RetrieveGitFolder, but downloads the repo with the given HTTP options.
+func RetrieveGitFolderWithOptions(rawurl string, path string, opts gitutil.HTTPOptions) (string, error) {
 	url, urlPath, err := gitutil.ParseGitRepoURL(rawurl)
 	if err != nil {
 		return "", err
 	}
 
-	ref, commit, subDirectory, err := gitutil.GetGitReferenceNameOrHashAndSubDirectory(url, urlPath)
+	ref, commit, subDirectory, err := gitutil.GetGitReferenceNameOrHashAndSubDirectoryWithOptions(url, urlPath, opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to get git ref: %w", err)
 	}
@@ -388,7 +712,7 @@ func RetrieveGitFolder(rawurl string, path string) (string, error) {
 		var cloneErr error
 		for _, ref := range refAttempts {
 			// Attempt the clone. If it succeeds, break
-			cloneErr := gitutil.GitCloneOrPull(url, ref, path, true /*shallow*/)
+			cloneErr := gitutil.GitCloneOrPullWithOptions(url, ref, path, true /*shallow*/, opts)
 			if cloneErr == nil {
 				break
 			}
@@ -398,7 +722,7 @@ func RetrieveGitFolder(rawurl string, path string) (string, error) {
 		}
 
 	} else {
-		if cloneErr := gitutil.GitCloneAndCheckoutCommit(url, commit, path); cloneErr != nil {
+		if cloneErr := gitutil.GitCloneAndCheckoutCommitWithOptions(url, commit, path, opts); cloneErr != nil {
 			return "", fmt.Errorf("failed to clone and checkout %s(%s): %w", url, commit, cloneErr)
 		}
 	}
@@ -475,24 +799,65 @@ func CopyTemplateFilesDryRun(sourceDir, destDir, projectName string) error {
 func CopyTemplateFiles(
 	sourceDir, destDir string, force bool, projectName string, projectDescription string) error {
 
-	return walkFiles(sourceDir, destDir, projectName,
+	_, err := CopyTemplateFilesWithVariables(sourceDir, destDir, force, projectName, projectDescription, nil)
+	return err
+}
+
+// CopyTemplateFilesWithVariables copies a template to a destination directory like CopyTemplateFiles, and also
+// replaces each ${key} placeholder in the template's text files with the corresponding value from variables. It
+// returns the sorted names of the placeholders of the form ${name}, where name is a valid template variable name,
+// that remain in the copied files.
+func CopyTemplateFilesWithVariables(sourceDir, destDir string, force bool, projectName string,
+	projectDescription string, variables map[string]string) ([]string, error) {
+
+	_, unresolved, err := copyTemplateFiles(sourceDir, destDir, force, false, projectName, projectDescription, variables)
+	return unresolved, err
+}
+
+// CopyNewTemplateFiles copies a template to a destination directory like CopyTemplateFilesWithVariables, but skips
+// the files that already exist in the destination directory instead of overwriting them. It returns the full paths of
+// the files that were written, followed by the unresolved placeholders in those files.
+func CopyNewTemplateFiles(sourceDir, destDir string, projectName string, projectDescription string,
+	variables map[string]string) ([]string, []string, error) {
+
+	return copyTemplateFiles(sourceDir, destDir, false, true, projectName, projectDescription, variables)
+}
+
+// copyTemplateFiles copies a template to a destination directory, returning the full paths of the files that were
+// written and the sorted names of the unresolved placeholders in them. If skipExisting is true, files that already
+// exist in the destination directory are left alone.
+func copyTemplateFiles(sourceDir, destDir string, force bool, skipExisting bool, projectName string,
+	projectDescription string, variables map[string]string) ([]string, []string, error) {
+
+	var written []string
+	unresolved := map[string]bool{}
+	err := walkFiles(sourceDir, destDir, projectName,
 		func(info os.FileInfo, source string, dest string) error {
 			if info.IsDir() {
+				if skipExisting {
+					if destInfo, statErr := os.Stat(dest); statErr == nil && destInfo.IsDir() {
+						return nil
+					}
+				}
 				// Create the destination directory.
 				return os.Mkdir(dest, 0700)
 			}
 
-			// Read the source file.
-			b, err := ioutil.ReadFile(source)
+			if skipExisting {
+				if _, statErr := os.Lstat(dest); statErr == nil {
+					return nil
+				}
+			}
+
+			// Read and transform the source file.
+			result, err := readTemplateFile(source, projectName, projectDescription, variables)
 			if err != nil {
 				return err
 			}
-
-			// Transform only if it isn't a binary file.
-			result := b
-			if !isBinary(b) {
-				transformed := transform(string(b), projectName, projectDescription)
-				result = []byte(transformed)
+			if !isBinary(result) {
+				for _, match := range templatePlaceholderRegexp.FindAllStringSubmatch(string(result), -1) {
+					unresolved[match[1]] = true
+				}
 			}
 
 			// Originally we just wrote in 0600 mode, but
@@ -514,9 +879,114 @@ func CopyTemplateFiles(
 				if os.IsExist(err) {
 					return newExistingFilesError([]string{filepath.Base(dest)})
 				}
+				return err
 			}
-			return err
+			written = append(written, dest)
+			return nil
 		})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(unresolved))
+	for name := range unresolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return written, names, nil
+}
+
+// TemplateFile describes a file that copying a template to a destination directory would write.
+type TemplateFile struct {
+	Path     string      // The full path of the file in the destination directory.
+	Content  []byte      // The content the file would have after copying.
+	Mode     os.FileMode // The permissions a new file would be created with after copying.
+	Exists   bool        // Whether a file already exists at Path.
+	Existing []byte      // The current content of the file at Path, if it exists.
+}
+
+// PreviewTemplateFiles returns the files that copying a template to a destination directory with
+// CopyTemplateFilesWithVariables would write, without writing anything.
+func PreviewTemplateFiles(sourceDir, destDir string, projectName string, projectDescription string,
+	variables map[string]string) ([]TemplateFile, error) {
+
+	var files []TemplateFile
+	err := walkFiles(sourceDir, destDir, projectName,
+		func(info os.FileInfo, source string, dest string) error {
+			if info.IsDir() {
+				return nil
+			}
+
+			content, err := readTemplateFile(source, projectName, projectDescription, variables)
+			if err != nil {
+				return err
+			}
+			// As when copying, new files are at least as permissive as 0600 and the source file.
+			file := TemplateFile{Path: dest, Content: content, Mode: info.Mode().Perm() | 0600}
+
+			if destInfo, statErr := os.Stat(dest); statErr == nil && !destInfo.IsDir() {
+				existing, err := ioutil.ReadFile(dest)
+				if err != nil {
+					return err
+				}
+				file.Exists, file.Existing = true, existing
+			}
+
+			files = append(files, file)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// templateVariableNamePattern is the grammar of the names of template variables, which are the keys that replace the
+// ${name} placeholders in a template's files.
+const templateVariableNamePattern = `[A-Za-z_][A-Za-z0-9_]*`
+
+var (
+	validTemplateVariableNameRegexp = regexp.MustCompile("^" + templateVariableNamePattern + "$")
+
+	// templatePlaceholderRegexp matches the placeholders that CopyTemplateFilesWithVariables reports when they are
+	// left unresolved. It accepts the same names as ValidateTemplateVariableName, so that any placeholder that a
+	// variable could have replaced is reported.
+	templatePlaceholderRegexp = regexp.MustCompile(`\$\{(` + templateVariableNamePattern + `)\}`)
+)
+
+// ValidateTemplateVariableName ensures that a template variable name is valid: it must start with a letter or an
+// underscore and contain only letters, digits, and underscores.
+func ValidateTemplateVariableName(s string) error {
+	if !validTemplateVariableNameRegexp.MatchString(s) {
+		return errors.Errorf("invalid template variable name '%s': names must start with a letter or an underscore "+
+			"and contain only letters, digits, and underscores", s)
+	}
+	return nil
+}
+
+// readTemplateFile reads a file from a template, replacing the project name and description placeholders and the
+// placeholders for the given variables in its content unless it is a binary file.
+func readTemplateFile(source string, projectName string, projectDescription string,
+	variables map[string]string) ([]byte, error) {
+
+	b, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Transform only if it isn't a binary file.
+	if isBinary(b) {
+		return b, nil
+	}
+	content := transform(string(b), projectName, projectDescription)
+	if len(variables) > 0 {
+		oldnew := make([]string, 0, 2*len(variables))
+		for key, value := range variables {
+			oldnew = append(oldnew, "${"+key+"}", value)
+		}
+		content = strings.NewReplacer(oldnew...).Replace(content)
+	}
+	return []byte(content), nil
 }
 
 // LoadPolicyPackTemplate returns a Policy Pack template from a path.
@@ -534,12 +1004,19 @@ func LoadPolicyPackTemplate(path string) (PolicyPackTemplate, error) {
 		return PolicyPackTemplate{}, err
 	}
 	policyPackTemplate := PolicyPackTemplate{
-		Dir:  path,
-		Name: filepath.Base(path),
+		Dir:     path,
+		Name:    filepath.Base(path),
+		Runtime: pack.Runtime.Name(),
 	}
 	if pack.Description != nil {
 		policyPackTemplate.Description = *pack.Description
 	}
+	if pack.Template != nil {
+		policyPackTemplate.Hooks = pack.Template.Hooks
+		policyPackTemplate.Packs = pack.Template.Packs
+		policyPackTemplate.Framework = pack.Template.Framework
+		policyPackTemplate.Parameters = pack.Template.Parameters
+	}
 
 	return policyPackTemplate, nil
 }
@@ -569,7 +1046,8 @@ func GetTemplateDir(templateKind TemplateKind) (string, error) {
 // in this file. Though the backend may enforce stronger restrictions for a project name or description
 // further down the line.
 var (
-	validProjectNameRegexp = regexp.MustCompile("^[A-Za-z0-9_.-]{1,100}$")
+	validProjectNameRegexp    = regexp.MustCompile("^[A-Za-z0-9_.-]{1,100}$")
+	validPolicyPackNameRegexp = regexp.MustCompile("^[a-z0-9-]{1,100}$")
 )
 
 // ValidateProjectName ensures a project name is valid, if it is not it returns an error with a message suitable
@@ -596,6 +1074,25 @@ func ValidateProjectName(s string) error {
 	return nil
 }
 
+// ValidatePolicyPackName ensures a Policy Pack name can be published, if it cannot it returns an error with a message
+// suitable for display to an end user.
+func ValidatePolicyPackName(s string) error {
+	if s == "" {
+		return errors.New("A Policy Pack name may not be empty")
+	}
+
+	if len(s) > 100 {
+		return errors.New("A Policy Pack name must be 100 characters or less")
+	}
+
+	if !validPolicyPackNameRegexp.MatchString(s) {
+		return fmt.Errorf("A Policy Pack name may only contain lowercase alphanumerics and hyphens (%s)",
+			validPolicyPackNameRegexp)
+	}
+
+	return nil
+}
+
 // ValidateProjectDescription ensures a project description name is valid, if it is not it returns an error with a
 // message suitable for display to an end user.
 func ValidateProjectDescription(s string) error {
@@ -731,35 +1228,50 @@ func newExistingFilesError(existing []string) error {
 // newTemplateNotFoundError returns an error for when the template doesn't exist,
 // offering distance-based suggestions in the error message.
 func newTemplateNotFoundError(templateDir string, templateName string) error {
-	message := fmt.Sprintf("template '%s' not found", templateName)
+	notFound := &TemplateNotFoundError{
+		Name:       templateName,
+		Repository: TemplateRepository{Root: templateDir, SubDirectory: templateDir},
+	}
 
 	// Attempt to read the directory to offer suggestions.
 	infos, err := ioutil.ReadDir(templateDir)
 	if err != nil {
 		contract.IgnoreError(err)
-		return errors.New(message)
+		return notFound
 	}
 
 	// Get suggestions based on levenshtein distance.
-	suggestions := []string{}
 	const minDistance = 2
 	op := levenshtein.DefaultOptions
 	for _, info := range infos {
 		distance := levenshtein.DistanceForStrings([]rune(templateName), []rune(info.Name()), op)
 		if distance <= minDistance {
-			suggestions = append(suggestions, info.Name())
+			notFound.Suggestions = append(notFound.Suggestions, info.Name())
 		}
 	}
 
+	return notFound
+}
+
+// TemplateNotFoundError is returned by RetrieveTemplates when there is no template with the requested name.
+type TemplateNotFoundError struct {
+	Name        string             // The name of the template.
+	Suggestions []string           // The names of templates with similar names.
+	Repository  TemplateRepository // The retrieved repository of templates that was searched for the template.
+}
+
+func (e *TemplateNotFoundError) Error() string {
+	message := fmt.Sprintf("template '%s' not found", e.Name)
+
 	// Build-up error message with suggestions.
-	if len(suggestions) > 0 {
+	if len(e.Suggestions) > 0 {
 		message = message + "\n\nDid you mean this?\n"
-		for _, suggestion := range suggestions {
+		for _, suggestion := range e.Suggestions {
 			message = message + fmt.Sprintf("\t%s\n", suggestion)
 		}
 	}
 
-	return errors.New(message)
+	return message
 }
 
 // transform returns a new string with ${PROJECT} and ${DESCRIPTION} replaced by
@@ -15,11 +15,25 @@
 package workspace
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/contract"
 )
 
 func TestGetValidDefaultProjectName(t *testing.T) {
@@ -229,6 +243,330 @@ func TestRetrieveHttpsTemplateOffline(t *testing.T) {
 	}
 }
 
+//nolint:paralleltest // sets environment variables
+func TestRetrievePolicyTemplateOfflineFromPulumiHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(PulumiHomeEnvVar, home)
+	t.Setenv(pulumiLocalPolicyTemplatePathEnvVar, "")
+
+	// Populate the cache the way an earlier online retrieval would.
+	templateDir, err := GetTemplateDir(TemplateKindPolicyPack)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, TemplatePolicyDir), templateDir)
+	packDir := filepath.Join(templateDir, "aws-typescript")
+	assert.NoError(t, os.MkdirAll(packDir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(packDir, "PulumiPolicy.yaml"),
+		[]byte("runtime: nodejs\ndescription: A policy pack\n"), 0600))
+
+	repository, err := RetrieveTemplates("aws-typescript", true, TemplateKindPolicyPack)
+	assert.NoError(t, err)
+	assert.Equal(t, templateDir, repository.Root)
+	assert.Equal(t, packDir, repository.SubDirectory)
+
+	templates, err := repository.PolicyTemplates()
+	assert.NoError(t, err)
+	if assert.Len(t, templates, 1) {
+		assert.Equal(t, "aws-typescript", templates[0].Name)
+		assert.Equal(t, "A policy pack", templates[0].Description)
+	}
+}
+
+func TestVerifyTemplateChecksums(t *testing.T) {
+	t.Parallel()
+
+	// newCache returns a template cache with two templates whose checksums have been recorded.
+	newCache := func(t *testing.T) string {
+		root := t.TempDir()
+		for _, name := range []string{"aws-typescript", "azure-python"} {
+			dir := filepath.Join(root, name)
+			assert.NoError(t, os.MkdirAll(filepath.Join(dir, "src"), 0700))
+			assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"), []byte("runtime: nodejs\n"), 0600))
+			assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "src", "index.ts"), []byte("// "+name+"\n"), 0600))
+		}
+		// The git metadata is not part of the templates.
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, GitDir), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, GitDir, "HEAD"), []byte("ref: master\n"), 0600))
+		assert.NoError(t, writeTemplateChecksums(root))
+		return root
+	}
+	repository := func(root string) TemplateRepository {
+		return TemplateRepository{Root: root, SubDirectory: filepath.Join(root, "aws-typescript")}
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		root := newCache(t)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, GitDir, "HEAD"), []byte("ref: main\n"), 0600))
+		assert.NoError(t, repository(root).VerifyChecksums())
+		assert.NoError(t, TemplateRepository{Root: root, SubDirectory: root}.VerifyChecksums())
+	})
+
+	t.Run("Corrupted", func(t *testing.T) {
+		t.Parallel()
+
+		root := newCache(t)
+		dir := filepath.Join(root, "aws-typescript")
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "src", "index.ts"), []byte("// tampered\n"), 0600))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "extra.ts"), []byte("// extra\n"), 0600))
+		assert.NoError(t, os.Remove(filepath.Join(dir, "PulumiPolicy.yaml")))
+
+		err := repository(root).VerifyChecksums()
+		var checksumErr *TemplateChecksumError
+		if assert.True(t, errors.As(err, &checksumErr), "unexpected error: %v", err) {
+			assert.Equal(t, []string{"aws-typescript/src/index.ts"}, checksumErr.Modified)
+			assert.Equal(t, []string{"aws-typescript/extra.ts"}, checksumErr.Added)
+			assert.Equal(t, []string{"aws-typescript/PulumiPolicy.yaml"}, checksumErr.Removed)
+			assert.Contains(t, err.Error(), "modified: aws-typescript/src/index.ts")
+		}
+	})
+
+	t.Run("OtherTemplateChanged", func(t *testing.T) {
+		t.Parallel()
+
+		// Only the retrieved template is verified.
+		root := newCache(t)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "azure-python", "PulumiPolicy.yaml"),
+			[]byte("runtime: python\n"), 0600))
+		assert.NoError(t, repository(root).VerifyChecksums())
+		assert.Error(t, TemplateRepository{Root: root, SubDirectory: root}.VerifyChecksums())
+	})
+
+	t.Run("NoChecksums", func(t *testing.T) {
+		t.Parallel()
+
+		root := newCache(t)
+		assert.NoError(t, os.Remove(templateChecksumsPath(root)))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "aws-typescript", "PulumiPolicy.yaml"),
+			[]byte("runtime: python\n"), 0600))
+		assert.NoError(t, repository(root).VerifyChecksums())
+	})
+}
+
+//nolint:paralleltest // sets environment variables
+func TestVerifyRetrievedPolicyTemplateChecksums(t *testing.T) {
+	templateDir := t.TempDir()
+	t.Setenv(pulumiLocalPolicyTemplatePathEnvVar, templateDir)
+
+	packDir := filepath.Join(templateDir, "aws-typescript")
+	assert.NoError(t, os.MkdirAll(packDir, 0700))
+	path := filepath.Join(packDir, "PulumiPolicy.yaml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("runtime: nodejs\n"), 0600))
+	assert.NoError(t, writeTemplateChecksums(templateDir))
+
+	// The checksums are recorded outside the template directory, which is a git checkout.
+	assert.FileExists(t, templateChecksumsPath(templateDir))
+	entries, err := ioutil.ReadDir(templateDir)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "aws-typescript", entries[0].Name())
+	}
+
+	repository, err := RetrieveTemplates("aws-typescript", true, TemplateKindPolicyPack)
+	assert.NoError(t, err)
+	assert.NoError(t, repository.VerifyChecksums())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("runtime: nodejs\ndescription: tampered\n"), 0600))
+	err = repository.VerifyChecksums()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "have changed since they were downloaded")
+}
+
+//nolint:paralleltest // sets environment variables
+func TestRetrieveTemplatesNotFoundCarriesRepository(t *testing.T) {
+	templateDir := t.TempDir()
+	t.Setenv(pulumiLocalPolicyTemplatePathEnvVar, templateDir)
+	assert.NoError(t, os.Mkdir(filepath.Join(templateDir, "aws-typescript"), 0700))
+
+	// The error carries the repository that was searched, so that its other templates can be used without
+	// retrieving it again.
+	_, err := RetrieveTemplates("aws-typescrpt", true, TemplateKindPolicyPack)
+	var notFound *TemplateNotFoundError
+	if assert.True(t, errors.As(err, &notFound), "unexpected error: %v", err) {
+		assert.Equal(t, []string{"aws-typescript"}, notFound.Suggestions)
+		assert.Equal(t, TemplateRepository{Root: templateDir, SubDirectory: templateDir}, notFound.Repository)
+	}
+}
+
+// fetchTemplateStatus requests url and returns the error go-git would report for the response.
+func fetchTemplateStatus(url string) error {
+	resp, err := http.Get(url) //nolint:gosec // test server URL
+	if err != nil {
+		return err
+	}
+	defer contract.IgnoreClose(resp.Body)
+	return githttp.NewErr(resp)
+}
+
+func TestRetryTemplateRetrieval(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	err := retryTemplateRetrieval(server.URL, 3, time.Millisecond, func() error {
+		return fetchTemplateStatus(server.URL)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestRetryTemplateRetrievalGivesUp(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := retryTemplateRetrieval(server.URL, 2, time.Millisecond, func() error {
+		return fetchTemplateStatus(server.URL)
+	})
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestRetryTemplateRetrievalNotFound(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := retryTemplateRetrieval(server.URL, 3, time.Millisecond, func() error {
+		return fetchTemplateStatus(server.URL)
+	})
+	assert.Equal(t, transport.ErrRepositoryNotFound, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestIsTransientTemplateError(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isTransientTemplateError(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.True(t, isTransientTemplateError(plumbing.NewUnexpectedError(&net.DNSError{IsTimeout: true})))
+	assert.False(t, isTransientTemplateError(transport.ErrRepositoryNotFound))
+	assert.False(t, isTransientTemplateError(transport.ErrAuthenticationRequired))
+	assert.False(t, isTransientTemplateError(transport.ErrAuthorizationFailed))
+	assert.False(t, isTransientTemplateError(errors.New("reference not found")))
+}
+
+//nolint:paralleltest // sets environment variables
+func TestTemplateRetries(t *testing.T) {
+	t.Setenv(pulumiTemplateRetriesEnvVar, "")
+	assert.Equal(t, defaultTemplateRetries, templateRetries())
+
+	t.Setenv(pulumiTemplateRetriesEnvVar, "5")
+	assert.Equal(t, 5, templateRetries())
+
+	t.Setenv(pulumiTemplateRetriesEnvVar, "0")
+	assert.Equal(t, 0, templateRetries())
+
+	t.Setenv(pulumiTemplateRetriesEnvVar, "-1")
+	assert.Equal(t, defaultTemplateRetries, templateRetries())
+
+	t.Setenv(pulumiTemplateRetriesEnvVar, "lots")
+	assert.Equal(t, defaultTemplateRetries, templateRetries())
+}
+
+func TestLoadPolicyPackTemplateHooks(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "aws-typescript")
+	assert.NoError(t, os.Mkdir(dir, 0700))
+	contents := "runtime: nodejs\ndescription: A policy pack\ntemplate:\n  hooks:\n    - npm run format\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"), []byte(contents), 0600))
+
+	template, err := LoadPolicyPackTemplate(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "aws-typescript", template.Name)
+	assert.Equal(t, []string{"npm run format"}, template.Hooks)
+}
+
+func TestLoadPolicyPackTemplatePacks(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "governance")
+	assert.NoError(t, os.Mkdir(dir, 0700))
+	contents := "runtime: nodejs\ntemplate:\n  packs:\n    - security\n    - cost\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"), []byte(contents), 0600))
+
+	template, err := LoadPolicyPackTemplate(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"security", "cost"}, template.Packs)
+
+	// Packs must be subdirectories of the template.
+	for _, pack := range []string{"..", "security/rules", "/tmp"} {
+		dir := t.TempDir()
+		contents := fmt.Sprintf("runtime: nodejs\ntemplate:\n  packs:\n    - %s\n", pack)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"), []byte(contents), 0600))
+		_, err := LoadPolicyPackTemplate(dir)
+		if assert.Error(t, err, pack) {
+			assert.Contains(t, err.Error(), "packs must name subdirectories of the template")
+		}
+	}
+}
+
+func TestLoadPolicyPackTemplateParameters(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "tagged")
+	assert.NoError(t, os.Mkdir(dir, 0700))
+	contents := "runtime: nodejs\ntemplate:\n  parameters:\n" +
+		"    - name: ORG\n      prompt: Organization\n      required: true\n" +
+		"    - name: ENVIRONMENT\n      default: dev\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"), []byte(contents), 0600))
+
+	template, err := LoadPolicyPackTemplate(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []PolicyPackTemplateParameter{
+		{Name: "ORG", Prompt: "Organization", Required: true},
+		{Name: "ENVIRONMENT", Default: "dev"},
+	}, template.Parameters)
+
+	// Parameters must be named, and only once.
+	for _, params := range []string{"    - prompt: Organization\n", "    - name: ORG\n    - name: ORG\n"} {
+		dir := t.TempDir()
+		contents := "runtime: nodejs\ntemplate:\n  parameters:\n" + params
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"), []byte(contents), 0600))
+		_, err := LoadPolicyPackTemplate(dir)
+		assert.Error(t, err, params)
+	}
+}
+
+func TestLoadPolicyPackTemplateFramework(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "framework")
+	assert.NoError(t, os.Mkdir(dir, 0700))
+	contents := "runtime: nodejs\ntemplate:\n  framework:\n    - package.json\n    - \"*.config.js\"\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "PulumiPolicy.yaml"), []byte(contents), 0600))
+
+	template, err := LoadPolicyPackTemplate(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"package.json", "*.config.js"}, template.Framework)
+
+	// Patterns must be valid globs.
+	invalid := filepath.Join(t.TempDir(), "invalid")
+	assert.NoError(t, os.Mkdir(invalid, 0700))
+	contents = "runtime: nodejs\ntemplate:\n  framework:\n    - \"[package.json\"\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(invalid, "PulumiPolicy.yaml"), []byte(contents), 0600))
+	_, err = LoadPolicyPackTemplate(invalid)
+	assert.Error(t, err)
+}
+
 //nolint:paralleltest // uses shared state in pulumi dir
 func TestRetrieveFileTemplate(t *testing.T) {
 	tests := []struct {
@@ -259,6 +597,49 @@ func TestRetrieveFileTemplate(t *testing.T) {
 	}
 }
 
+func TestRetrieveArchiveTemplate(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join("testdata", "policy-templates.tgz")
+	assert.True(t, isTemplateArchive(path))
+
+	repository, err := RetrieveTemplates(path, true /*offline*/, TemplateKindPolicyPack)
+	assert.NoError(t, err)
+	assert.True(t, repository.ShouldDelete)
+	assert.Equal(t, filepath.Join(repository.Root, "policy-templates", "policy-templates"), repository.SubDirectory)
+
+	templates, err := repository.PolicyTemplates()
+	assert.NoError(t, err)
+	if assert.Len(t, templates, 2) {
+		assert.Equal(t, "aws-typescript", templates[0].Name)
+		assert.Equal(t, "A minimal AWS Policy Pack in TypeScript", templates[0].Description)
+		assert.FileExists(t, filepath.Join(templates[0].Dir, "index.ts"))
+		assert.Equal(t, "azure-python", templates[1].Name)
+	}
+
+	// The extracted templates are removed with the repository.
+	assert.NoError(t, repository.Delete())
+	assert.NoDirExists(t, repository.Root)
+}
+
+func TestIsTemplateArchive(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"templates.tar.gz", "templates.TGZ", "templates.zip"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "dir.tgz"), 0700))
+
+	assert.True(t, isTemplateArchive(filepath.Join(dir, "templates.tar.gz")))
+	assert.True(t, isTemplateArchive(filepath.Join(dir, "templates.TGZ")))
+	assert.False(t, isTemplateArchive(filepath.Join(dir, "templates.zip")))
+	assert.False(t, isTemplateArchive(filepath.Join(dir, "dir.tgz")))
+	assert.False(t, isTemplateArchive(filepath.Join(dir, "missing.tgz")))
+	assert.False(t, isTemplateArchive("https://example.com/templates.tgz"))
+	assert.False(t, isTemplateArchive(".tgz"))
+}
+
 func TestProjectNames(t *testing.T) {
 	t.Parallel()
 
@@ -323,3 +704,148 @@ func TestProjectNames(t *testing.T) {
 		})
 	}
 }
+
+func TestValidatePolicyPackName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		testName    string
+		packName    string
+		expectError bool
+	}{
+		{
+			testName:    "Correct Policy Pack Name",
+			packName:    "aws-typescript",
+			expectError: false,
+		},
+		{
+			testName:    "Policy Pack Name with digits",
+			packName:    "cis-1-2",
+			expectError: false,
+		},
+		{
+			testName:    "Policy Pack Name with uppercase letters",
+			packName:    "AwsTypescript",
+			expectError: true,
+		},
+		{
+			testName:    "Policy Pack Name with underscores",
+			packName:    "aws_typescript",
+			expectError: true,
+		},
+		{
+			testName:    "Policy Pack Name with periods",
+			packName:    "aws.typescript",
+			expectError: true,
+		},
+		{
+			testName:    "Policy Pack Name greater than 100 characters",
+			packName:    strings.Repeat("a", 101),
+			expectError: true,
+		},
+		{
+			testName:    "Empty Policy Pack Name",
+			packName:    "",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidatePolicyPackName(tt.packName)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestPreviewTemplateFiles(t *testing.T) {
+	t.Parallel()
+
+	sourceDir, destDir := t.TempDir(), t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(sourceDir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "index.ts"), []byte("// ${PROJECT}"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "sub", "new.txt"), []byte("new"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(destDir, "index.ts"), []byte("// old"), 0600))
+
+	files, err := PreviewTemplateFiles(sourceDir, destDir, "proj", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []TemplateFile{
+		{
+			Path:     filepath.Join(destDir, "index.ts"),
+			Content:  []byte("// proj"),
+			Mode:     0600,
+			Exists:   true,
+			Existing: []byte("// old"),
+		},
+		{
+			Path:    filepath.Join(destDir, "sub", "new.txt"),
+			Content: []byte("new"),
+			Mode:    0600,
+		},
+	}, files)
+
+	// Nothing should have been written.
+	_, err = os.Stat(filepath.Join(destDir, "sub"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCopyTemplateFilesWithVariables(t *testing.T) {
+	t.Parallel()
+
+	sourceDir, destDir := t.TempDir(), t.TempDir()
+	text := "org: ${ORG} severity: ${SEVERITY} project: ${PROJECT} code: ${name} region: ${region}"
+	binary := "\x00${ORG}"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "config.yaml"), []byte(text), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "data.bin"), []byte(binary), 0600))
+
+	unresolved, err := CopyTemplateFilesWithVariables(sourceDir, destDir, false, "proj", "",
+		map[string]string{"ORG": "acme", "name": "n"})
+	assert.NoError(t, err)
+	// Lowercase placeholders are reported too, since they could have been set.
+	assert.Equal(t, []string{"SEVERITY", "region"}, unresolved)
+
+	b, err := ioutil.ReadFile(filepath.Join(destDir, "config.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "org: acme severity: ${SEVERITY} project: proj code: n region: ${region}", string(b))
+
+	// Binary files are copied as-is.
+	b, err = ioutil.ReadFile(filepath.Join(destDir, "data.bin"))
+	assert.NoError(t, err)
+	assert.Equal(t, binary, string(b))
+}
+
+func TestCopyNewTemplateFiles(t *testing.T) {
+	t.Parallel()
+
+	sourceDir, destDir := t.TempDir(), t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "PulumiPolicy.yaml"), []byte("runtime: nodejs\n"), 0600))
+	assert.NoError(t, os.Mkdir(filepath.Join(sourceDir, "policies"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "policies", "new.ts"), []byte("${SEVERITY}"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "policies", "old.ts"), []byte("${ORG}"), 0600))
+
+	// The destination already has metadata and some of the template's files.
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(destDir, "PulumiPolicy.yaml"), []byte("runtime: python\n"), 0600))
+	assert.NoError(t, os.Mkdir(filepath.Join(destDir, "policies"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(destDir, "policies", "old.ts"), []byte("mine"), 0600))
+
+	written, unresolved, err := CopyNewTemplateFiles(sourceDir, destDir, "", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(destDir, "policies", "new.ts")}, written)
+	assert.Equal(t, []string{"SEVERITY"}, unresolved)
+
+	// Existing files are left alone.
+	b, err := ioutil.ReadFile(filepath.Join(destDir, "PulumiPolicy.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "runtime: python\n", string(b))
+	b, err = ioutil.ReadFile(filepath.Join(destDir, "policies", "old.ts"))
+	assert.NoError(t, err)
+	assert.Equal(t, "mine", string(b))
+	assert.FileExists(t, filepath.Join(destDir, "policies", "new.ts"))
+}